@@ -0,0 +1,48 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+var RedactSecrets bool
+
+func init() {
+	configDumpCmd.Flags().BoolVarP(&RedactSecrets, "redact-secrets", "", true, "Replace the secrets set with REDACTED")
+	configCmd.AddCommand(configValidateCmd, configDumpCmd)
+	rootCmd.AddCommand(configCmd)
+}
+
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Check the settings of the bot, from its flags, environment and --config file",
+}
+
+var configValidateCmd = &cobra.Command{
+	Use:   "validate",
+	Short: "Check the settings and the files they name, without starting the bot",
+	Args:  cobra.NoArgs,
+	// A setting that does not check out is not a usage error
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		logger := initLogger(Debug).Sugar()
+		if _, err := loadBotConfig(logger); err != nil {
+			return err
+		}
+		fmt.Fprintln(cmd.OutOrStdout(), "Configuration is valid")
+		return nil
+	},
+}
+
+var configDumpCmd = &cobra.Command{
+	Use:   "dump",
+	Short: "Print the effective settings as a configuration file, with the source of each value",
+	Args:  cobra.NoArgs,
+	// A setting that does not check out is not a usage error
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return settings.Dump(os.Stdout, RedactSecrets)
+	},
+}
@@ -0,0 +1,120 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/instructlab/instructlab-bot/gobot/common"
+	"github.com/instructlab/instructlab-bot/gobot/util"
+	"github.com/instructlab/instructlab-bot/pkg/jobs"
+	"github.com/palantir/go-githubapp/githubapp"
+	"go.uber.org/zap"
+)
+
+// progressLines is how many of the last lines of its log are shown on the
+// check run of a running job.
+const progressLines = 20
+
+// progressReporter shows the progress and the latest log lines of the running
+// jobs on their check runs.
+type progressReporter struct {
+	store  *jobs.RedisStore
+	cc     githubapp.ClientCreator
+	logger *zap.SugaredLogger
+	// shown holds the output last shown on the check run of each job, so an
+	// unchanged output is not sent again
+	shown map[string]string
+}
+
+// reportProgress periodically updates the check runs of the running jobs.
+// The interval throttles the calls to the GitHub API.
+func reportProgress(ctx context.Context, store *jobs.RedisStore, cc githubapp.ClientCreator, logger *zap.SugaredLogger, interval time.Duration) {
+	r := &progressReporter{
+		store:  store,
+		cc:     cc,
+		logger: logger,
+		shown:  map[string]string{},
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			logger.Info("Context cancelled, stopping reportProgress")
+			return
+		case <-ticker.C:
+			r.update(ctx)
+		}
+	}
+}
+
+func (r *progressReporter) update(ctx context.Context) {
+	all, err := r.store.Running(ctx)
+	if err != nil {
+		r.logger.Errorf("Failed to list the running jobs to report their progress: %v", err)
+		return
+	}
+	running := map[string]bool{}
+	for _, job := range all {
+		if job.Status != jobs.StatusRunning || job.InstallationID == 0 || job.CheckRunID == 0 {
+			continue
+		}
+		running[job.ID] = true
+		if err := r.updateJob(ctx, job); err != nil {
+			r.logger.Warnf("Failed to report the progress of job %s: %v", job.ID, err)
+		}
+	}
+	for id := range r.shown {
+		if !running[id] {
+			delete(r.shown, id)
+		}
+	}
+}
+
+func (r *progressReporter) updateJob(ctx context.Context, job *jobs.Job) error {
+	lines, err := r.store.Tail(ctx, job.ID, progressLines)
+	if err != nil {
+		return err
+	}
+
+	summary := fmt.Sprintf("Job ID: %s - Running", job.ID)
+	if job.Worker != "" {
+		summary += " on " + job.Worker
+	}
+	summary += "."
+	if percent := job.PercentComplete(); percent >= 0 {
+		summary += fmt.Sprintf(" %d%% complete.", percent)
+	}
+	details := fmt.Sprintf("Beep, boop 🤖, Running the *%s* job for your PR.\n\n", job.JobType)
+	if len(lines) > 0 {
+		details += fmt.Sprintf("Latest log lines:\n\n```\n%s\n```\n", strings.ReplaceAll(strings.Join(lines, "\n"), "```", "'''"))
+	}
+	if r.shown[job.ID] == summary+details {
+		return nil
+	}
+
+	client, err := r.cc.NewInstallationClient(job.InstallationID)
+	if err != nil {
+		return err
+	}
+	params := util.PullRequestStatusParams{
+		Status:       common.CheckInProgress,
+		CheckName:    util.JobCheckName(job.JobType),
+		CheckSummary: summary,
+		CheckDetails: details,
+		JobType:      job.JobType,
+		JobID:        job.ID,
+		RepoOwner:    job.RepoOwner,
+		RepoName:     job.RepoName,
+		PrNum:        job.PRNumber,
+		PrSha:        job.PRSHA,
+		CheckRunID:   job.CheckRunID,
+	}
+	if _, err := util.PostPullRequestCheck(ctx, client, params); err != nil {
+		return err
+	}
+	r.shown[job.ID] = summary + details
+	return nil
+}
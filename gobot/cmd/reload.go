@@ -0,0 +1,101 @@
+package cmd
+
+import (
+	"context"
+	"crypto/sha256"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/instructlab/instructlab-bot/gobot/util"
+	"go.uber.org/zap"
+)
+
+// reloadedSettings are the settings of the configuration file applied again
+// on a reload. The other settings only change on a restart.
+var reloadedSettings = []string{"maintainers", "required-labels"}
+
+// reloadConfig reads the maintainers, required labels, authorization policy
+// and repositories again, and makes them the active configuration. On error
+// the active configuration is kept.
+func reloadConfig(store *util.RepoStore, logger *zap.SugaredLogger) error {
+	if ConfigFile != "" {
+		if err := configLoader.Reload(settings, ConfigFile, reloadedSettings...); err != nil {
+			return err
+		}
+	}
+	policy, err := loadPolicy(logger)
+	if err != nil {
+		return err
+	}
+	repos, err := loadRepos(logger, policy)
+	if err != nil {
+		return err
+	}
+	if snapshot, changed := store.Replace(repos); changed {
+		logger.Infof("Reloaded configuration version %d (%s)", snapshot.Version, snapshot.Digest)
+	} else {
+		logger.Infof("Configuration version %d (%s) is unchanged", snapshot.Version, snapshot.Digest)
+	}
+	return nil
+}
+
+// watchConfig reloads the configuration on SIGHUP, and when the files it is
+// read from change, checking them every interval. An interval of 0 only
+// reloads on SIGHUP.
+func watchConfig(ctx context.Context, store *util.RepoStore, logger *zap.SugaredLogger, interval time.Duration) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	var tick <-chan time.Time
+	if interval > 0 {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	reload := func(reason string) {
+		logger.Infof("Reloading configuration on %s", reason)
+		if err := reloadConfig(store, logger); err != nil {
+			logger.Errorf("Failed to reload configuration, keeping version %d: %v", store.Active().Version, err)
+		}
+	}
+
+	digest := configDigest()
+	for {
+		select {
+		case <-ctx.Done():
+			logger.Info("Context cancelled, stopping watchConfig")
+			return
+		case <-hup:
+			digest = configDigest()
+			reload("SIGHUP")
+		case <-tick:
+			current := configDigest()
+			if current == digest {
+				continue
+			}
+			digest = current
+			reload("file change")
+		}
+	}
+}
+
+// configDigest returns a hash of the files the configuration is read from. A
+// file that can not be read hashes as empty, so the reload reports the error.
+func configDigest() [sha256.Size]byte {
+	h := sha256.New()
+	for _, path := range []string{ConfigFile, AuthzPolicyFile, ReposConfigFile} {
+		if path == "" {
+			continue
+		}
+		data, _ := os.ReadFile(path)
+		h.Write([]byte(path))
+		h.Write(data)
+	}
+	var sum [sha256.Size]byte
+	h.Sum(sum[:0])
+	return sum
+}
@@ -1,6 +1,7 @@
 package cmd
 
 import (
+	"bytes"
 	"context"
 	"fmt"
 	"net"
@@ -19,36 +20,62 @@ import (
 	"github.com/instructlab/instructlab-bot/gobot/common"
 	"github.com/instructlab/instructlab-bot/gobot/handlers"
 	"github.com/instructlab/instructlab-bot/gobot/util"
+	"github.com/instructlab/instructlab-bot/pkg/authz"
+	"github.com/instructlab/instructlab-bot/pkg/config"
+	"github.com/instructlab/instructlab-bot/pkg/history"
+	"github.com/instructlab/instructlab-bot/pkg/jobs"
+	"github.com/instructlab/instructlab-bot/pkg/secrets"
 	"github.com/palantir/go-githubapp/githubapp"
 	"github.com/rcrowley/go-metrics"
 	"github.com/spf13/cobra"
-	"github.com/spf13/pflag"
-	"github.com/spf13/viper"
 	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"gopkg.in/yaml.v3"
 )
 
 const (
 	JobFailed          = "Command execution failed. Check details."
-	redisQueueResults  = "results"
-	redisQueueArchived = "archived"
+	reapInterval       = 30 * time.Second
+	releaseInterval    = 1 * time.Second
+	resultsWaitTimeout = 5 * time.Second
 )
 
 var (
-	RedisHost           string
-	HTTPAddress         string
-	HTTPPort            int
-	GithubIntegrationID int
-	TaxonomyRepo        string
-	GithubURL           string
-	GithubWebhookSecret string
-	GithubAppPrivateKey string
-	WebhookProxyURL     string
-	GithubUsername      string
-	GithubToken         string
-	RequiredLabels      []string
-	Maintainers         []string
-	BotUsername         string
-	Debug               bool
+	RedisHost            string
+	HTTPAddress          string
+	HTTPPort             int
+	GithubIntegrationID  int
+	TaxonomyRepo         string
+	GithubURL            string
+	GithubWebURL         string
+	GithubGraphQLURL     string
+	GithubWebhookSecret  string
+	GithubAppPrivateKey  string
+	WebhookProxyURL      string
+	GithubUsername       string
+	GithubToken          string
+	APIToken             string
+	RequiredLabels       []string
+	Maintainers          []string
+	BotUsername          string
+	MaxJobAttempts       int
+	QueueBackend         string
+	VisibilityTimeout    time.Duration
+	HistoryDriver        string
+	HistoryDSN           string
+	JobTTL               time.Duration
+	ProgressInterval     time.Duration
+	MaxNumInstructions   int
+	MaxSeed              int
+	MaxFiles             int
+	Models               []string
+	CommentMode          string
+	AuthzPolicyFile      string
+	ReposConfigFile      string
+	ConfigFile           string
+	SecretProvider       string
+	ConfigReloadInterval time.Duration
+	Debug                bool
 )
 
 func init() {
@@ -56,25 +83,51 @@ func init() {
 	rootCmd.PersistentFlags().StringVarP(&HTTPAddress, "http-address", "", "127.0.0.1", "HTTP Address to bind to")
 	rootCmd.PersistentFlags().IntVarP(&HTTPPort, "http-port", "", 8081, "HTTP Port to bind to")
 	rootCmd.PersistentFlags().IntVarP(&GithubIntegrationID, "github-integration-id", "", 0, "The GitHub App Integration ID")
-	rootCmd.PersistentFlags().StringVarP(&TaxonomyRepo, "taxonomy-repo", "", "https://github.com/instructlab/taxonomy.git", "The GitHub repository to use for the taxonomy")
-	rootCmd.PersistentFlags().StringVarP(&GithubURL, "github-url", "", "https://api.github.com/", "The URL of the GitHub instance")
+	rootCmd.PersistentFlags().StringVarP(&TaxonomyRepo, "taxonomy-repo", "", "", "The GitHub repository to use for the taxonomy. If blank, instructlab/taxonomy on --github-web-url")
+	rootCmd.PersistentFlags().StringVarP(&GithubWebURL, "github-web-url", "", util.DefaultWebURL, "The web URL of the GitHub instance, such as https://github.example.com for GitHub Enterprise Server")
+	rootCmd.PersistentFlags().StringVarP(&GithubURL, "github-url", "", "", "The REST API URL of the GitHub instance. If blank, derived from --github-web-url")
+	rootCmd.PersistentFlags().StringVarP(&GithubGraphQLURL, "github-graphql-url", "", "", "The GraphQL API URL of the GitHub instance. If blank, derived from --github-web-url")
 	rootCmd.PersistentFlags().StringVarP(&GithubWebhookSecret, "github-webhook-secret", "", "", "The GitHub App Webhook Secret")
 	rootCmd.PersistentFlags().StringVarP(&GithubAppPrivateKey, "github-app-private-key", "", "", "The GitHub App Private Key")
 	rootCmd.PersistentFlags().StringVarP(&WebhookProxyURL, "webhook-proxy-url", "", "", "Get an ID from https://smee.io/new. If blank, the app will not use a webhook proxy")
 	rootCmd.PersistentFlags().StringVarP(&GithubUsername, "github-username", "u", "instructlab-bot", "The GitHub username to use for authentication")
 	rootCmd.PersistentFlags().StringVarP(&GithubToken, "github-token", "g", "", "The GitHub token to use for authentication")
+	rootCmd.PersistentFlags().StringVarP(&APIToken, "api-token", "", "", "Bearer token required on the job API and the admin endpoints of the HTTP server. If blank, they are refused")
 	rootCmd.PersistentFlags().StringSliceVarP(&RequiredLabels, "required-labels", "", []string{}, "Label(s) required before a PR can be tested")
 	rootCmd.PersistentFlags().StringSliceVarP(&Maintainers, "maintainers", "", []string{}, "GitHub users or groups that are considered maintainers")
 	rootCmd.PersistentFlags().BoolVarP(&Debug, "debug", "d", false, "Enable debug logging")
 	rootCmd.PersistentFlags().StringVarP(&BotUsername, "bot-username", "", "@instructlab-bot", "The username of the bot")
-	if GithubToken == "" {
-		GithubToken = os.Getenv("ILWORKER_GITHUB_TOKEN")
-	}
-	if GithubUsername == "" {
-		GithubUsername = os.Getenv("ILWORKER_GITHUB_USERNAME")
-	}
+	rootCmd.PersistentFlags().IntVarP(&MaxJobAttempts, "max-job-attempts", "", 3, "Times a job abandoned by a dead worker is attempted before it is dead-lettered")
+	rootCmd.PersistentFlags().StringVarP(&QueueBackend, "queue-backend", "", jobs.BackendLists, "Transport used to exchange jobs with the workers: 'lists' or 'streams'. Must match the workers")
+	rootCmd.PersistentFlags().DurationVarP(&VisibilityTimeout, "visibility-timeout", "", 60*time.Second, "How long a job of the streams backend may go without a worker heartbeat before it is reclaimed")
+	rootCmd.PersistentFlags().StringVarP(&HistoryDriver, "history-driver", "", history.DriverSQLite, "Database used to keep the history of finished jobs: 'sqlite' or 'postgres'")
+	rootCmd.PersistentFlags().StringVarP(&HistoryDSN, "history-dsn", "", "", "Data source of the job history database, a file path for sqlite. If blank, finished jobs are kept in Redis and not expired")
+	rootCmd.PersistentFlags().DurationVarP(&ProgressInterval, "progress-interval", "", 30*time.Second, "How often the check runs of the running jobs are updated with their progress. 0 disables the updates")
+	rootCmd.PersistentFlags().IntVarP(&MaxNumInstructions, "max-num-instructions", "", 100, "Highest --num-instructions accepted on a job command")
+	rootCmd.PersistentFlags().IntVarP(&MaxSeed, "max-seed", "", 100, "Highest --max-seed accepted on a job command")
+	rootCmd.PersistentFlags().IntVarP(&MaxFiles, "max-files", "", 10, "Most files accepted with --files on a job command")
+	rootCmd.PersistentFlags().StringSliceVarP(&Models, "models", "", []string{}, "Models a job command can select with --model. If empty, --model is refused")
+	rootCmd.PersistentFlags().StringVarP(&CommentMode, "comment-mode", "", handlers.CommentModeComments, "How the bot acknowledges commands: 'reactions' to the comment, reply 'comments' or 'both'")
+	rootCmd.PersistentFlags().StringVarP(&AuthzPolicyFile, "authz-policy", "", "", "YAML file of the rules deciding who may run each command. If blank, every command is allowed to the --maintainers teams, or to the users with write permission without teams")
+	rootCmd.PersistentFlags().StringVarP(&ReposConfigFile, "repos-config", "", "", "YAML file of the repositories the bot serves, with their git remote, base branch, required labels, maintainers, enabled commands and policy. If blank, only the taxonomy repository is served, with --required-labels and --maintainers")
+	rootCmd.PersistentFlags().DurationVarP(&JobTTL, "job-ttl", "", 7*24*time.Hour, "How long a finished job recorded in the history database is kept in Redis")
+	rootCmd.PersistentFlags().DurationVarP(&ConfigReloadInterval, "config-reload-interval", "", 10*time.Second, "How often the --config, --authz-policy and --repos-config files are checked for changes to the maintainers, required labels, policies and repositories. 0 only reloads them on SIGHUP")
+	rootCmd.PersistentFlags().StringVarP(&ConfigFile, config.FlagName, "", "", "YAML file of settings keyed by the flag names, such as bot.yaml. Flags win over ILBOT_* environment variables, which win over the file")
+	rootCmd.PersistentFlags().StringVarP(&SecretProvider, "secret-provider", "", "", "Where to read the secrets left empty: file:<dir> reads <dir>/<flag name>, env:<prefix> reads <PREFIX>_<FLAG_NAME>, vault:<url> reads the fields of a Vault KV secret with the token in VAULT_TOKEN")
+	config.MarkSecret(rootCmd.PersistentFlags(), "github-webhook-secret", "github-app-private-key", "github-token", "history-dsn", "api-token")
+	config.AddSecretFileFlags(rootCmd.PersistentFlags())
+}
+
+// configLoader reads the settings of the bot from the ILBOT_* environment
+// variables. The GitHub token can also come from the variable of the worker.
+var configLoader = config.Loader{
+	EnvPrefix:  "ILBOT",
+	EnvAliases: map[string]string{"github-token": "ILWORKER_GITHUB_TOKEN"},
 }
 
+// settings are the loaded settings of the bot, along with their sources.
+var settings *config.Settings
+
 var rootCmd = &cobra.Command{
 	Use:   "bot",
 	Short: "Bot receives events from GitHub and processes them",
@@ -88,14 +141,66 @@ var rootCmd = &cobra.Command{
 	},
 }
 
+// botConfig holds the settings of the bot that are read from other files or
+// derived from the flags.
+type botConfig struct {
+	policy     *authz.Policy
+	repos      util.Repos
+	githubURLs util.GitHubURLs
+}
+
+// loadBotConfig checks the settings of the bot and loads the files they name.
+func loadBotConfig(logger *zap.SugaredLogger) (*botConfig, error) {
+	if err := handlers.ValidateCommentMode(CommentMode); err != nil {
+		return nil, err
+	}
+	if err := jobs.ValidateBackend(QueueBackend); err != nil {
+		return nil, err
+	}
+	if err := history.ValidateDriver(HistoryDriver); err != nil {
+		return nil, err
+	}
+	policy, err := loadPolicy(logger)
+	if err != nil {
+		return nil, err
+	}
+	repos, err := loadRepos(logger, policy)
+	if err != nil {
+		return nil, err
+	}
+	githubURLs, err := util.NewGitHubURLs(GithubWebURL, GithubURL, GithubGraphQLURL)
+	if err != nil {
+		return nil, err
+	}
+	return &botConfig{policy: policy, repos: repos, githubURLs: githubURLs}, nil
+}
+
 func run(logger *zap.SugaredLogger) error {
 	logger.Info("Starting bot...")
+	if ConfigFile != "" {
+		logger.Infof("Loaded settings from %s", ConfigFile)
+	}
+	botCfg, err := loadBotConfig(logger)
+	if err != nil {
+		return err
+	}
+	repos := util.NewRepoStore(botCfg.repos)
+	githubURLs := botCfg.githubURLs
+	util.WebURL = githubURLs.Web
+	if TaxonomyRepo == "" {
+		TaxonomyRepo = util.RepoGitURL("instructlab", common.RepoName)
+	}
+	logger.Infof("Using GitHub at %s (REST API %s, GraphQL API %s)", githubURLs.Web, githubURLs.V3API, githubURLs.V4API)
+
 	metricsRegistry := metrics.DefaultRegistry
-	// Replace all instances of \n with actual newlines
+	// Replace all instances of \n with actual newlines, for a key given on a
+	// single line. A key read from --github-app-private-key-file has them.
 	GithubAppPrivateKey = strings.ReplaceAll(GithubAppPrivateKey, "\\n", "\n")
+	secretRedactor.Add(GithubAppPrivateKey)
 
 	ghConfig := githubapp.Config{
-		V3APIURL: GithubURL,
+		V3APIURL: githubURLs.V3API,
+		V4APIURL: githubURLs.V4API,
 		App: struct {
 			IntegrationID int64  `yaml:"integration_id" json:"integrationId"`
 			WebhookSecret string `yaml:"webhook_secret" json:"webhookSecret"`
@@ -120,21 +225,67 @@ func run(logger *zap.SugaredLogger) error {
 		return err
 	}
 
+	r := redis.NewClient(&redis.Options{
+		Addr:     RedisHost,
+		Password: "", // no password set
+		DB:       0,  // use default DB
+	})
+	defer r.Close()
+
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGTERM, syscall.SIGQUIT, syscall.SIGINT)
+	defer cancel()
+
+	dispatcher, err := jobs.NewDispatcher(ctx, QueueBackend, r, jobs.DispatcherOptions{
+		MaxAttempts:       MaxJobAttempts,
+		VisibilityTimeout: VisibilityTimeout,
+	})
+	if err != nil {
+		return err
+	}
+	store := jobs.NewRedisStore(r)
+	scheduler := jobs.NewScheduler(r, dispatcher)
+
+	var historyStore history.Store
+	var archiver *history.Archiver
+	if HistoryDSN != "" {
+		sqlStore, err := history.Open(ctx, HistoryDriver, HistoryDSN)
+		if err != nil {
+			return err
+		}
+		defer sqlStore.Close()
+		historyStore = sqlStore
+		archiver = history.NewArchiver(store, sqlStore, JobTTL)
+	} else {
+		logger.Warn("No job history database configured, finished jobs are kept in Redis")
+	}
+
+	argLimits := handlers.JobArgLimits{
+		MaxNumInstructions: MaxNumInstructions,
+		MaxSeed:            MaxSeed,
+		MaxFiles:           MaxFiles,
+		Models:             Models,
+	}
+
 	prCommentHandler := &handlers.PRCommentHandler{
-		ClientCreator:  cc,
-		Logger:         logger,
-		RedisHostPort:  RedisHost,
-		RequiredLabels: RequiredLabels,
-		BotUsername:    BotUsername,
-		Maintainers:    Maintainers,
+		ClientCreator: cc,
+		Logger:        logger,
+		JobStore:      store,
+		Archiver:      archiver,
+		Scheduler:     scheduler,
+		Dedup:         jobs.NewDedupIndex(r),
+		ArgLimits:     argLimits,
+		CommentMode:   CommentMode,
+		Repos:         repos,
+		BotUsername:   BotUsername,
 	}
 
 	prHandler := &handlers.PullRequestEventHandler{
-		ClientCreator:  cc,
-		Logger:         logger,
-		RequiredLabels: RequiredLabels,
-		BotUsername:    BotUsername,
-		Maintainers:    Maintainers,
+		ClientCreator: cc,
+		Logger:        logger,
+		JobStore:      store,
+		Archiver:      archiver,
+		Repos:         repos,
+		BotUsername:   BotUsername,
 	}
 
 	prCreateHandler := &handlers.PullRequestCreateHandler{
@@ -145,16 +296,20 @@ func run(logger *zap.SugaredLogger) error {
 		GithubToken:    GithubToken,
 	}
 
-	webhookHandler := githubapp.NewDefaultEventDispatcher(ghConfig, prCommentHandler, prHandler)
+	checkRunHandler := &handlers.CheckRunEventHandler{
+		ClientCreator: cc,
+		Logger:        logger,
+		Comments:      prCommentHandler,
+		History:       historyStore,
+	}
+
+	webhookHandler := githubapp.NewDefaultEventDispatcher(ghConfig, prCommentHandler, prHandler, checkRunHandler)
 
 	http.Handle(githubapp.DefaultWebhookRoute, webhookHandler)
 
 	//addr := net.JoinHostPort(HTTPAddress, strconv.Itoa(HTTPPort))
 	addr := net.JoinHostPort("", strconv.Itoa(HTTPPort))
 
-	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGTERM, syscall.SIGQUIT, syscall.SIGINT)
-	defer cancel()
-
 	wg := sync.WaitGroup{}
 	if WebhookProxyURL != "" {
 		args := []string{
@@ -183,6 +338,20 @@ func run(logger *zap.SugaredLogger) error {
 	httpServer := &http.Server{Addr: addr}
 	http.HandleFunc("/pr/skill", prCreateHandler.SkillPRHandler)
 	http.HandleFunc("/pr/knowledge", prCreateHandler.KnowledgePRHandler)
+	jobsAPIHandler := &handlers.JobsAPIHandler{
+		Logger:   logger,
+		JobStore: store,
+		Events:   store,
+		History:  historyStore,
+	}
+	jobsAPI := handlers.RequireToken(APIToken, jobsAPIHandler)
+	http.Handle(handlers.JobsAPIRoute, jobsAPI)
+	http.Handle(handlers.JobsAPIRoute+"/", jobsAPI)
+	http.Handle(handlers.PRsAPIRoute+"/", jobsAPI)
+	if APIToken == "" {
+		logger.Warnf("No --api-token set, the job API and %s are refused", handlers.AdminConfigRoute)
+	}
+	http.Handle(handlers.AdminConfigRoute, handlers.RequireToken(APIToken, &handlers.AdminConfigHandler{Logger: logger, Repos: repos}))
 
 	go func() {
 		logger.Infof("Starting server on %s...", addr)
@@ -193,9 +362,31 @@ func run(logger *zap.SugaredLogger) error {
 	}()
 	wg.Add(1)
 	go func() {
-		receiveResults(ctx, RedisHost, logger, cc)
+		receiveResults(ctx, store, archiver, dispatcher, logger, cc)
+		wg.Done()
+	}()
+	wg.Add(1)
+	go func() {
+		reapJobs(ctx, dispatcher, logger)
+		wg.Done()
+	}()
+	wg.Add(1)
+	go func() {
+		releaseJobs(ctx, scheduler, logger)
+		wg.Done()
+	}()
+	wg.Add(1)
+	go func() {
+		watchConfig(ctx, repos, logger, ConfigReloadInterval)
 		wg.Done()
 	}()
+	if ProgressInterval > 0 {
+		wg.Add(1)
+		go func() {
+			reportProgress(ctx, store, cc, logger, ProgressInterval)
+			wg.Done()
+		}()
+	}
 
 	<-ctx.Done()
 
@@ -230,277 +421,267 @@ func initLogger(debug bool) *zap.Logger {
 		OutputPaths:      []string{"stderr"},
 		ErrorOutputPaths: []string{"stderr"},
 	}
-	logger, _ := loggerConfig.Build()
+	logger, _ := loggerConfig.Build(zap.WrapCore(func(core zapcore.Core) zapcore.Core {
+		return secrets.RedactCore(core, secretRedactor)
+	}))
 	return logger
 }
 
+// initializeConfig applies the environment and the configuration file to the
+// settings of the bot not given on the command line.
 func initializeConfig(cmd *cobra.Command) error {
-	v := viper.New()
-	v.SetEnvPrefix("ILBOT")
-	v.SetEnvKeyReplacer(strings.NewReplacer("-", "_"))
-	v.AutomaticEnv()
-	bindFlags(cmd, v)
-	return nil
-}
-
-func bindFlags(cmd *cobra.Command, v *viper.Viper) {
-	cmd.Flags().VisitAll(func(f *pflag.Flag) {
-		configName := f.Name
-		if !f.Changed && v.IsSet(configName) {
-			val := v.Get(configName)
-			_ = cmd.Flags().Set(f.Name, fmt.Sprintf("%v", val))
-		}
-	})
+	var err error
+	settings, err = configLoader.Load(cmd.Root().PersistentFlags(), ConfigFile)
+	if err != nil {
+		return err
+	}
+	return resolveSecrets(cmd.Context())
 }
 
-func receiveResults(ctx context.Context, redisHostPort string, logger *zap.SugaredLogger, cc githubapp.ClientCreator) {
-	r := redis.NewClient(&redis.Options{
-		Addr:     redisHostPort,
-		Password: "", // no password set
-		DB:       0,  // use default DB
-	})
-
+func receiveResults(ctx context.Context, store jobs.JobStore, archiver *history.Archiver, dispatcher jobs.Dispatcher, logger *zap.SugaredLogger, cc githubapp.ClientCreator) {
 	for {
 		select {
 		case <-ctx.Done():
 			logger.Info("Context cancelled, stopping receiveResults")
 			return
 		default:
-			count, err := r.LLen(ctx, redisQueueResults).Result()
-			if err != nil {
-				logger.Errorf("Redis Client Error: %v", err)
-				continue
-			}
-			if count == 0 {
-				logger.Debugf("No jobs in results, sleeping for 5 seconds")
-				time.Sleep(5 * time.Second)
-				continue
-			}
-
-			// Use LMove to move the job from "results" to the "archived" list
-			result, err := r.LMove(ctx, redisQueueResults, redisQueueArchived, "RIGHT", "LEFT").Result()
+			// Block until a result arrives
+			delivery, err := dispatcher.NextResult(ctx, resultsWaitTimeout)
 			if err != nil {
-				logger.Errorf("Redis Client Error during LMove: %v", err)
+				if ctx.Err() == nil {
+					logger.Errorf("Redis Client Error while waiting for results: %v", err)
+					sleepContext(ctx, resultsWaitTimeout)
+				}
 				continue
 			}
-
-			if result == "" {
-				logger.Info("No job moved, possibly due to an empty 'results' list")
+			if delivery == nil {
 				continue
 			}
+			result := delivery.JobID
+			logger.Debugf("Received the result of job %s", result)
 
-			// Log that the job has been archived successfully
-			logger.Debugf("Job %s moved to archive queue successfully.", result)
-
-			prNumber, err := r.Get(ctx, buildRedisKey(result, common.RedisKeyPRNumber)).Result()
-			if err != nil || prNumber == "" {
-				logger.Errorf("No PR number found for job %s", result)
-				continue
+			handleResult(ctx, store, archiver, logger, cc, result)
+			if err := delivery.Ack(ctx); err != nil {
+				logger.Errorf("Failed to acknowledge the result of job %s: %v", result, err)
 			}
+		}
+	}
+}
 
-			installID, err := r.Get(ctx, buildRedisKey(result, common.RedisKeyInstallationID)).Result()
-			if err != nil || installID == "" {
-				logger.Errorf("No installation ID found for job %s", result)
-				continue
-			}
-			installIDInt, err := strconv.Atoi(installID)
-			if err != nil {
-				logger.Errorf("Error converting installation ID to int: %v", err)
-				continue
-			}
+// handleResult reports the outcome of a finished job on its pull request, then
+// archives the job.
+func handleResult(ctx context.Context, store jobs.JobStore, archiver *history.Archiver, logger *zap.SugaredLogger, cc githubapp.ClientCreator, result string) {
+	job, err := store.Get(ctx, result)
+	if err != nil {
+		logger.Errorf("Failed to read job %s: %v", result, err)
+		return
+	}
+	defer func() {
+		if err := archiver.Archive(ctx, result); err != nil {
+			logger.Errorf("Failed to archive job %s: %v", result, err)
+		}
+	}()
+	if job.InstallationID == 0 || job.RepoOwner == "" || job.RepoName == "" || job.PRSHA == "" {
+		logger.Errorf("Incomplete job details found for job %s", result)
+		return
+	}
+	jobType := job.JobType
+	prNum := job.PRNumber
+	totalTime := time.Since(job.RequestTime).Round(time.Second)
 
-			repoOwner, err := r.Get(ctx, buildRedisKey(result, common.RedisKeyRepoOwner)).Result()
-			if err != nil || repoOwner == "" {
-				logger.Errorf("No repo owner found for job %s", result)
-				continue
-			}
+	prURL := util.PullRequestURL(job.RepoOwner, job.RepoName, prNum)
 
-			repoName, err := r.Get(ctx, buildRedisKey(result, common.RedisKeyRepoName)).Result()
-			if err != nil || repoName == "" {
-				logger.Errorf("No repo name found for job %s", result)
-				continue
-			}
+	if job.Duration == 0 {
+		logger.Infof("Job result for %s/%s#%d, job ID: %s, GitHub URL: %s (No job duration time found for job)", job.RepoOwner, job.RepoName, prNum, result, prURL)
+	} else {
+		queueTime := totalTime - job.Duration
+		logger.Infof("Job result for %s/%s#%d, job ID: %s, job duration: %s, queue time: %s URL: %s", job.RepoOwner, job.RepoName, prNum, result, job.Duration, queueTime, prURL)
+	}
 
-			jobType, err := r.Get(ctx, buildRedisKey(result, common.RedisKeyJobType)).Result()
-			if err != nil || jobType == "" {
-				logger.Errorf("No job type found for job %s", result)
-				continue
-			}
+	statusContext := util.JobCheckName(jobType)
+	if statusContext == "" {
+		logger.Errorf("Unknown job type: %s", jobType)
+	}
 
-			prSha, err := r.Get(ctx, buildRedisKey(result, common.RedisKeyPRSHA)).Result()
-			if err != nil || prSha == "" {
-				logger.Errorf("No PR SHA found for job %s", result)
-				continue
-			}
+	client, err := cc.NewInstallationClient(job.InstallationID)
+	if err != nil {
+		logger.Errorf("Failed to create installation client: %v", err)
+		return
+	}
 
-			requestTimeStr, err := r.Get(ctx, buildRedisKey(result, common.RedisKeyRequestTime)).Result()
-			if err != nil || requestTimeStr == "" {
-				logger.Errorf("No request time found for job %s", result)
-				continue
-			}
-			requestTime, err := strconv.ParseInt(requestTimeStr, 10, 64)
-			if err != nil {
-				logger.Errorf("Error parsing request time for job %s: %v", result, err)
-				continue
-			}
-			totalTime := time.Now().Unix() - requestTime
+	if job.Status == jobs.StatusCancelled || job.Status == jobs.StatusSuperseded {
+		logger.Infof("Job %s on %s/%s#%d was cancelled: %s", result, job.RepoOwner, job.RepoName, prNum, job.CancelReason)
+		if err := util.PostJobCancelledCheck(ctx, client, job); err != nil {
+			logger.Errorf("Failed to post cancelled check for job %s: %v", result, err)
+		}
+		return
+	}
 
-			prURL := fmt.Sprintf("https://github.com/%s/%s/pull/%s", repoOwner, repoName, prNumber)
+	// check for errors prior to checking for an S3 url and models since that will not get produced on a failure
+	prErrors := job.Errors
+	if prErrors != "" {
+		errCommentBody := fmt.Sprintf("An error occurred while processing your request, please review the following log for job id %s :\n\n```\n%s\n```", result, prErrors)
+
+		params := util.PullRequestStatusParams{
+			Status:       common.CheckComplete,
+			Conclusion:   common.CheckStatusFailure,
+			CheckName:    statusContext,
+			CheckSummary: JobFailed,
+			CheckDetails: errCommentBody,
+			Comment:      errCommentBody,
+			JobType:      jobType,
+			JobID:        result,
+			JobErr:       errCommentBody,
+			RepoOwner:    job.RepoOwner,
+			RepoName:     job.RepoName,
+			PrNum:        prNum,
+			PrSha:        job.PRSHA,
+			CheckRunID:   job.CheckRunID,
+		}
 
-			jobDuration, err := r.Get(ctx, buildRedisKey(result, common.RedisKeyDuration)).Result()
+		logger.Errorf("Error processing command on %s/%s#%d: err %s",
+			params.RepoOwner, params.RepoName, params.PrNum, params.JobErr)
 
-			if err != nil || jobDuration == "" {
-				logger.Infof("Job result for %s/%s#%s, job ID: %s, GitHub URL: %s (No job duration time found for job)", repoOwner, repoName, prNumber, result, prURL)
-			} else {
+		_, err = util.PostPullRequestCheck(ctx, client, params)
+		if err != nil {
+			logger.Errorf("Failed to update error message on PR for job %s error: %v", result, err)
+		}
+		return
+	}
 
-				jobDurationInt, err := strconv.ParseInt(jobDuration, 10, 64)
-				if err != nil {
-					logger.Errorf("Error parsing duration time for job %s: %v", result, err)
-					continue
-				}
-				queueTime := totalTime - jobDurationInt
-				logger.Infof("Job result for %s/%s#%s, job ID: %s, job duration: %s, queue time: %d URL: %s", repoOwner, repoName, prNumber, result, jobDuration, queueTime, prURL)
-			}
+	if job.NoChanges() {
+		logger.Infof("Job %s on %s/%s#%d found no taxonomy changes", result, job.RepoOwner, job.RepoName, prNum)
+		if err := util.PostJobNoChangesCheck(ctx, client, job); err != nil {
+			logger.Errorf("Failed to post no changes check for job %s: %v", result, err)
+		}
+		return
+	}
+	if job.ModelName == "" || job.ModelName == "unknown" {
+		logger.Infof("No specific model name found for job %s, using generic message.", result)
+	}
 
-			var statusContext string
-			switch jobType {
-			case "generate":
-				statusContext = common.GenerateLocalCheck
-			case "precheck":
-				statusContext = common.PrecheckCheck
-			case "sdg-svc":
-				statusContext = common.GenerateSDGCheck
-			default:
-				logger.Errorf("Unknown job type: %s", jobType)
-			}
+	if err := util.PostJobResults(ctx, client, job, ""); err != nil {
+		logger.Errorf("Failed to post results on pr %s/%s#%d: %v", job.RepoOwner, job.RepoName, prNum, err)
+	}
+}
 
-			client, err := cc.NewInstallationClient(int64(installIDInt))
-			if err != nil {
-				logger.Errorf("Failed to create installation client: %v", err)
-				continue
-			}
+// sleepContext waits for the given duration or until the context is cancelled.
+func sleepContext(ctx context.Context, d time.Duration) {
+	select {
+	case <-ctx.Done():
+	case <-time.After(d):
+	}
+}
 
-			prNum, err := strconv.Atoi(prNumber)
+// reapJobs periodically requeues the jobs abandoned by dead workers.
+func reapJobs(ctx context.Context, dispatcher jobs.Dispatcher, logger *zap.SugaredLogger) {
+	ticker := time.NewTicker(reapInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			logger.Info("Context cancelled, stopping reapJobs")
+			return
+		case <-ticker.C:
+			reaped, err := dispatcher.Recover(ctx)
 			if err != nil {
-				logger.Errorf("error converting string to int: %v", err)
-				continue
+				logger.Errorf("Failed to reap abandoned jobs: %v", err)
 			}
-
-			// check for errors prior to checking for an S3 url and models since that will not get produced on a failure
-			prErrors, _ := r.Get(ctx, "jobs:"+result+":errors").Result()
-			if prErrors != "" {
-				errCommentBody := fmt.Sprintf("An error occurred while processing your request, please review the following log for job id %s :\n\n```\n%s\n```", result, prErrors)
-
-				params := util.PullRequestStatusParams{
-					Status:       common.CheckComplete,
-					Conclusion:   common.CheckStatusFailure,
-					CheckName:    statusContext,
-					CheckSummary: JobFailed,
-					CheckDetails: errCommentBody,
-					Comment:      errCommentBody,
-					JobType:      jobType,
-					JobID:        result,
-					JobErr:       errCommentBody,
-					RepoOwner:    repoOwner,
-					RepoName:     repoName,
-					PrNum:        prNum,
-					PrSha:        prSha,
-				}
-
-				logger.Errorf("Error processing command on %s/%s#%d: err %s",
-					params.RepoOwner, params.RepoName, params.PrNum, params.JobErr)
-
-				err = util.PostPullRequestCheck(ctx, client, params)
-				if err != nil {
-					logger.Errorf("Failed to update error message on PR for job %s error: %v", result, err)
+			for _, job := range reaped {
+				switch {
+				case job.DeadLettered:
+					logger.Warnf("Job %s abandoned by worker %s moved to the dead-letter queue after %d attempts", job.JobID, job.WorkerID, job.Attempts)
+				case job.Finished:
+					logger.Infof("Job %s left by worker %s had already finished, dropped", job.JobID, job.WorkerID)
+				default:
+					logger.Infof("Job %s abandoned by worker %s requeued after %d attempts", job.JobID, job.WorkerID, job.Attempts)
 				}
-
-				// Enable redis keys deletion once we have solution for persisting the job history
-				// cleanupRedisKeys(logger, r, result)
-				continue
-			}
-
-			s3Url, err := r.Get(ctx, "jobs:"+result+":s3_url").Result()
-			if err != nil || s3Url == "" {
-				logger.Errorf("No S3 URL found for job %s", result)
-				continue
-			}
-
-			modelName, err := r.Get(ctx, "jobs:"+result+":model_name").Result()
-			if err != nil || modelName == "" || modelName == "unknown" {
-				logger.Infof("No specific model name found for job %s, using generic message.", result)
-				modelName = ""
-			} else {
-				modelName = "using the model " + modelName
-			}
-
-			// Add the model name only if it's not empty
-			detailsMsg := fmt.Sprintf("Beep, boop 🤖, Here are the %s results for your PR", jobType)
-			if modelName != "" {
-				detailsMsg += " " + modelName
-			}
-			detailsMsg += fmt.Sprintf("!\n\nResults can be found [here](%s).", s3Url)
-
-			summaryMsg := fmt.Sprintf("Job ID: %s completed successfully. Check Details.", result)
-
-			params := util.PullRequestStatusParams{
-				Status:       common.CheckComplete,
-				Conclusion:   common.CheckStatusSuccess,
-				JobID:        result,
-				JobType:      jobType,
-				CheckName:    statusContext,
-				CheckSummary: summaryMsg,
-				CheckDetails: detailsMsg,
-				Comment:      detailsMsg,
-				RepoOwner:    repoOwner,
-				RepoName:     repoName,
-				PrNum:        prNum,
-				PrSha:        prSha,
 			}
+		}
+	}
+}
 
-			err = util.PostPullRequestCheck(ctx, client, params)
+// releaseJobs periodically hands the next scheduled jobs to the workers.
+func releaseJobs(ctx context.Context, scheduler *jobs.Scheduler, logger *zap.SugaredLogger) {
+	ticker := time.NewTicker(releaseInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			logger.Info("Context cancelled, stopping releaseJobs")
+			return
+		case <-ticker.C:
+			released, err := scheduler.Release(ctx)
 			if err != nil {
-				logger.Errorf("Failed to post check on pr %s/%s#%d: %v", params.RepoOwner, params.RepoName, params.PrNum, err)
+				logger.Errorf("Failed to release scheduled jobs: %v", err)
 			}
-
-			err = util.PostPullRequestComment(ctx, client, params)
-			if err != nil {
-				logger.Errorf("Failed to post comment on pr %s/%s#%d: %v", params.RepoOwner, params.RepoName, params.PrNum, err)
+			for _, id := range released {
+				logger.Debugf("Released job %s to the workers", id)
 			}
-			// Enable redis keys deletion once we have solution for persisting the job history
-			// cleanupRedisKeys(logger, r, result)
 		}
 	}
 }
 
-// buildRedisKey constructs a Redis key for job attributes.
-func buildRedisKey(jobID, keyType string) string {
-	return fmt.Sprintf("%s:%s:%s", common.RedisKeyJobs, jobID, keyType)
-}
+// loadPolicy reads the authorization policy from --authz-policy, or derives
+// it from --maintainers.
+func loadPolicy(logger *zap.SugaredLogger) (*authz.Policy, error) {
+	if AuthzPolicyFile == "" {
+		if len(Maintainers) == 0 {
+			logger.Warn("No maintainers configured, commands are allowed to the users with write permission on the repository")
+		}
+		return authz.TeamsPolicy(Maintainers), nil
+	}
 
-//lint:ignore U1000
-func cleanupRedisKeys(ctx context.Context, logger *zap.SugaredLogger, r *redis.Client, jobID string) {
-	matchKey := fmt.Sprintf("%s:%s:*", common.RedisKeyJobs, jobID)
-	var cursor uint64 = 0
+	data, err := os.ReadFile(AuthzPolicyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read authorization policy: %w", err)
+	}
+	var policy authz.Policy
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&policy); err != nil {
+		return nil, fmt.Errorf("failed to parse authorization policy %s: %w", AuthzPolicyFile, err)
+	}
+	if err := policy.Validate(util.AuthorizedCommands()); err != nil {
+		return nil, fmt.Errorf("invalid authorization policy %s: %w", AuthzPolicyFile, err)
+	}
+	logger.Infof("Loaded %d authorization rules from %s", len(policy.Rules), AuthzPolicyFile)
+	return &policy, nil
+}
 
-	for {
-		keys, nextCursor, err := r.Scan(ctx, cursor, matchKey, 0).Result()
-		if err != nil {
-			logger.Errorf("Error scanning keys matching to %s: %v", matchKey, err)
-			return
-		}
+// reposConfig is the file given with --repos-config.
+type reposConfig struct {
+	Repositories util.Repos `yaml:"repositories"`
+}
 
-		for _, key := range keys {
-			err := r.Del(ctx, key).Err()
-			if err != nil {
-				logger.Warnf("Error deleting key %s: %v", key, err)
-			}
-		}
+// loadRepos reads the repositories the bot serves from --repos-config. Without
+// it, the bot serves the taxonomy repository with the policy given.
+func loadRepos(logger *zap.SugaredLogger, policy *authz.Policy) (util.Repos, error) {
+	if ReposConfigFile == "" {
+		repos := util.Repos{{
+			Name:           common.RepoName,
+			RequiredLabels: RequiredLabels,
+			Maintainers:    Maintainers,
+			Policy:         policy,
+		}}
+		return repos, repos.Prepare(policy)
+	}
 
-		cursor = nextCursor
-		if cursor == 0 {
-			break
-		}
+	data, err := os.ReadFile(ReposConfigFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read repositories config: %w", err)
+	}
+	var file reposConfig
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&file); err != nil {
+		return nil, fmt.Errorf("failed to parse repositories config %s: %w", ReposConfigFile, err)
+	}
+	if err := file.Repositories.Prepare(policy); err != nil {
+		return nil, fmt.Errorf("invalid repositories config %s: %w", ReposConfigFile, err)
+	}
+	for _, repo := range file.Repositories {
+		logger.Infof("Serving repository %s, base branch %s", repo, repo.BaseBranch)
 	}
-	logger.Infof("Deleted all keys matching to %s", matchKey)
+	return file.Repositories, nil
 }
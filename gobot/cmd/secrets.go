@@ -0,0 +1,24 @@
+package cmd
+
+import (
+	"context"
+
+	"github.com/instructlab/instructlab-bot/pkg/secrets"
+)
+
+// secretRedactor redacts the secrets of the bot from its logs.
+var secretRedactor = secrets.NewRedactor()
+
+// resolveSecrets reads the secrets from their files or from --secret-provider,
+// and registers them for redaction.
+func resolveSecrets(ctx context.Context) error {
+	provider, err := secrets.Open(SecretProvider)
+	if err != nil {
+		return err
+	}
+	if err := configLoader.ResolveSecrets(ctx, settings, provider); err != nil {
+		return err
+	}
+	secretRedactor.Add(settings.Secrets()...)
+	return nil
+}
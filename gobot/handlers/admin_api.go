@@ -0,0 +1,30 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/instructlab/instructlab-bot/gobot/util"
+	"go.uber.org/zap"
+)
+
+// AdminConfigRoute serves the active configuration of the repositories, so
+// operators can check which version a reload left in place.
+const AdminConfigRoute = "/admin/config"
+
+// AdminConfigHandler serves AdminConfigRoute.
+type AdminConfigHandler struct {
+	Logger *zap.SugaredLogger
+	Repos  *util.RepoStore
+}
+
+func (h *AdminConfigHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(h.Repos.Active()); err != nil {
+		h.Logger.Warnf("Failed to write admin config response: %v", err)
+	}
+}
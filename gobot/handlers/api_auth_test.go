@@ -0,0 +1,62 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/instructlab/instructlab-bot/gobot/util"
+	"go.uber.org/zap"
+)
+
+func TestAdminConfigRequiresToken(t *testing.T) {
+	admin := &AdminConfigHandler{
+		Logger: zap.NewNop().Sugar(),
+		Repos:  util.NewRepoStore(util.Repos{{Owner: "acme", Name: "taxonomy"}}),
+	}
+	tests := []struct {
+		name          string
+		token         string
+		authorization string
+		want          int
+	}{
+		{"no token configured", "", "Bearer s3cret", http.StatusForbidden},
+		{"unauthenticated", "s3cret", "", http.StatusUnauthorized},
+		{"wrong token", "s3cret", "Bearer guess", http.StatusUnauthorized},
+		{"not a bearer token", "s3cret", "Basic s3cret", http.StatusUnauthorized},
+		{"authenticated", "s3cret", "Bearer s3cret", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, AdminConfigRoute, nil)
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+			rec := httptest.NewRecorder()
+			RequireToken(tt.token, admin).ServeHTTP(rec, req)
+			if rec.Code != tt.want {
+				t.Errorf("GET %s = %d, want %d", AdminConfigRoute, rec.Code, tt.want)
+			}
+		})
+	}
+}
+
+func TestJobsAPIRequiresToken(t *testing.T) {
+	// The request is refused before the handler reads the job store
+	jobsAPI := RequireToken("s3cret", &JobsAPIHandler{Logger: zap.NewNop().Sugar()})
+	for _, route := range []string{
+		JobsAPIRoute,
+		JobsAPIRoute + "/1",
+		JobsAPIRoute + "/1/events",
+		PRsAPIRoute + "/acme/taxonomy/7/jobs",
+	} {
+		rec := httptest.NewRecorder()
+		jobsAPI.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, route, nil))
+		if rec.Code != http.StatusUnauthorized {
+			t.Errorf("GET %s = %d, want %d", route, rec.Code, http.StatusUnauthorized)
+		}
+		if rec.Header().Get("WWW-Authenticate") == "" {
+			t.Errorf("GET %s has no WWW-Authenticate header", route)
+		}
+	}
+}
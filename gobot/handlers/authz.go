@@ -0,0 +1,82 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/google/go-github/v61/github"
+	"github.com/instructlab/instructlab-bot/pkg/authz"
+)
+
+// githubDirectory looks up the teams and the repository permissions of the
+// users on GitHub, for the repository of a pull request.
+type githubDirectory struct {
+	client *github.Client
+	org    string
+	owner  string
+	repo   string
+}
+
+func (d *githubDirectory) TeamMember(ctx context.Context, team, user string) (bool, error) {
+	if d.org == "" {
+		return false, nil
+	}
+	membership, resp, err := d.client.Teams.GetTeamMembershipBySlug(ctx, d.org, team, user)
+	if resp != nil && resp.StatusCode == http.StatusNotFound {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return membership.GetState() == "active", nil
+}
+
+func (d *githubDirectory) Permission(ctx context.Context, user string) (string, error) {
+	level, resp, err := d.client.Repositories.GetPermissionLevel(ctx, d.owner, d.repo, user)
+	if resp != nil && resp.StatusCode == http.StatusNotFound {
+		return authz.PermissionNone, nil
+	}
+	if err != nil {
+		return "", err
+	}
+
+	// The permission field folds maintain into write and triage into read,
+	// the finer roles are in the permissions of the user
+	roles := level.GetUser().GetPermissions()
+	switch permission := level.GetPermission(); {
+	case permission == authz.PermissionWrite && roles["maintain"]:
+		return authz.PermissionMaintain, nil
+	case permission == authz.PermissionRead && roles["triage"]:
+		return authz.PermissionTriage, nil
+	default:
+		return permission, nil
+	}
+}
+
+// authorize checks the author of a comment may run a command on its pull
+// request, and logs the decision.
+func (h *PRCommentHandler) authorize(ctx context.Context, client *github.Client, prComment *PRComment, command string) bool {
+	if prComment.repoOrg == "" {
+		h.Logger.Warnf("No organization found in the repository URL")
+	}
+
+	dir := &githubDirectory{
+		client: client,
+		org:    prComment.repoOrg,
+		owner:  prComment.repoOwner,
+		repo:   prComment.repoName,
+	}
+	decision := prComment.repo.Policy.Authorize(ctx, dir, authz.Request{
+		User:     prComment.author,
+		Command:  command,
+		PRAuthor: prComment.prAuthor,
+	})
+	if decision.Allowed {
+		h.Logger.Infof("Allowed %s to run %s on %s/%s#%d: %s", prComment.author, command,
+			prComment.repoOwner, prComment.repoName, prComment.prNum, decision.Reason)
+	} else {
+		h.Logger.Infof("Denied %s to run %s on %s/%s#%d: %s", prComment.author, command,
+			prComment.repoOwner, prComment.repoName, prComment.prNum, decision.Reason)
+	}
+	return decision.Allowed
+}
@@ -0,0 +1,133 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/google/go-github/v61/github"
+	"github.com/instructlab/instructlab-bot/gobot/util"
+	"github.com/instructlab/instructlab-bot/pkg/history"
+	"github.com/instructlab/instructlab-bot/pkg/jobs"
+)
+
+func (h *PRCommentHandler) cancelCommand(ctx context.Context, client *github.Client, prComment *PRComment, args []string) error {
+	h.Logger.Infof("Cancel command received on %s/%s#%d by %s",
+		prComment.repoOwner, prComment.repoName, prComment.prNum, prComment.author)
+
+	params := util.PullRequestStatusParams{
+		RepoOwner: prComment.repoOwner,
+		RepoName:  prComment.repoName,
+		PrNum:     prComment.prNum,
+		PrSha:     prComment.prSha,
+	}
+
+	if !h.authorize(ctx, client, prComment, util.CancelCommand) {
+		h.react(ctx, client, prComment, reactionDenied)
+		if !h.acknowledgeWithComments() {
+			return nil
+		}
+		params.Comment = fmt.Sprintf("User %s is not allowed to run the `cancel` command on this pull request.", prComment.author)
+		return h.postComment(ctx, client, params)
+	}
+	h.react(ctx, client, prComment, reactionAccepted)
+
+	var targets []*jobs.Job
+	if len(args) > 0 {
+		job, err := h.JobStore.Get(ctx, args[0])
+		if err != nil && err != jobs.ErrNotFound {
+			h.Logger.Errorf("Failed to read job %s: %v", args[0], err)
+			return err
+		}
+		if err == jobs.ErrNotFound || !jobOnPR(job, prComment.repoOwner, prComment.repoName, prComment.prNum) {
+			params.Comment = fmt.Sprintf("Beep, boop 🤖, Job %s was not found on this pull request.", args[0])
+			return h.postComment(ctx, client, params)
+		}
+		if !job.Active() {
+			params.Comment = fmt.Sprintf("Beep, boop 🤖, Job %s has already finished.", job.ID)
+			return h.postComment(ctx, client, params)
+		}
+		targets = append(targets, job)
+	} else {
+		prJobs, err := activePRJobs(ctx, h.JobStore, prComment.repoOwner, prComment.repoName, prComment.prNum)
+		if err != nil {
+			h.Logger.Errorf("Failed to list jobs of PR %s/%s#%d: %v", prComment.repoOwner, prComment.repoName, prComment.prNum, err)
+			return err
+		}
+		targets = prJobs
+	}
+
+	if len(targets) == 0 {
+		params.Comment = "Beep, boop 🤖, There are no queued or running jobs to cancel on this pull request."
+		return h.postComment(ctx, client, params)
+	}
+
+	reason := fmt.Sprintf("cancelled by @%s", prComment.author)
+	var cancelled []string
+	for _, job := range targets {
+		if err := cancelJob(ctx, client, h.JobStore, h.Archiver, job, jobs.StatusCancelled, reason); err != nil {
+			h.Logger.Errorf("Failed to cancel job %s: %v", job.ID, err)
+			continue
+		}
+		cancelled = append(cancelled, job.ID)
+	}
+	if len(cancelled) == 0 {
+		params.Comment = "Beep, boop 🤖, Sorry, the jobs could not be cancelled. Please try again later."
+		return h.postComment(ctx, client, params)
+	}
+	params.Comment = fmt.Sprintf("Beep, boop 🤖, Cancelled job(s) %s. Running jobs are stopped by their worker "+
+		"and reported in the pull request status box shortly.", strings.Join(cancelled, ", "))
+	return h.postComment(ctx, client, params)
+}
+
+func (h *PRCommentHandler) postComment(ctx context.Context, client *github.Client, params util.PullRequestStatusParams) error {
+	err := util.PostPullRequestComment(ctx, client, params)
+	if err != nil {
+		h.Logger.Errorf("Failed to post comment on PR %s/%s#%d: %v", params.RepoOwner, params.RepoName, params.PrNum, err)
+		return err
+	}
+	return nil
+}
+
+// cancelJob flags a job for cancellation. A queued job is concluded with the
+// given status and archived right away, a running job is stopped by its
+// worker which then reports it.
+func cancelJob(ctx context.Context, client *github.Client, store jobs.JobStore, archiver *history.Archiver, job *jobs.Job, status jobs.Status, reason string) error {
+	if err := store.Cancel(ctx, job.ID, reason); err != nil {
+		return err
+	}
+	job.CancelReason = reason
+	if job.Status != jobs.StatusPending {
+		return nil
+	}
+
+	// A worker may start the job meanwhile, it then reports the cancellation
+	ended, err := store.Transition(ctx, job.ID, status, jobs.StatusPending)
+	if err != nil || !ended {
+		return err
+	}
+	job.Status = status
+	if err := archiver.Archive(ctx, job.ID); err != nil {
+		return fmt.Errorf("failed to archive job %s: %w", job.ID, err)
+	}
+	return util.PostJobCancelledCheck(ctx, client, job)
+}
+
+// activePRJobs returns the queued and running jobs of a pull request.
+func activePRJobs(ctx context.Context, store jobs.JobStore, repoOwner, repoName string, prNum int) ([]*jobs.Job, error) {
+	all, err := store.ListPR(ctx, repoOwner, repoName, prNum)
+	if err != nil {
+		return nil, err
+	}
+	var prJobs []*jobs.Job
+	for _, job := range all {
+		if job.Active() {
+			prJobs = append(prJobs, job)
+		}
+	}
+	return prJobs, nil
+}
+
+func jobOnPR(job *jobs.Job, repoOwner, repoName string, prNum int) bool {
+	return job.RepoOwner == repoOwner && job.RepoName == repoName && job.PRNumber == prNum
+}
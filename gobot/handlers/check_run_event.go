@@ -0,0 +1,149 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+
+	"github.com/google/go-github/v61/github"
+	"github.com/instructlab/instructlab-bot/gobot/util"
+	"github.com/instructlab/instructlab-bot/pkg/history"
+	"github.com/instructlab/instructlab-bot/pkg/jobs"
+	"github.com/palantir/go-githubapp/githubapp"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+)
+
+// CheckRunEventHandler handles the Re-run requests and the buttons shown on
+// the check run of a job. The actions go through the same checks as the
+// matching PR comment command.
+type CheckRunEventHandler struct {
+	githubapp.ClientCreator
+	Logger   *zap.SugaredLogger
+	Comments *PRCommentHandler
+	// History is used to find finished jobs that are no longer in Redis
+	History history.Store
+}
+
+func (h *CheckRunEventHandler) Handles() []string {
+	return []string{"check_run"}
+}
+
+func (h *CheckRunEventHandler) Handle(ctx context.Context, eventType, deliveryID string, payload []byte) error {
+	var event github.CheckRunEvent
+	if err := json.Unmarshal(payload, &event); err != nil {
+		return errors.Wrap(err, "failed to parse check run event payload")
+	}
+
+	repo := event.GetRepo()
+	repoConfig, ok := h.Comments.Repos.Lookup(repo.GetOwner().GetLogin(), repo.GetName())
+	if !ok {
+		h.Logger.Warnf("Received unexpected event %s from %s/%s repo. Skipping the event.",
+			eventType, repo.GetOwner().GetLogin(), repo.GetName())
+		return nil
+	}
+
+	var action string
+	switch event.GetAction() {
+	case "requested_action":
+		action = event.GetRequestedAction().Identifier
+	case "rerequested":
+		// The Re-run link GitHub shows on the checks of the app
+		action = util.CheckActionRerun
+	default:
+		return nil
+	}
+
+	checkRun := event.GetCheckRun()
+	spec, ok := util.JobTypeByCheckName(checkRun.GetName())
+	if !ok {
+		h.Logger.Debugf("Check run %s is not a job check, ignoring the %s action", checkRun.GetName(), action)
+		return nil
+	}
+
+	prComment := PRComment{
+		repoOwner: repo.GetOwner().GetLogin(),
+		repoName:  repo.GetName(),
+		repoOrg:   event.GetOrg().GetLogin(),
+		author:    event.GetSender().GetLogin(),
+		installID: githubapp.GetInstallationIDFromEvent(&event),
+		prSha:     checkRun.GetHeadSHA(),
+		repo:      repoConfig,
+	}
+
+	// The job gives the PR of the check, as GitHub leaves the pull requests
+	// of a check run out when the PR comes from a fork
+	var job *jobs.Job
+	if jobID := checkRun.GetExternalID(); jobID != "" {
+		var err error
+		job, err = h.findJob(ctx, jobID)
+		if err != nil && err != jobs.ErrNotFound {
+			h.Logger.Errorf("Failed to read job %s: %v", jobID, err)
+			return err
+		}
+	}
+	if job != nil {
+		prComment.prNum = job.PRNumber
+		if jobSpec, ok := util.JobTypeByType(job.JobType); ok {
+			spec = jobSpec
+		}
+	} else if len(checkRun.PullRequests) > 0 {
+		prComment.prNum = checkRun.PullRequests[0].GetNumber()
+	} else {
+		h.Logger.Warnf("No pull request found for check run %d, ignoring the %s action", checkRun.GetID(), action)
+		return nil
+	}
+
+	client, err := h.NewInstallationClient(prComment.installID)
+	if err != nil {
+		return err
+	}
+
+	h.Logger.Infof("Check run action %s received for %s on %s/%s#%d by %s",
+		action, checkRun.GetName(), prComment.repoOwner, prComment.repoName, prComment.prNum, prComment.author)
+
+	pr, _, err := client.PullRequests.Get(ctx, prComment.repoOwner, prComment.repoName, prComment.prNum)
+	if err != nil {
+		h.Logger.Errorf("Failed to get pull request (%s/%s#%d) related to the check run: %v", prComment.repoOwner, prComment.repoName, prComment.prNum, err)
+		return err
+	}
+	prComment.labels = pr.Labels
+	prComment.prAuthor = pr.GetUser().GetLogin()
+
+	switch action {
+	case util.CheckActionCancel:
+		if job == nil {
+			h.Logger.Warnf("No job found for check run %d, nothing to cancel", checkRun.GetID())
+			return nil
+		}
+		return h.Comments.cancelCommand(ctx, client, &prComment, []string{job.ID})
+	case util.CheckActionRerun:
+		// The job is run again on the current head of the PR
+		prComment.prSha = pr.GetHead().GetSHA()
+		prComment.rerun = true
+		if !repoConfig.CommandEnabled(spec.Command) {
+			return h.Comments.disabledCommand(ctx, client, &prComment, spec.Command)
+		}
+
+		// A job is run again with the same options
+		var args []string
+		if job != nil {
+			args = strings.Fields(job.Args)
+		}
+		if err := h.Comments.jobCommand(ctx, client, &prComment, spec, args); err != nil {
+			return err
+		}
+		return h.Comments.postQueuedJobs(ctx, client, &prComment)
+	default:
+		h.Logger.Warnf("Unknown check run action %s for check run %d", action, checkRun.GetID())
+		return nil
+	}
+}
+
+func (h *CheckRunEventHandler) findJob(ctx context.Context, id string) (*jobs.Job, error) {
+	job, err := h.Comments.JobStore.Get(ctx, id)
+	if err != jobs.ErrNotFound || h.History == nil {
+		return job, err
+	}
+	return h.History.Get(ctx, id)
+}
@@ -0,0 +1,197 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/instructlab/instructlab-bot/gobot/common"
+	"github.com/instructlab/instructlab-bot/gobot/util"
+	"github.com/instructlab/instructlab-bot/pkg/jobs"
+)
+
+// newTestCheckRunHandler returns a check run handler of the fake server, and
+// the store of its jobs.
+func newTestCheckRunHandler(t *testing.T, fake *fakeGitHub) (*CheckRunEventHandler, *jobs.RedisStore) {
+	comments, store, _ := newJobsCommentHandler(t, fake)
+	comments.ArgLimits = JobArgLimits{Models: []string{"granite-7b-lab"}}
+	return &CheckRunEventHandler{
+		ClientCreator: comments.ClientCreator,
+		Logger:        comments.Logger,
+		Comments:      comments,
+	}, store
+}
+
+// checkRunPayload returns the event of alice acting on the check run 99 of
+// acme/taxonomy. identifier is the requested action, if any.
+func checkRunPayload(t *testing.T, action, identifier, checkName, externalID string) []byte {
+	event := map[string]any{
+		"action": action,
+		"repository": map[string]any{
+			"name":  "taxonomy",
+			"owner": map[string]any{"login": "acme"},
+		},
+		"check_run": map[string]any{
+			"id":          99,
+			"name":        checkName,
+			"head_sha":    "old456",
+			"external_id": externalID,
+		},
+		"sender":       map[string]any{"login": "alice", "type": "User"},
+		"installation": map[string]any{"id": 42},
+	}
+	if identifier != "" {
+		event["requested_action"] = map[string]any{"identifier": identifier}
+	}
+	payload, err := json.Marshal(event)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return payload
+}
+
+// createFinishedJob records a precheck job of acme/taxonomy#7 run with a
+// model option, finished with its check run 99.
+func createFinishedJob(t *testing.T, store *jobs.RedisStore) *jobs.Job {
+	ctx := context.Background()
+	job := &jobs.Job{
+		PRNumber:  7,
+		PRSHA:     "old456",
+		RepoOwner: "acme",
+		RepoName:  "taxonomy",
+		JobType:   jobs.TypePrecheck,
+		Args:      "--model granite-7b-lab",
+	}
+	id, err := store.Create(ctx, job)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.SetCheckRun(ctx, id, 99); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Complete(ctx, id, jobs.Result{Duration: time.Second, S3URL: "https://results.example.com/index.html"}); err != nil {
+		t.Fatal(err)
+	}
+	return job
+}
+
+func TestCheckRunRerunRequeuesJob(t *testing.T) {
+	ctx := context.Background()
+	fake := newFakeGitHub(t)
+	defer func(web string) { util.WebURL = web }(util.WebURL)
+	util.WebURL = fake.URL
+	h, store := newTestCheckRunHandler(t, fake)
+	previous := createFinishedJob(t, store)
+
+	if err := h.Handle(ctx, "check_run", "delivery", checkRunPayload(t, "rerequested", "", common.PrecheckCheck, previous.ID)); err != nil {
+		t.Fatalf("Handle: %v", err)
+	}
+
+	prJobs, err := store.ListPR(ctx, "acme", "taxonomy", 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(prJobs) != 2 {
+		t.Fatalf("Got %d jobs on the PR, want the previous one and the rerun", len(prJobs))
+	}
+	rerun := prJobs[1]
+	if rerun.JobType != previous.JobType || rerun.Args != previous.Args {
+		t.Errorf("Rerun job is %s %q, want %s %q", rerun.JobType, rerun.Args, previous.JobType, previous.Args)
+	}
+	if rerun.Status != jobs.StatusPending {
+		t.Errorf("Rerun job is %s, want %s", rerun.Status, jobs.StatusPending)
+	}
+	// The job runs again on the current head of the PR
+	if rerun.PRSHA != "abc123" {
+		t.Errorf("Rerun job runs on %s, want the head abc123", rerun.PRSHA)
+	}
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+	if len(fake.unexpected) > 0 {
+		t.Errorf("Unexpected requests to the fake GitHub: %v", fake.unexpected)
+	}
+}
+
+func TestCheckRunCancelAction(t *testing.T) {
+	ctx := context.Background()
+	fake := newFakeGitHub(t)
+	defer func(web string) { util.WebURL = web }(util.WebURL)
+	util.WebURL = fake.URL
+	h, store := newTestCheckRunHandler(t, fake)
+	id, err := store.Create(ctx, &jobs.Job{PRNumber: 7, RepoOwner: "acme", RepoName: "taxonomy", JobType: jobs.TypePrecheck})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.SetCheckRun(ctx, id, 99); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := h.Handle(ctx, "check_run", "delivery", checkRunPayload(t, "requested_action", util.CheckActionCancel, common.PrecheckCheck, id)); err != nil {
+		t.Fatalf("Handle: %v", err)
+	}
+
+	job, err := store.Get(ctx, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job.Status != jobs.StatusCancelled || job.CancelReason != "cancelled by @alice" {
+		t.Errorf("Job is %s (%q), want %s by alice", job.Status, job.CancelReason, jobs.StatusCancelled)
+	}
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+	if !slices.Contains(fake.requests, "PATCH /api/v3/repos/acme/taxonomy/check-runs/99") {
+		t.Errorf("The check run of the job was not updated, got %v", fake.requests)
+	}
+}
+
+func TestCheckRunIgnoredEvents(t *testing.T) {
+	tests := []struct {
+		name       string
+		action     string
+		identifier string
+		checkName  string
+		// unknownJob refers the check run to a job the bot does not know
+		unknownJob bool
+	}{
+		{"unknown job", "rerequested", "", common.PrecheckCheck, true},
+		{"unknown requested action", "requested_action", "frobnicate", common.PrecheckCheck, false},
+		{"check run of another app", "rerequested", "", "lint", false},
+		{"other check run action", "completed", "", common.PrecheckCheck, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			fake := newFakeGitHub(t)
+			defer func(web string) { util.WebURL = web }(util.WebURL)
+			util.WebURL = fake.URL
+			h, store := newTestCheckRunHandler(t, fake)
+			job := createFinishedJob(t, store)
+			externalID := job.ID
+			if tt.unknownJob {
+				externalID = "404"
+			}
+
+			if err := h.Handle(ctx, "check_run", "delivery", checkRunPayload(t, tt.action, tt.identifier, tt.checkName, externalID)); err != nil {
+				t.Fatalf("Handle: %v", err)
+			}
+
+			prJobs, err := store.ListPR(ctx, "acme", "taxonomy", 7)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(prJobs) != 1 || prJobs[0].Status != jobs.StatusSuccess {
+				t.Errorf("Jobs of the PR changed: %+v", prJobs)
+			}
+			fake.mu.Lock()
+			defer fake.mu.Unlock()
+			for _, request := range fake.requests {
+				if strings.HasPrefix(request, "POST /api/v3/repos/") || strings.HasPrefix(request, "PATCH ") {
+					t.Errorf("Unexpected request %s", request)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,127 @@
+package handlers
+
+import (
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/instructlab/instructlab-bot/gobot/util"
+)
+
+// builtinCommands are the commands of the bot that do not queue a job.
+var builtinCommands = []string{"help", "enable", util.CancelCommand}
+
+// botCommand is a command addressed to the bot in a comment.
+type botCommand struct {
+	// mention is the bot username the command was addressed to.
+	mention string
+	// words holds the command and its arguments.
+	words []string
+	// midLine is set when the mention does not start its line, as in
+	// "thanks @instructlab-bot".
+	midLine bool
+}
+
+// codeSpan matches the inline code of a Markdown line.
+var codeSpan = regexp.MustCompile("`+[^`]*`+")
+
+// parseCommands returns the commands addressed to any of the mentions in a
+// comment body, in order. A command is the words following a mention, up to
+// the end of the line or the next mention. A mention starting with a slash is
+// a slash command, only recognized at the start of a line. Mentions inside
+// code blocks, inline code and quoted replies are ignored.
+func parseCommands(body string, mentions ...string) []botCommand {
+	var commands []botCommand
+	var fence string
+	for _, line := range strings.Split(body, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if fence != "" {
+			if strings.HasPrefix(trimmed, fence) {
+				fence = ""
+			}
+			continue
+		}
+		if strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+			fence = trimmed[:3]
+			continue
+		}
+		if strings.HasPrefix(trimmed, ">") {
+			continue
+		}
+
+		var current *botCommand
+		for i, word := range strings.Fields(codeSpan.ReplaceAllString(line, " ")) {
+			if mention, ok := matchMention(word, mentions, i == 0); ok {
+				commands = appendCommand(commands, current)
+				current = &botCommand{mention: mention, midLine: i > 0}
+				continue
+			}
+			if current != nil {
+				current.words = append(current.words, word)
+			}
+		}
+		commands = appendCommand(commands, current)
+	}
+	return commands
+}
+
+// matchMention returns the mention a word matches, allowing for a trailing
+// colon or comma. Slash commands only match the first word of a line.
+func matchMention(word string, mentions []string, first bool) (string, bool) {
+	word = strings.TrimRight(word, ":,")
+	for _, mention := range mentions {
+		if strings.HasPrefix(mention, "/") && !first {
+			continue
+		}
+		if strings.EqualFold(word, mention) {
+			return mention, true
+		}
+	}
+	return "", false
+}
+
+// isCommand reports whether a word names a command of the bot.
+func isCommand(word string) bool {
+	if _, ok := util.JobTypeByCommand(word); ok {
+		return true
+	}
+	return slices.Contains(builtinCommands, word)
+}
+
+// dropConversation removes the mentions in the middle of a line that are not
+// followed by a command, so thanking the bot in a sentence does not get a
+// reply about an unknown command. A mention starting a line is always read
+// as a command.
+func dropConversation(commands []botCommand) []botCommand {
+	return slices.DeleteFunc(commands, func(command botCommand) bool {
+		return command.midLine && !isCommand(command.words[0])
+	})
+}
+
+// newCommands returns the commands that are not in previous, so editing a
+// comment only runs the commands the edit added or changed. A command repeated
+// in a comment counts once per occurrence.
+func newCommands(commands, previous []botCommand) []botCommand {
+	seen := map[string]int{}
+	for _, command := range previous {
+		seen[strings.Join(command.words, " ")]++
+	}
+	var added []botCommand
+	for _, command := range commands {
+		key := strings.Join(command.words, " ")
+		if seen[key] > 0 {
+			seen[key]--
+			continue
+		}
+		added = append(added, command)
+	}
+	return added
+}
+
+// appendCommand adds a command to the list unless it has no words.
+func appendCommand(commands []botCommand, command *botCommand) []botCommand {
+	if command == nil || len(command.words) == 0 {
+		return commands
+	}
+	return append(commands, *command)
+}
@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseCommandsConversation(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want [][]string
+	}{
+		{"mention starting a line", "@instructlab-bot precheck", [][]string{{"precheck"}}},
+		{"unknown command starting a line", "@instructlab-bot frobnicate", [][]string{{"frobnicate"}}},
+		{"casual mention", "thanks @instructlab-bot for the help", nil},
+		{"casual mention ending a sentence", "Looks good now, thanks @instructlab-bot!", nil},
+		{"command after a mid-line mention", "Could you run @instructlab-bot precheck --seed 3", [][]string{{"precheck", "--seed", "3"}}},
+		{"casual mention then a command", "thanks @instructlab-bot\n@instructlab-bot cancel", [][]string{{"cancel"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got [][]string
+			for _, command := range dropConversation(parseCommands(tt.body, "@instructlab-bot", SlashCommand)) {
+				got = append(got, command.words)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Commands of %q = %v, want %v", tt.body, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseCommands(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want [][]string
+	}{
+		{"one command", "@instructlab-bot generate", [][]string{{"generate"}}},
+		{"command with arguments", "@instructlab-bot precheck --model granite-7b-lab", [][]string{{"precheck", "--model", "granite-7b-lab"}}},
+		{"commands on several lines", "@instructlab-bot precheck\nand then\n@instructlab-bot generate", [][]string{{"precheck"}, {"generate"}}},
+		{"commands on one line", "@instructlab-bot precheck @instructlab-bot generate", [][]string{{"precheck"}, {"generate"}}},
+		{"mention with a colon", "@instructlab-bot: generate", [][]string{{"generate"}}},
+		{"mention in another case", "@InstructLab-Bot generate", [][]string{{"generate"}}},
+		{"mention without a command", "@instructlab-bot", nil},
+		{"slash command", "/instructlab generate", [][]string{{"generate"}}},
+		{"slash command and mention", "/instructlab precheck\n@instructlab-bot generate", [][]string{{"precheck"}, {"generate"}}},
+		{"slash command mid-line", "please /instructlab generate", nil},
+		{"code fence", "```\n@instructlab-bot generate\n```\n@instructlab-bot precheck", [][]string{{"precheck"}}},
+		{"tilde code fence", "~~~sh\n/instructlab generate\n~~~", nil},
+		{"unclosed code fence", "```\n@instructlab-bot generate", nil},
+		{"quoted reply", "> @instructlab-bot generate\n@instructlab-bot precheck", [][]string{{"precheck"}}},
+		{"inline code", "Run `@instructlab-bot generate` to get data", nil},
+		{"inline code after a command", "@instructlab-bot precheck `--model foo`", [][]string{{"precheck"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got [][]string
+			for _, command := range parseCommands(tt.body, "@instructlab-bot", SlashCommand) {
+				got = append(got, command.words)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Commands of %q = %v, want %v", tt.body, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewCommands(t *testing.T) {
+	tests := []struct {
+		name     string
+		previous string
+		body     string
+		want     [][]string
+	}{
+		{"new comment", "", "@instructlab-bot precheck\n@instructlab-bot generate", [][]string{{"precheck"}, {"generate"}}},
+		{"unchanged commands", "@instructlab-bot precheck", "Typo fixed.\n@instructlab-bot precheck", nil},
+		{"added command", "@instructlab-bot precheck", "@instructlab-bot precheck\n@instructlab-bot generate", [][]string{{"generate"}}},
+		{"changed arguments", "@instructlab-bot precheck", "@instructlab-bot precheck --model granite-7b-lab", [][]string{{"precheck", "--model", "granite-7b-lab"}}},
+		{"repeated command", "@instructlab-bot precheck", "@instructlab-bot precheck\n@instructlab-bot precheck", [][]string{{"precheck"}}},
+		{"removed command", "@instructlab-bot precheck\n@instructlab-bot generate", "@instructlab-bot generate", nil},
+		{"command moved out of a code fence", "```\n@instructlab-bot generate\n```", "@instructlab-bot generate", [][]string{{"generate"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got [][]string
+			previous := parseCommands(tt.previous, "@instructlab-bot", SlashCommand)
+			for _, command := range newCommands(parseCommands(tt.body, "@instructlab-bot", SlashCommand), previous) {
+				got = append(got, command.words)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("New commands of %q edited from %q = %v, want %v", tt.body, tt.previous, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,49 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/go-github/v61/github"
+	"github.com/instructlab/instructlab-bot/gobot/util"
+	"github.com/instructlab/instructlab-bot/pkg/jobs"
+)
+
+// reuseJob answers a request identical to an earlier job on the same commit.
+// A job still queued or running is pointed to, the results of a successful
+// job are posted again, as is the check of a job that found no changes.
+func (h *PRCommentHandler) reuseJob(ctx context.Context, client *github.Client, prComment *PRComment, existing *jobs.Job) error {
+	h.Logger.Infof("Reusing job %s for an identical %s request on %s/%s#%d by %s", existing.ID, existing.JobType,
+		prComment.repoOwner, prComment.repoName, prComment.prNum, prComment.author)
+
+	if existing.Active() {
+		checksURL := util.PullRequestURL(prComment.repoOwner, prComment.repoName, prComment.prNum) + "/checks"
+		params := util.PullRequestStatusParams{
+			RepoOwner: prComment.repoOwner,
+			RepoName:  prComment.repoName,
+			PrNum:     prComment.prNum,
+			Comment: fmt.Sprintf("Beep, boop 🤖, A *%s* job (ID %s) is already %s for this commit. "+
+				"Its results will be presented in the [%s](%s) check.",
+				existing.JobType, existing.ID, activeState(existing), util.JobCheckName(existing.JobType), checksURL),
+		}
+		return h.postComment(ctx, client, params)
+	}
+
+	if existing.NoChanges() {
+		return util.PostJobNoChangesCheck(ctx, client, existing)
+	}
+
+	note := fmt.Sprintf("> [!NOTE] \n > These results were computed by job %s on the same commit and are reused instead of running the job again.", existing.ID)
+	if err := util.PostJobResults(ctx, client, existing, note); err != nil {
+		h.Logger.Errorf("Failed to post results of job %s on PR %s/%s#%d: %v", existing.ID, prComment.repoOwner, prComment.repoName, prComment.prNum, err)
+		return err
+	}
+	return nil
+}
+
+func activeState(job *jobs.Job) string {
+	if job.Status == jobs.StatusRunning {
+		return "running"
+	}
+	return "queued"
+}
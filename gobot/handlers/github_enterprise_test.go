@@ -0,0 +1,324 @@
+package handlers
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/instructlab/instructlab-bot/gobot/common"
+	"github.com/instructlab/instructlab-bot/gobot/util"
+	"github.com/instructlab/instructlab-bot/pkg/authz"
+	"github.com/instructlab/instructlab-bot/pkg/jobs"
+	"github.com/palantir/go-githubapp/githubapp"
+	"go.uber.org/zap"
+)
+
+// fakeGitHub is a GitHub Enterprise Server serving the few API calls of the
+// tests, recording every request it receives.
+type fakeGitHub struct {
+	*httptest.Server
+
+	mu         sync.Mutex
+	requests   []string
+	bodies     []string
+	unexpected []string
+	statuses   []map[string]any
+	checkRuns  []map[string]any
+	reactions  []string
+	// onCreateCheckRun, if set, is called when a check run is created.
+	onCreateCheckRun func()
+}
+
+func newFakeGitHub(t *testing.T) *fakeGitHub {
+	f := &fakeGitHub{}
+	f.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		route := r.Method + " " + r.URL.Path
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		f.requests = append(f.requests, route)
+		f.bodies = append(f.bodies, string(body))
+
+		switch route {
+		case "POST /api/v3/app/installations/42/access_tokens":
+			reply(w, http.StatusCreated, map[string]any{
+				"token":      "ghs_fake",
+				"expires_at": time.Now().Add(time.Hour).Format(time.RFC3339),
+			})
+		case "GET /api/v3/repos/acme/taxonomy/pulls/7":
+			reply(w, http.StatusOK, map[string]any{
+				"number": 7,
+				"head":   map[string]any{"sha": "abc123"},
+				"user":   map[string]any{"login": "alice"},
+			})
+		case "POST /api/v3/repos/acme/taxonomy/issues/7/comments":
+			reply(w, http.StatusCreated, map[string]any{"id": 2})
+		case "POST /api/v3/repos/acme/taxonomy/statuses/abc123":
+			var status map[string]any
+			if err := json.Unmarshal(body, &status); err != nil {
+				t.Errorf("Invalid status: %v", err)
+			}
+			f.statuses = append(f.statuses, status)
+			reply(w, http.StatusCreated, map[string]any{"id": 3})
+		case "POST /api/v3/repos/acme/taxonomy/check-runs", "PATCH /api/v3/repos/acme/taxonomy/check-runs/99":
+			var checkRun map[string]any
+			if err := json.Unmarshal(body, &checkRun); err != nil {
+				t.Errorf("Invalid check run: %v", err)
+			}
+			f.checkRuns = append(f.checkRuns, checkRun)
+			if r.Method == http.MethodPost && f.onCreateCheckRun != nil {
+				f.onCreateCheckRun()
+			}
+			reply(w, http.StatusOK, map[string]any{"id": 99})
+		case "POST /api/v3/repos/acme/taxonomy/issues/comments/1/reactions":
+			var reaction struct {
+				Content string `json:"content"`
+			}
+			if err := json.Unmarshal(body, &reaction); err != nil {
+				t.Errorf("Invalid reaction: %v", err)
+			}
+			f.reactions = append(f.reactions, reaction.Content)
+			reply(w, http.StatusCreated, map[string]any{"id": 4, "content": reaction.Content})
+		default:
+			f.unexpected = append(f.unexpected, route)
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(f.Close)
+	return f
+}
+
+func reply(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// newEnterpriseClientCreator returns a client creator for the fake server,
+// configured the way the bot configures it from its web URL.
+func newEnterpriseClientCreator(t *testing.T, webURL string) githubapp.ClientCreator {
+	urls, err := util.NewGitHubURLs(webURL, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := githubapp.Config{V3APIURL: urls.V3API, V4APIURL: urls.V4API}
+	config.App.IntegrationID = 1
+	config.App.PrivateKey = string(pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}))
+
+	cc, err := githubapp.NewDefaultCachingClientCreator(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cc
+}
+
+// issueCommentPayload returns the event of alice commenting on the pull
+// request acme/taxonomy#7 of the fake server.
+func issueCommentPayload(webURL, comment string) []byte {
+	return []byte(fmt.Sprintf(`{
+		"action": "created",
+		"repository": {"name": "taxonomy", "owner": {"login": "acme"}, "html_url": "%[1]s/acme/taxonomy"},
+		"issue": {"number": 7, "pull_request": {"url": "%[1]s/api/v3/repos/acme/taxonomy/pulls/7"}},
+		"comment": {"id": 1, "body": "%[2]s", "user": {"login": "alice", "type": "User"}},
+		"sender": {"login": "alice", "type": "User"},
+		"installation": {"id": 42}
+	}`, webURL, comment))
+}
+
+// checkBodies reports the request bodies sent to the fake server that point
+// at github.com instead of the server.
+func (f *fakeGitHub) checkBodies(t *testing.T) {
+	for i, body := range f.bodies {
+		if strings.Contains(body, "github.com") {
+			t.Errorf("Request %s points at github.com: %s", f.requests[i], body)
+		}
+	}
+}
+
+func TestHelpCommandOnEnterpriseServer(t *testing.T) {
+	fake := newFakeGitHub(t)
+	defer func(web string) { util.WebURL = web }(util.WebURL)
+	util.WebURL = fake.URL
+
+	repos := util.Repos{{Owner: "acme", Name: common.RepoName}}
+	if err := repos.Prepare(nil); err != nil {
+		t.Fatal(err)
+	}
+	h := &PRCommentHandler{
+		ClientCreator: newEnterpriseClientCreator(t, fake.URL),
+		Logger:        zap.NewNop().Sugar(),
+		CommentMode:   CommentModeComments,
+		Repos:         util.NewRepoStore(repos),
+		BotUsername:   "@instructlab-bot",
+	}
+
+	if err := h.Handle(context.Background(), "issue_comment", "delivery", issueCommentPayload(fake.URL, "@instructlab-bot help")); err != nil {
+		t.Fatalf("Handle: %v", err)
+	}
+
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+	if len(fake.unexpected) > 0 {
+		t.Errorf("Unexpected requests to the fake GitHub: %v", fake.unexpected)
+	}
+	for _, want := range []string{
+		"GET /api/v3/repos/acme/taxonomy/pulls/7",
+		"POST /api/v3/repos/acme/taxonomy/issues/7/comments",
+		"POST /api/v3/repos/acme/taxonomy/statuses/abc123",
+	} {
+		if !slices.Contains(fake.requests, want) {
+			t.Errorf("Missing request %s, got %v", want, fake.requests)
+		}
+	}
+	fake.checkBodies(t)
+	if len(fake.statuses) != 1 {
+		t.Fatalf("Got %d statuses, want 1", len(fake.statuses))
+	}
+	want := fake.URL + "/acme/taxonomy/pull/7/checks"
+	if got := fake.statuses[0]["target_url"]; got != want {
+		t.Errorf("Status target URL = %v, want %s", got, want)
+	}
+}
+
+// newJobsCommentHandler returns a comment handler of the fake server queueing
+// jobs in an in-memory Redis. alice may run every command on acme/taxonomy.
+func newJobsCommentHandler(t *testing.T, fake *fakeGitHub) (*PRCommentHandler, *jobs.RedisStore, *miniredis.Miniredis) {
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { rdb.Close() })
+	dispatcher, err := jobs.NewDispatcher(context.Background(), jobs.BackendLists, rdb, jobs.DispatcherOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := jobs.NewRedisStore(rdb)
+
+	repos := util.Repos{{
+		Owner:  "acme",
+		Name:   common.RepoName,
+		Policy: &authz.Policy{Rules: []authz.Rule{{Users: []string{"alice"}}}},
+	}}
+	if err := repos.Prepare(nil); err != nil {
+		t.Fatal(err)
+	}
+	h := &PRCommentHandler{
+		ClientCreator: newEnterpriseClientCreator(t, fake.URL),
+		Logger:        zap.NewNop().Sugar(),
+		CommentMode:   CommentModeComments,
+		Repos:         util.NewRepoStore(repos),
+		BotUsername:   "@instructlab-bot",
+		JobStore:      store,
+		Scheduler:     jobs.NewScheduler(rdb, dispatcher),
+		Dedup:         jobs.NewDedupIndex(rdb),
+	}
+	return h, store, mr
+}
+
+func TestQueuedJobOnEnterpriseServer(t *testing.T) {
+	ctx := context.Background()
+	fake := newFakeGitHub(t)
+	defer func(web string) { util.WebURL = web }(util.WebURL)
+	util.WebURL = fake.URL
+	h, store, mr := newJobsCommentHandler(t, fake)
+
+	// A job finishing right away must find its check run to update
+	fake.onCreateCheckRun = func() {
+		if mr.Exists(jobs.ScheduleKey(jobs.TypePrecheck, jobs.PriorityNormal)) {
+			t.Error("Check run created after the job was scheduled")
+		}
+	}
+	if err := h.Handle(ctx, "issue_comment", "delivery", issueCommentPayload(fake.URL, "@instructlab-bot precheck")); err != nil {
+		t.Fatalf("Handle: %v", err)
+	}
+	queued, err := store.ListPR(ctx, "acme", "taxonomy", 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(queued) != 1 {
+		t.Fatalf("Got %d jobs, want 1", len(queued))
+	}
+	job := queued[0]
+	if job.CheckRunID != 99 {
+		t.Errorf("Check run of the job = %d, want 99", job.CheckRunID)
+	}
+
+	// The bot reports the results of the job once a worker completes it
+	if err := store.Complete(ctx, job.ID, jobs.Result{S3URL: "https://results.example.com/index.html"}); err != nil {
+		t.Fatal(err)
+	}
+	if job, err = store.Get(ctx, job.ID); err != nil {
+		t.Fatal(err)
+	}
+	client, err := h.NewInstallationClient(42)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := util.PostJobResults(ctx, client, job, ""); err != nil {
+		t.Fatalf("PostJobResults: %v", err)
+	}
+
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+	if len(fake.unexpected) > 0 {
+		t.Errorf("Unexpected requests to the fake GitHub: %v", fake.unexpected)
+	}
+	for _, want := range []string{
+		"GET /api/v3/repos/acme/taxonomy/pulls/7",
+		"POST /api/v3/repos/acme/taxonomy/check-runs",
+		"PATCH /api/v3/repos/acme/taxonomy/check-runs/99",
+	} {
+		if !slices.Contains(fake.requests, want) {
+			t.Errorf("Missing request %s, got %v", want, fake.requests)
+		}
+	}
+	fake.checkBodies(t)
+	if len(fake.checkRuns) != 3 {
+		t.Fatalf("Got %d check runs, want the created one, the queue position and the results", len(fake.checkRuns))
+	}
+	if got := fake.checkRuns[0]["status"]; got != common.CheckInProgress {
+		t.Errorf("Status of the created check run = %v, want %s", got, common.CheckInProgress)
+	}
+	if got := fake.checkRuns[0]["external_id"]; got != job.ID {
+		t.Errorf("External ID of the created check run = %v, want %s", got, job.ID)
+	}
+	summary, _ := fake.checkRuns[1]["output"].(map[string]any)["summary"].(string)
+	if !strings.Contains(summary, "Queue position: 1") {
+		t.Errorf("Updated check run does not give the queue position: %q", summary)
+	}
+	if got := fake.checkRuns[2]["conclusion"]; got != common.CheckStatusSuccess {
+		t.Errorf("Conclusion of the updated check run = %v, want %s", got, common.CheckStatusSuccess)
+	}
+
+	var comments []string
+	for i, request := range fake.requests {
+		if request == "POST /api/v3/repos/acme/taxonomy/issues/7/comments" {
+			comments = append(comments, fake.bodies[i])
+		}
+	}
+	if len(comments) != 2 {
+		t.Fatalf("Got %d comments, want the queued jobs and the results: %v", len(comments), comments)
+	}
+	if !strings.Contains(comments[0], "job "+job.ID) {
+		t.Errorf("Queued jobs comment does not name job %s: %s", job.ID, comments[0])
+	}
+	if !strings.Contains(comments[1], "https://results.example.com/index.html") {
+		t.Errorf("Results comment does not link the results: %s", comments[1])
+	}
+}
@@ -4,14 +4,14 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"strconv"
+	"slices"
 	"strings"
-	"time"
 
-	"github.com/go-redis/redis/v8"
 	"github.com/google/go-github/v61/github"
 	"github.com/instructlab/instructlab-bot/gobot/common"
 	"github.com/instructlab/instructlab-bot/gobot/util"
+	"github.com/instructlab/instructlab-bot/pkg/history"
+	"github.com/instructlab/instructlab-bot/pkg/jobs"
 	"github.com/palantir/go-githubapp/githubapp"
 	"github.com/pkg/errors"
 	"go.uber.org/zap"
@@ -19,6 +19,9 @@ import (
 
 const (
 	DeprecatedBotUsername = "@instruct-lab-bot"
+	// SlashCommand addresses the bot at the start of a line, as an alternative
+	// to mentioning the bot username.
+	SlashCommand = "/instructlab"
 
 	AccessCheckFailed = "Access check failed."
 	LabelsNotFound    = "Required labels not found."
@@ -26,13 +29,31 @@ const (
 	NotAllowed        = "Command not allowed"
 )
 
+// DeprecatedMentions lists the retired ways to address the bot. Their
+// commands still run, along with a warning pointing to the bot username.
+var DeprecatedMentions = []string{DeprecatedBotUsername}
+
 type PRCommentHandler struct {
 	githubapp.ClientCreator
-	Logger         *zap.SugaredLogger
-	RedisHostPort  string
-	RequiredLabels []string
-	BotUsername    string
-	Maintainers    []string
+	Logger      *zap.SugaredLogger
+	JobStore    jobs.JobStore
+	Archiver    *history.Archiver
+	Scheduler   *jobs.Scheduler
+	Dedup       *jobs.DedupIndex
+	ArgLimits   JobArgLimits
+	CommentMode string
+	Repos       *util.RepoStore
+	BotUsername string
+}
+
+// JobArgLimits bounds the options of the job commands.
+type JobArgLimits struct {
+	MaxNumInstructions int
+	MaxSeed            int
+	MaxFiles           int
+	// Models lists the models a job can be run against. The --model option is
+	// refused when it is empty.
+	Models []string
 }
 
 type PRComment struct {
@@ -42,9 +63,17 @@ type PRComment struct {
 	prNum     int
 	author    string
 	body      string
+	commentID int64
+	prAuthor  string
 	installID int64
 	prSha     string
 	labels    []*github.Label
+	// repo holds the settings of the repository of the PR
+	repo *util.RepoConfig
+	// rerun runs a new job even when an identical job could be reused
+	rerun bool
+	// queued lists the jobs queued for the comment, one line each
+	queued []string
 }
 
 func (h *PRCommentHandler) Handles() []string {
@@ -58,9 +87,11 @@ func (h *PRCommentHandler) Handle(ctx context.Context, eventType, deliveryID str
 		return errors.Wrap(err, "failed to parse issue comment event payload")
 	}
 
-	if event.GetRepo().GetName() != common.RepoName {
+	repo := event.GetRepo()
+	repoConfig, ok := h.Repos.Lookup(repo.GetOwner().GetLogin(), repo.GetName())
+	if !ok {
 		h.Logger.Warnf("Received unexpected event %s from %s/%s repo. Skipping the event.",
-			eventType, event.GetOrganization().GetLogin(), event.GetRepo().GetName())
+			eventType, repo.GetOwner().GetLogin(), repo.GetName())
 		return nil
 	}
 
@@ -68,12 +99,17 @@ func (h *PRCommentHandler) Handle(ctx context.Context, eventType, deliveryID str
 		return nil
 	}
 
-	if event.GetAction() != "created" {
+	if event.GetAction() != "created" && event.GetAction() != "edited" {
+		return nil
+	}
+
+	// Commands can be anywhere in a comment, so the replies of the bots,
+	// including this one, are never read as commands
+	if event.GetComment().GetUser().GetType() == "Bot" || event.GetSender().GetType() == "Bot" {
 		return nil
 	}
 
 	h.Logger.Debugf("Details of the event: %v", event)
-	repo := event.GetRepo()
 	prComment := PRComment{
 		repoOwner: repo.GetOwner().GetLogin(),
 		repoName:  repo.GetName(),
@@ -81,7 +117,23 @@ func (h *PRCommentHandler) Handle(ctx context.Context, eventType, deliveryID str
 		prNum:     event.GetIssue().GetNumber(),
 		author:    event.GetComment().GetUser().GetLogin(),
 		body:      event.GetComment().GetBody(),
+		commentID: event.GetComment().GetID(),
 		installID: githubapp.GetInstallationIDFromEvent(&event),
+		repo:      repoConfig,
+	}
+
+	mentions := append([]string{h.BotUsername, SlashCommand}, DeprecatedMentions...)
+	commands := parseCommands(prComment.body, mentions...)
+	if event.GetAction() == "edited" {
+		// Only the commands added or changed by the edit are run, and by
+		// whoever made the edit
+		previous := parseCommands(event.GetChanges().GetBody().GetFrom(), mentions...)
+		commands = newCommands(commands, previous)
+		prComment.author = event.GetSender().GetLogin()
+	}
+	commands = dropConversation(commands)
+	if len(commands) == 0 {
+		return nil
 	}
 
 	client, err := h.NewInstallationClient(prComment.installID)
@@ -89,200 +141,201 @@ func (h *PRCommentHandler) Handle(ctx context.Context, eventType, deliveryID str
 		h.Logger.Errorf("Failed to create installation client: %v", err)
 		return err
 	}
-	words := strings.Fields(strings.TrimSpace(prComment.body))
-	if len(words) < 2 {
-		return nil
+	var deprecated []string
+	for _, command := range commands {
+		if slices.Contains(DeprecatedMentions, command.mention) && !slices.Contains(deprecated, command.mention) {
+			deprecated = append(deprecated, command.mention)
+		}
 	}
-	if words[0] == DeprecatedBotUsername {
+	for _, mention := range deprecated {
 		params := util.PullRequestStatusParams{
 			RepoOwner: prComment.repoOwner,
 			RepoName:  prComment.repoName,
 			PrNum:     prComment.prNum,
 		}
 		params.Comment = fmt.Sprintf("> [!WARNING] \n > Beep, boop 🤖, The bot username `%s` is going to"+
-			" be deprecated soon. Please use `%s` instead.", DeprecatedBotUsername, h.BotUsername)
+			" be deprecated soon. Please use `%s` instead.", mention, h.BotUsername)
 		if err := util.PostPullRequestComment(ctx, client, params); err != nil {
 			h.Logger.Errorf("Failed to post pull request comment: %v", err)
 		}
-	} else if words[0] != h.BotUsername {
-		return nil
 	}
 
 	// Fetch the PR sha and labels to avoid multiple Pull Request API calls
 	pr, _, err := client.PullRequests.Get(ctx, prComment.repoOwner, prComment.repoName, prComment.prNum)
 	if err != nil {
-		h.Logger.Errorf("Failed to get pull request (%s/%s#%d) related to the issue comment: %w", prComment.repoOwner, prComment.repoName, prComment.prNum, err)
+		h.Logger.Errorf("Failed to get pull request (%s/%s#%d) related to the issue comment: %v", prComment.repoOwner, prComment.repoName, prComment.prNum, err)
 		return err
 	}
 
 	prComment.prSha = pr.GetHead().GetSHA()
 	prComment.labels = pr.Labels
-
-	switch words[1] {
-	case "help":
-		return h.helpCommand(ctx, client, &prComment)
-	case "enable":
-		return h.enableCommand(ctx, client, &prComment)
-	case "generate-local":
-		return h.generateCommand(ctx, client, &prComment)
-	case "precheck":
-		return h.precheckCommand(ctx, client, &prComment)
-	case "generate":
-		return h.sdgSvcCommand(ctx, client, &prComment)
-	default:
-		return h.unknownCommand(ctx, client, &prComment)
-	}
-}
-
-func setJobKey(r *redis.Client, jobNumber int64, key string, value interface{}) error {
-	return r.Set(context.Background(), "jobs:"+strconv.FormatInt(jobNumber, 10)+":"+key, value, 0).Err()
-}
-
-func (h *PRCommentHandler) queueGenerateJob(ctx context.Context, client *github.Client, prComment *PRComment, jobType string) error {
-	r := redis.NewClient(&redis.Options{
-		Addr:     h.RedisHostPort,
-		Password: "", // no password set
-		DB:       0,  // use default DB
-	})
-
-	jobNumber, err := r.Incr(ctx, common.RedisKeyJobs).Result()
-	if err != nil {
-		return err
-	}
-
-	err = setJobKey(r, jobNumber, common.RedisKeyPRNumber, prComment.prNum)
-	if err != nil {
-		return err
-	}
-
-	err = setJobKey(r, jobNumber, common.RedisKeyPRSHA, prComment.prSha)
-	if err != nil {
-		return err
+	prComment.prAuthor = pr.GetUser().GetLogin()
+
+	// Every command is run even if an earlier one failed, and the jobs they
+	// queued are listed in a single reply
+	var firstErr error
+	for _, command := range commands {
+		if err := h.runCommand(ctx, client, &prComment, command.words); err != nil {
+			h.Logger.Errorf("Failed to run %q on %s/%s#%d: %v", strings.Join(command.words, " "),
+				prComment.repoOwner, prComment.repoName, prComment.prNum, err)
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
 	}
-
-	err = setJobKey(r, jobNumber, common.RedisKeyAuthor, prComment.author)
-	if err != nil {
-		return err
-	}
-
-	err = setJobKey(r, jobNumber, common.RedisKeyInstallationID, prComment.installID)
-	if err != nil {
-		return err
+	if err := h.postQueuedJobs(ctx, client, &prComment); err != nil && firstErr == nil {
+		firstErr = err
 	}
+	return firstErr
+}
 
-	err = setJobKey(r, jobNumber, common.RedisKeyRepoOwner, prComment.repoOwner)
-	if err != nil {
-		return err
+// runCommand runs a single command of a comment.
+func (h *PRCommentHandler) runCommand(ctx context.Context, client *github.Client, prComment *PRComment, words []string) error {
+	if spec, ok := util.JobTypeByCommand(words[0]); ok {
+		if !prComment.repo.CommandEnabled(spec.Command) {
+			return h.disabledCommand(ctx, client, prComment, spec.Command)
+		}
+		return h.jobCommand(ctx, client, prComment, spec, words[1:])
 	}
 
-	err = setJobKey(r, jobNumber, common.RedisKeyRepoName, prComment.repoName)
-	if err != nil {
-		return err
+	switch words[0] {
+	case "help":
+		return h.helpCommand(ctx, client, prComment)
+	case "enable":
+		return h.enableCommand(ctx, client, prComment)
+	case util.CancelCommand:
+		return h.cancelCommand(ctx, client, prComment, words[1:])
+	default:
+		return h.unknownCommand(ctx, client, prComment)
 	}
+}
 
-	err = setJobKey(r, jobNumber, common.RedisKeyJobType, jobType)
-	if err != nil {
-		return err
+// postQueuedJobs replies to a comment with the jobs its commands queued.
+func (h *PRCommentHandler) postQueuedJobs(ctx context.Context, client *github.Client, prComment *PRComment) error {
+	if len(prComment.queued) == 0 || !h.acknowledgeWithComments() {
+		return nil
 	}
-
-	err = setJobKey(r, jobNumber, common.RedisKeyErrors, "")
-	if err != nil {
-		return err
+	params := util.PullRequestStatusParams{
+		RepoOwner: prComment.repoOwner,
+		RepoName:  prComment.repoName,
+		PrNum:     prComment.prNum,
 	}
+	params.Comment = fmt.Sprintf("Beep, boop 🤖, Working on the following job(s) for your PR:\n\n%s\n"+
+		"The results will be presented below in the pull request status box. This may take several minutes...\n",
+		strings.Join(prComment.queued, ""))
+	return h.postComment(ctx, client, params)
+}
 
-	err = setJobKey(r, jobNumber, common.RedisKeyStatus, common.CheckStatusPending)
-	if err != nil {
-		return err
+func (h *PRCommentHandler) queueGenerateJob(ctx context.Context, client *github.Client, prComment *PRComment, jobType string, req jobRequest) error {
+	job := &jobs.Job{
+		PRNumber:       prComment.prNum,
+		PRSHA:          prComment.prSha,
+		Author:         prComment.author,
+		InstallationID: prComment.installID,
+		RepoOwner:      prComment.repoOwner,
+		RepoName:       prComment.repoName,
+		JobType:        jobType,
+		Priority:       req.priority,
+		Args:           req.args.String(),
+		GitRemote:      prComment.repo.GitRemote,
+		BaseBranch:     prComment.repo.BaseBranch,
+	}
+
+	if !prComment.rerun {
+		existing, err := h.Dedup.Lookup(ctx, job)
+		if err != nil {
+			h.Logger.Warnf("Failed to look up identical jobs, running a new one: %v", err)
+		}
+		if existing != nil {
+			return h.reuseJob(ctx, client, prComment, existing)
+		}
 	}
 
-	err = setJobKey(r, jobNumber, common.RedisKeyRequestTime, strconv.FormatInt(time.Now().Unix(), 10))
+	jobID, err := h.JobStore.Create(ctx, job)
 	if err != nil {
 		return err
 	}
 
-	err = r.LPush(ctx, "generate", strconv.FormatInt(jobNumber, 10)).Err()
-	if err != nil {
-		h.Logger.Errorf("Failed to LPUSH job %d to redis %v", jobNumber, err)
-		return err
+	checkName := util.JobCheckName(jobType)
+	if checkName == "" {
+		h.Logger.Errorf("Unknown job type: %s", jobType)
 	}
 
-	summaryMsg := "Job ID: " + strconv.FormatInt(jobNumber, 10) + " - Generating test data.\n\n"
 	detailsMsg := fmt.Sprintf("Generating test data for your PR with the job type: *%s*. \n"+
-		"Related Job ID is %d.\n"+
-		"This may take several minutes...\n\n", jobType, jobNumber)
-	commentMsg := fmt.Sprintf("Beep, boop 🤖, Working on *%s* job for your PR. The "+
-		"results will be presented below in the pull request status box. This may take several minutes...\n\n",
-		jobType)
-
-	var checkName string
-	switch jobType {
-	case "generate":
-		checkName = common.GenerateLocalCheck
-	case "precheck":
-		checkName = common.PrecheckCheck
-	case "sdg-svc":
-		checkName = common.GenerateSDGCheck
-	default:
-		h.Logger.Errorf("Unknown job type: %s", jobType)
+		"Related Job ID is %s.\n", jobType, jobID)
+	if job.Args != "" {
+		detailsMsg += fmt.Sprintf("Job options: `%s`\n", job.Args)
 	}
+	detailsMsg += "This may take several minutes...\n\n"
 
 	params := util.PullRequestStatusParams{
 		Status:       common.CheckInProgress,
-		CheckSummary: summaryMsg,
+		CheckSummary: fmt.Sprintf("Job ID: %s - Generating test data.\n\n", jobID),
 		CheckDetails: detailsMsg,
-		Comment:      commentMsg,
 		CheckName:    checkName,
 		JobType:      jobType,
-		JobID:        strconv.FormatInt(jobNumber, 10),
+		JobID:        jobID,
 		RepoOwner:    prComment.repoOwner,
 		RepoName:     prComment.repoName,
 		PrNum:        prComment.prNum,
 		PrSha:        prComment.prSha,
 	}
 
-	err = util.PostPullRequestCheck(ctx, client, params)
+	// The check run is created before the job is scheduled, so the results of
+	// a job finishing right away update it instead of adding a second one
+	checkRunID, err := util.PostPullRequestCheck(ctx, client, params)
 	if err != nil {
 		h.Logger.Errorf("Failed to post check on PR %s/%s#%d: %v", params.RepoOwner, params.RepoName, params.PrNum, err)
+		if failErr := h.JobStore.Fail(ctx, jobID, fmt.Errorf("failed to post the check run: %w", err)); failErr != nil {
+			h.Logger.Errorf("Failed to record the failure of job %s: %v", jobID, failErr)
+		}
 		return err
 	}
-	return nil
-
-}
-
-func (h *PRCommentHandler) checkAuthorPermission(ctx context.Context, client *github.Client, prComment *PRComment) bool {
-	if prComment.repoOrg == "" {
-		h.Logger.Warnf("No organization found in the repository URL")
+	if err := h.JobStore.SetCheckRun(ctx, jobID, checkRunID); err != nil {
+		h.Logger.Errorf("Failed to record the check run of job %s: %v", jobID, err)
 	}
+	job.CheckRunID = checkRunID
+	params.CheckRunID = checkRunID
 
-	// Check if user is part of the teams that are allowed to enable the bot
-	isAllowed := true
-	for _, teamName := range h.Maintainers {
-		var err error
-		isAllowed = true
-		teamMembership, _, err := client.Teams.GetTeamMembershipBySlug(ctx, prComment.repoOrg, teamName, prComment.author)
-		if err != nil {
-			isAllowed = false
-			h.Logger.Debugf("Failed to get team membership for user %s in team %s: %v", prComment.author, teamName, err)
-			continue
-		}
-
-		if teamMembership.GetState() != "active" {
-			h.Logger.Debugf("User %s does not have required permission in the team %s", prComment.author, teamName)
-			isAllowed = false
-			continue
+	position, err := h.Scheduler.Schedule(ctx, job)
+	if err != nil {
+		h.Logger.Errorf("Failed to schedule job %s: %v", jobID, err)
+		if failErr := h.JobStore.Fail(ctx, jobID, err); failErr != nil {
+			h.Logger.Errorf("Failed to record the failure of job %s: %v", jobID, failErr)
 		}
-		h.Logger.Infof("User %s is a part of the team %s", prComment.author, teamName)
-		if isAllowed {
-			break
+		params.Status = common.CheckComplete
+		params.Conclusion = common.CheckStatusFailure
+		params.CheckSummary = fmt.Sprintf("Job ID: %s - Failed to queue the job.", jobID)
+		if _, checkErr := util.PostPullRequestCheck(ctx, client, params); checkErr != nil {
+			h.Logger.Errorf("Failed to update the check run of job %s: %v", jobID, checkErr)
 		}
+		return err
 	}
-	return isAllowed
+	if err := h.Dedup.Record(ctx, job); err != nil {
+		h.Logger.Warnf("Failed to record job %s for deduplication: %v", jobID, err)
+	}
+	h.react(ctx, client, prComment, reactionQueued)
+	prComment.queued = append(prComment.queued, fmt.Sprintf("* *%s* -- job %s, queue position %d (%s priority)\n",
+		jobType, jobID, position, job.Priority))
+
+	// A worker may already have taken the job, and its own updates of the
+	// check run must not be overwritten with the queue position
+	if current, err := h.JobStore.Get(ctx, jobID); err != nil || current.Status != jobs.StatusPending {
+		return nil
+	}
+	params.CheckSummary = fmt.Sprintf("Job ID: %s - Generating test data. Queue position: %d (%s priority).\n\n", jobID, position, job.Priority)
+	if _, err := util.PostPullRequestCheck(ctx, client, params); err != nil {
+		h.Logger.Warnf("Failed to post the queue position of job %s: %v", jobID, err)
+	}
+	return nil
+
 }
 
 func (h *PRCommentHandler) helpCommand(ctx context.Context, client *github.Client, prComment *PRComment) error {
 	h.Logger.Infof("Help command received on %s/%s#%d by %s",
 		prComment.repoOwner, prComment.repoName, prComment.prNum, prComment.author)
-	err := util.PostBotWelcomeMessage(ctx, client, prComment.repoOwner, prComment.repoName, prComment.prNum, prComment.prSha, h.BotUsername, h.Maintainers)
+	h.react(ctx, client, prComment, reactionAccepted)
+	err := util.PostBotWelcomeMessage(ctx, client, prComment.repoOwner, prComment.repoName, prComment.prNum, prComment.prSha, h.BotUsername, prComment.repo)
 	if err != nil {
 		h.Logger.Errorf("Failed to post welcome message on PR %s/%s#%d: %v", prComment.repoOwner, prComment.repoName, prComment.prNum, err)
 		return err
@@ -300,7 +353,7 @@ func (h *PRCommentHandler) enableCommand(ctx context.Context, client *github.Cli
 		PrSha:     prComment.prSha,
 	}
 	params.Comment = fmt.Sprintf("> [!NOTE] \n > **Enable command is deprecated and removed now. If you are member of the maintainers team [%v], "+
-		"you can run the commands directly. Enabling the bot is not required.**", h.Maintainers)
+		"you can run the commands directly. Enabling the bot is not required.**", prComment.repo.Maintainers)
 
 	err := util.PostPullRequestComment(ctx, client, params)
 	if err != nil {
@@ -310,169 +363,179 @@ func (h *PRCommentHandler) enableCommand(ctx context.Context, client *github.Cli
 	return nil
 }
 
-func (h *PRCommentHandler) generateCommand(ctx context.Context, client *github.Client, prComment *PRComment) error {
-	h.Logger.Infof("Generate command received on %s/%s#%d by %s",
+// jobCommand checks a job type's requirements against the PR and the
+// comment author, and queues a job when they are met. The command options set
+// the priority class of the job and the options of the job type.
+func (h *PRCommentHandler) jobCommand(ctx context.Context, client *github.Client, prComment *PRComment, spec util.JobTypeSpec, args []string) error {
+	h.Logger.Infof("%s command received on %s/%s#%d by %s", spec.Command,
 		prComment.repoOwner, prComment.repoName, prComment.prNum, prComment.author)
 
 	params := util.PullRequestStatusParams{
 		Status:     common.CheckComplete,
 		Conclusion: common.CheckStatusFailure,
-		CheckName:  common.GenerateLocalCheck,
+		CheckName:  spec.CheckName,
 		RepoOwner:  prComment.repoOwner,
 		RepoName:   prComment.repoName,
 		PrNum:      prComment.prNum,
 		PrSha:      prComment.prSha,
 	}
 
-	// Check if user is part of the teams that are allowed to enable the bot
-	isAllowed := h.checkAuthorPermission(ctx, client, prComment)
-
-	if !isAllowed {
-		params.Comment = fmt.Sprintf("User %s is not allowed to run the InstructLab bot. Only %v teams are allowed to access the bot functions.", prComment.author, h.Maintainers)
-
-		err := util.PostPullRequestComment(ctx, client, params)
-		if err != nil {
-			h.Logger.Errorf("Failed to post comment on PR %s/%s#%d: %v", prComment.repoOwner, prComment.repoName, prComment.prNum, err)
-			return err
+	req, err := parseJobArgs(spec, args, h.ArgLimits)
+	if err != nil {
+		h.react(ctx, client, prComment, reactionDenied)
+		params.Comment = fmt.Sprintf("Beep, boop 🤖, Sorry, I could not read the `%s` command: %v\n\nUsage: `%s %s %s`",
+			spec.Command, err, h.BotUsername, spec.Command, spec.Usage())
+		return h.postComment(ctx, client, params)
+	}
+
+	// Overriding the priority or the job options takes the same rights as
+	// the commands reserved to maintainers
+	if spec.MaintainersOnly || len(args) > 0 {
+		if !h.authorize(ctx, client, prComment, spec.Command) {
+			h.react(ctx, client, prComment, reactionDenied)
+			if !h.acknowledgeWithComments() {
+				return nil
+			}
+			params.Comment = fmt.Sprintf("User %s is not allowed to run the `%s` command on this pull request.", prComment.author, spec.Command)
+
+			err := util.PostPullRequestComment(ctx, client, params)
+			if err != nil {
+				h.Logger.Errorf("Failed to post comment on PR %s/%s#%d: %v", prComment.repoOwner, prComment.repoName, prComment.prNum, err)
+				return err
+			}
+			return nil
 		}
-		return nil
 	}
+	h.react(ctx, client, prComment, reactionAccepted)
 
-	present, err := util.CheckRequiredLabel(prComment.labels, h.RequiredLabels)
-	if err != nil {
-		h.Logger.Errorf("Failed to check required labels: %v", err)
-	}
-	if !present {
-		detailsMsg := fmt.Sprintf("Beep, boop 🤖: To proceed, the pull request must have one of the '%v' labels.", h.RequiredLabels)
+	if spec.RequireLabels {
+		present, err := util.CheckRequiredLabel(prComment.labels, prComment.repo.RequiredLabels)
 		if err != nil {
-			detailsMsg = fmt.Sprintf("%s\nError: %v", detailsMsg, err)
+			h.Logger.Errorf("Failed to check required labels: %v", err)
 		}
+		if !present {
+			h.react(ctx, client, prComment, reactionDenied)
+			detailsMsg := fmt.Sprintf("Beep, boop 🤖: To proceed, the pull request must have one of the '%v' labels.", prComment.repo.RequiredLabels)
+			if err != nil {
+				detailsMsg = fmt.Sprintf("%s\nError: %v", detailsMsg, err)
+			}
 
-		params.CheckSummary = LabelsNotFound
-		params.CheckDetails = detailsMsg
+			params.CheckSummary = LabelsNotFound
+			params.CheckDetails = detailsMsg
 
-		return util.PostPullRequestCheck(ctx, client, params)
-	}
-
-	return h.queueGenerateJob(ctx, client, prComment, "generate")
-}
-
-func (h *PRCommentHandler) precheckCommand(ctx context.Context, client *github.Client, prComment *PRComment) error {
-	h.Logger.Infof("Precheck command received on %s/%s#%d by %s",
-		prComment.repoOwner, prComment.repoName, prComment.prNum, prComment.author)
-
-	params := util.PullRequestStatusParams{
-		Status:     common.CheckComplete,
-		Conclusion: common.CheckStatusFailure,
-		CheckName:  common.PrecheckCheck,
-		RepoOwner:  prComment.repoOwner,
-		RepoName:   prComment.repoName,
-		PrNum:      prComment.prNum,
-		PrSha:      prComment.prSha,
-	}
-
-	// Check if user is part of the teams that are allowed to enable the bot
-	isAllowed := h.checkAuthorPermission(ctx, client, prComment)
-	if !isAllowed {
-		params.Comment = fmt.Sprintf("User %s is not allowed to run the InstructLab bot. Only %v teams are allowed to access the bot functions.", prComment.author, h.Maintainers)
-
-		err := util.PostPullRequestComment(ctx, client, params)
-		if err != nil {
-			h.Logger.Errorf("Failed to post comment on PR %s/%s#%d: %v", prComment.repoOwner, prComment.repoName, prComment.prNum, err)
+			_, err = util.PostPullRequestCheck(ctx, client, params)
 			return err
 		}
-		return nil
 	}
 
-	present, err := util.CheckRequiredLabel(prComment.labels, h.RequiredLabels)
-	if err != nil {
-		h.Logger.Errorf("Failed to check required labels: %v", err)
-	}
-	if !present {
-		detailsMsg := fmt.Sprintf("Beep, boop 🤖: To proceed, the pull request must have one of the '%v' labels.", h.RequiredLabels)
+	if spec.DenyKnowledge {
+		present, err := util.CheckKnowledgeLabel(prComment.labels)
 		if err != nil {
-			detailsMsg = fmt.Sprintf("%s\nError: %v", detailsMsg, err)
+			h.Logger.Errorf("Failed to check knowledge label: %v", err)
 		}
+		if present {
+			h.react(ctx, client, prComment, reactionDenied)
+			detailsMsg := fmt.Sprintf("Beep, boop 🤖: Bot does not allow to run %s on the knowledge contribution.", spec.Command)
 
-		params.CheckSummary = LabelsNotFound
-		params.CheckDetails = detailsMsg
-
-		return util.PostPullRequestCheck(ctx, client, params)
-	}
-
-	return h.queueGenerateJob(ctx, client, prComment, "precheck")
-}
-
-func (h *PRCommentHandler) sdgSvcCommand(ctx context.Context, client *github.Client, prComment *PRComment) error {
-	h.Logger.Infof("SDG svc command received on %s/%s#%d by %s",
-		prComment.repoOwner, prComment.repoName, prComment.prNum, prComment.author)
+			if h.acknowledgeWithComments() {
+				botComment := github.IssueComment{
+					Body: &detailsMsg,
+				}
 
-	params := util.PullRequestStatusParams{
-		Status:     common.CheckComplete,
-		Conclusion: common.CheckStatusFailure,
-		CheckName:  common.GenerateSDGCheck,
-		RepoOwner:  prComment.repoOwner,
-		RepoName:   prComment.repoName,
-		PrNum:      prComment.prNum,
-		PrSha:      prComment.prSha,
-	}
+				if _, _, err := client.Issues.CreateComment(ctx, prComment.repoOwner, prComment.repoName, prComment.prNum, &botComment); err != nil {
+					h.Logger.Errorf("Failed to comment on pull request: %v", err)
+				}
+			}
 
-	// Check if user is part of the teams that are allowed to enable the bot
-	isAllowed := h.checkAuthorPermission(ctx, client, prComment)
-	if !isAllowed {
-		params.Comment = fmt.Sprintf("User %s is not allowed to run the InstructLab bot. Only %v teams are allowed to access the bot functions.", prComment.author, h.Maintainers)
+			params.CheckSummary = NotAllowed
+			params.CheckDetails = detailsMsg
 
-		err := util.PostPullRequestComment(ctx, client, params)
-		if err != nil {
-			h.Logger.Errorf("Failed to post comment on PR %s/%s#%d: %v", prComment.repoOwner, prComment.repoName, prComment.prNum, err)
+			_, err = util.PostPullRequestCheck(ctx, client, params)
 			return err
 		}
-		return nil
-	}
-
-	present, err := util.CheckRequiredLabel(prComment.labels, h.RequiredLabels)
-	if err != nil {
-		h.Logger.Errorf("Failed to check required labels: %v", err)
 	}
-	if !present {
-		detailsMsg := fmt.Sprintf("Beep, boop 🤖: To proceed, the pull request must have one of the '%v' labels.", h.RequiredLabels)
-		if err != nil {
-			detailsMsg = fmt.Sprintf("%s\nError: %v", detailsMsg, err)
-		}
-
-		params.CheckSummary = LabelsNotFound
-		params.CheckDetails = detailsMsg
 
-		return util.PostPullRequestCheck(ctx, client, params)
-	}
+	return h.queueGenerateJob(ctx, client, prComment, spec.JobType, req)
+}
 
-	present, err = util.CheckKnowledgeLabel(prComment.labels)
-	if err != nil {
-		h.Logger.Errorf("Failed to check knowledge label: %v", err)
-	}
-	if present {
-		detailsMsg := "Beep, boop 🤖: Bot does not allow to run generate on the knowledge contribution."
+// jobRequest holds the options of a job command.
+type jobRequest struct {
+	priority jobs.Priority
+	args     jobs.Args
+}
 
-		botComment := github.IssueComment{
-			Body: &detailsMsg,
+// parseJobArgs reads the options of a job command, each given as either
+// "--name value" or "--name=value", and checks them against the limits. The
+// priority is empty when the option is absent.
+func parseJobArgs(spec util.JobTypeSpec, args []string, limits JobArgLimits) (jobRequest, error) {
+	var req jobRequest
+	for i := 0; i < len(args); i++ {
+		name, value, hasValue := strings.Cut(strings.TrimPrefix(args[i], "--"), "=")
+		if !strings.HasPrefix(args[i], "--") || name == "" {
+			return req, fmt.Errorf("unexpected argument %q", args[i])
 		}
-
-		if _, _, err := client.Issues.CreateComment(ctx, prComment.repoOwner, prComment.repoName, prComment.prNum, &botComment); err != nil {
-			h.Logger.Error("Failed to comment on pull request: %w", err)
+		if !spec.AcceptsOption(name) {
+			return req, fmt.Errorf("unknown option --%s", name)
+		}
+		if !hasValue {
+			if i+1 == len(args) {
+				return req, fmt.Errorf("--%s needs a value", name)
+			}
+			i++
+			value = args[i]
 		}
 
-		params.CheckSummary = NotAllowed
-		params.CheckDetails = detailsMsg
+		if name == util.OptionPriority {
+			priority, err := jobs.ParsePriority(value)
+			if err != nil {
+				return req, err
+			}
+			req.priority = priority
+			continue
+		}
+		if err := req.args.Set(name, value); err != nil {
+			return req, err
+		}
+	}
 
-		return util.PostPullRequestCheck(ctx, client, params)
+	switch {
+	case req.args.NumInstructions > limits.MaxNumInstructions:
+		return req, fmt.Errorf("--%s is limited to %d", jobs.ArgNumInstructions, limits.MaxNumInstructions)
+	case req.args.MaxSeed > limits.MaxSeed:
+		return req, fmt.Errorf("--%s is limited to %d", jobs.ArgMaxSeed, limits.MaxSeed)
+	case len(req.args.Files) > limits.MaxFiles:
+		return req, fmt.Errorf("--%s is limited to %d files", jobs.ArgFiles, limits.MaxFiles)
+	case req.args.Model != "" && len(limits.Models) == 0:
+		return req, fmt.Errorf("--%s is not enabled on this bot", jobs.ArgModel)
+	case req.args.Model != "" && !slices.Contains(limits.Models, req.args.Model):
+		return req, fmt.Errorf("model %q is not allowed, expected one of %s", req.args.Model, strings.Join(limits.Models, ", "))
 	}
+	return req, nil
+}
 
-	return h.queueGenerateJob(ctx, client, prComment, "sdg-svc")
+// disabledCommand answers a job command that is not enabled on the
+// repository of the PR.
+func (h *PRCommentHandler) disabledCommand(ctx context.Context, client *github.Client, prComment *PRComment, command string) error {
+	h.Logger.Infof("Disabled command %s received on %s/%s#%d by %s", command,
+		prComment.repoOwner, prComment.repoName, prComment.prNum, prComment.author)
+	if h.refuseWithReaction(ctx, client, prComment) {
+		return nil
+	}
+	params := util.PullRequestStatusParams{
+		RepoOwner: prComment.repoOwner,
+		RepoName:  prComment.repoName,
+		PrNum:     prComment.prNum,
+	}
+	params.Comment = fmt.Sprintf("Beep, boop 🤖, The `%s` command is not enabled on this repository.", command)
+	return h.postComment(ctx, client, params)
 }
 
 func (h *PRCommentHandler) unknownCommand(ctx context.Context, client *github.Client, prComment *PRComment) error {
 	h.Logger.Infof("Unknown command received on %s/%s#%d by %s",
 		prComment.repoOwner, prComment.repoName, prComment.prNum, prComment.author)
+	if h.refuseWithReaction(ctx, client, prComment) {
+		return nil
+	}
 
 	msg := "Beep, boop 🤖  Sorry, I don't understand that command"
 	botComment := github.IssueComment{
@@ -480,7 +543,7 @@ func (h *PRCommentHandler) unknownCommand(ctx context.Context, client *github.Cl
 	}
 
 	if _, _, err := client.Issues.CreateComment(ctx, prComment.repoOwner, prComment.repoName, prComment.prNum, &botComment); err != nil {
-		h.Logger.Error("Failed to comment on pull request: %w", err)
+		h.Logger.Errorf("Failed to comment on pull request: %v", err)
 		return err
 	}
 
@@ -0,0 +1,352 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/instructlab/instructlab-bot/pkg/history"
+	"github.com/instructlab/instructlab-bot/pkg/jobs"
+	"go.uber.org/zap"
+)
+
+const (
+	// JobsAPIRoute is the route of the job API, a job is served under
+	// JobsAPIRoute/<id> and its status transitions under
+	// JobsAPIRoute/<id>/events.
+	JobsAPIRoute = "/api/jobs"
+	// PRsAPIRoute serves the jobs of a pull request under
+	// PRsAPIRoute/<owner>/<repo>/<number>/jobs.
+	PRsAPIRoute = "/api/prs"
+
+	// eventCancelRequested names the events of a cancellation request, sent
+	// before the job reaches the cancelled status.
+	eventCancelRequested = "cancel_requested"
+
+	// eventsKeepAlive is how often an idle event stream sends a comment, so
+	// proxies do not close it.
+	eventsKeepAlive = 15 * time.Second
+
+	defaultJobsLimit = 100
+	maxJobsLimit     = 1000
+)
+
+// JobEvents streams the status transitions of the jobs.
+type JobEvents interface {
+	Subscribe(ctx context.Context) (<-chan jobs.Event, error)
+}
+
+// JobsAPIHandler serves the jobs known to the bot: the jobs still in Redis and
+// the finished jobs recorded in the history database.
+type JobsAPIHandler struct {
+	Logger   *zap.SugaredLogger
+	JobStore jobs.JobStore
+	Events   JobEvents
+	History  history.Store
+}
+
+// JobResponse is the JSON representation of a job.
+type JobResponse struct {
+	ID           string     `json:"id"`
+	RepoOwner    string     `json:"repo_owner"`
+	RepoName     string     `json:"repo_name"`
+	PRNumber     int        `json:"pr_number"`
+	PRSHA        string     `json:"pr_sha"`
+	Author       string     `json:"author"`
+	JobType      string     `json:"job_type"`
+	Args         string     `json:"args,omitempty"`
+	Priority     string     `json:"priority"`
+	Status       string     `json:"status"`
+	Worker       string     `json:"worker,omitempty"`
+	Attempts     int        `json:"attempts"`
+	RequestTime  *time.Time `json:"request_time,omitempty"`
+	StartTime    *time.Time `json:"start_time,omitempty"`
+	FinishTime   *time.Time `json:"finish_time,omitempty"`
+	Duration     int64      `json:"duration_seconds"`
+	ModelName    string     `json:"model_name,omitempty"`
+	Cmd          string     `json:"cmd,omitempty"`
+	S3URL        string     `json:"s3_url,omitempty"`
+	Errors       string     `json:"errors,omitempty"`
+	CancelReason string     `json:"cancel_reason,omitempty"`
+}
+
+func newJobResponse(job *jobs.Job) JobResponse {
+	optionalTime := func(t time.Time) *time.Time {
+		if t.IsZero() {
+			return nil
+		}
+		t = t.UTC()
+		return &t
+	}
+	return JobResponse{
+		ID:           job.ID,
+		RepoOwner:    job.RepoOwner,
+		RepoName:     job.RepoName,
+		PRNumber:     job.PRNumber,
+		PRSHA:        job.PRSHA,
+		Author:       job.Author,
+		JobType:      job.JobType,
+		Args:         job.Args,
+		Priority:     string(job.Priority),
+		Status:       string(job.Status),
+		Worker:       job.Worker,
+		Attempts:     job.Attempts,
+		RequestTime:  optionalTime(job.RequestTime),
+		StartTime:    optionalTime(job.StartTime),
+		FinishTime:   optionalTime(job.FinishTime),
+		Duration:     int64(job.Duration.Seconds()),
+		ModelName:    job.ModelName,
+		Cmd:          job.Cmd,
+		S3URL:        job.S3URL,
+		Errors:       job.Errors,
+		CancelReason: job.CancelReason,
+	}
+}
+
+// ServeHTTP routes the requests of JobsAPIRoute and PRsAPIRoute.
+func (h *JobsAPIHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if rest, ok := cutRoute(r.URL.Path, PRsAPIRoute); ok {
+		parts := strings.Split(rest, "/")
+		if len(parts) != 4 || parts[3] != "jobs" {
+			http.NotFound(w, r)
+			return
+		}
+		prNum, err := strconv.Atoi(parts[2])
+		if err != nil || prNum <= 0 {
+			http.Error(w, fmt.Sprintf("Invalid pull request number %q", parts[2]), http.StatusBadRequest)
+			return
+		}
+		h.listPRJobs(w, r, parts[0], parts[1], prNum)
+		return
+	}
+
+	rest, ok := cutRoute(r.URL.Path, JobsAPIRoute)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	switch parts := strings.Split(rest, "/"); {
+	case rest == "":
+		h.listJobs(w, r)
+	case len(parts) == 1:
+		h.getJob(w, r, parts[0])
+	case len(parts) == 2 && parts[1] == "events":
+		h.streamEvents(w, r, parts[0])
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// cutRoute returns the path below route, without its surrounding slashes.
+func cutRoute(path, route string) (string, bool) {
+	if path != route && !strings.HasPrefix(path, route+"/") {
+		return "", false
+	}
+	return strings.Trim(strings.TrimPrefix(path, route), "/"), true
+}
+
+// getJob returns a job from Redis while it is known there, and from the
+// history database afterwards.
+func (h *JobsAPIHandler) getJob(w http.ResponseWriter, r *http.Request, id string) {
+	job, err := h.JobStore.Get(r.Context(), id)
+	if errors.Is(err, jobs.ErrNotFound) && h.History != nil {
+		job, err = h.History.Get(r.Context(), id)
+	}
+	if errors.Is(err, jobs.ErrNotFound) {
+		http.Error(w, fmt.Sprintf("Job %s not found", id), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		h.Logger.Errorf("Failed to read job %s: %v", id, err)
+		http.Error(w, "Error reading job", http.StatusInternalServerError)
+		return
+	}
+	h.writeJSON(w, newJobResponse(job))
+}
+
+// listPRJobs returns the jobs of a pull request still in Redis, oldest first.
+func (h *JobsAPIHandler) listPRJobs(w http.ResponseWriter, r *http.Request, repoOwner, repoName string, prNum int) {
+	prJobs, err := h.JobStore.ListPR(r.Context(), repoOwner, repoName, prNum)
+	if err != nil {
+		h.Logger.Errorf("Failed to list jobs of PR %s/%s#%d: %v", repoOwner, repoName, prNum, err)
+		http.Error(w, "Error listing jobs", http.StatusInternalServerError)
+		return
+	}
+	response := make([]JobResponse, 0, len(prJobs))
+	for _, job := range prJobs {
+		response = append(response, newJobResponse(job))
+	}
+	h.writeJSON(w, response)
+}
+
+// streamEvents sends the status transitions of a job as Server-Sent Events,
+// starting with its current status, until the job finishes or the client
+// goes away.
+func (h *JobsAPIHandler) streamEvents(w http.ResponseWriter, r *http.Request, id string) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming is not supported", http.StatusInternalServerError)
+		return
+	}
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	// Subscribe before reading the job so no transition is missed
+	events, err := h.Events.Subscribe(ctx)
+	if err != nil {
+		h.Logger.Errorf("Failed to subscribe to job events: %v", err)
+		http.Error(w, "Error subscribing to job events", http.StatusInternalServerError)
+		return
+	}
+	job, err := h.JobStore.Get(ctx, id)
+	if errors.Is(err, jobs.ErrNotFound) {
+		http.Error(w, fmt.Sprintf("Job %s not found", id), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		h.Logger.Errorf("Failed to read job %s: %v", id, err)
+		http.Error(w, "Error reading job", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+
+	status := job.Status
+	if err := writeEvent(w, jobs.Event{JobID: id, Status: status}); err != nil {
+		return
+	}
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(eventsKeepAlive)
+	defer keepAlive.Stop()
+	for finished := !job.Active(); !finished; {
+		select {
+		case <-ctx.Done():
+			return
+		case <-keepAlive.C:
+			if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+				return
+			}
+		case event, ok := <-events:
+			if !ok {
+				return
+			}
+			if event.JobID != id || (event.Status == status && !event.CancelRequested()) {
+				continue
+			}
+			status = event.Status
+			if err := writeEvent(w, event); err != nil {
+				return
+			}
+			finished = status != jobs.StatusPending && status != jobs.StatusRunning
+		}
+		flusher.Flush()
+	}
+}
+
+// writeEvent writes a job event in the Server-Sent Events format, named
+// after the status of the job, or eventCancelRequested.
+func writeEvent(w io.Writer, event jobs.Event) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	name := string(event.Status)
+	if event.CancelRequested() {
+		name = eventCancelRequested
+	}
+	_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", name, data)
+	return err
+}
+
+// listJobs returns the finished jobs of the history database matching the
+// query parameters owner, repo, pr, author, type, status, since, until and
+// limit.
+func (h *JobsAPIHandler) listJobs(w http.ResponseWriter, r *http.Request) {
+	if h.History == nil {
+		http.Error(w, "The job history database is not configured", http.StatusServiceUnavailable)
+		return
+	}
+	filter, err := parseJobsFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	list, err := h.History.List(r.Context(), filter)
+	if err != nil {
+		h.Logger.Errorf("Failed to list job history: %v", err)
+		http.Error(w, "Error listing jobs", http.StatusInternalServerError)
+		return
+	}
+	response := make([]JobResponse, 0, len(list))
+	for _, job := range list {
+		response = append(response, newJobResponse(job))
+	}
+	h.writeJSON(w, response)
+}
+
+func (h *JobsAPIHandler) writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		h.Logger.Warnf("Failed to write job API response: %v", err)
+	}
+}
+
+func parseJobsFilter(r *http.Request) (history.Filter, error) {
+	query := r.URL.Query()
+	filter := history.Filter{
+		RepoOwner: query.Get("owner"),
+		RepoName:  query.Get("repo"),
+		Author:    query.Get("author"),
+		JobType:   query.Get("type"),
+		Status:    jobs.Status(query.Get("status")),
+		Limit:     defaultJobsLimit,
+	}
+
+	var err error
+	if pr := query.Get("pr"); pr != "" {
+		if filter.PRNumber, err = strconv.Atoi(pr); err != nil || filter.PRNumber <= 0 {
+			return filter, fmt.Errorf("invalid pr %q", pr)
+		}
+	}
+	if limit := query.Get("limit"); limit != "" {
+		if filter.Limit, err = strconv.Atoi(limit); err != nil || filter.Limit <= 0 || filter.Limit > maxJobsLimit {
+			return filter, fmt.Errorf("invalid limit %q, expected 1 to %d", limit, maxJobsLimit)
+		}
+	}
+	if filter.Since, err = parseDate(query.Get("since")); err != nil {
+		return filter, fmt.Errorf("invalid since: %w", err)
+	}
+	if filter.Until, err = parseDate(query.Get("until")); err != nil {
+		return filter, fmt.Errorf("invalid until: %w", err)
+	}
+	return filter, nil
+}
+
+// parseDate accepts an RFC 3339 time or a YYYY-MM-DD date.
+func parseDate(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse(time.DateOnly, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%q is neither an RFC 3339 time nor a YYYY-MM-DD date", value)
+	}
+	return t, nil
+}
@@ -0,0 +1,290 @@
+package handlers
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/instructlab/instructlab-bot/pkg/history"
+	"github.com/instructlab/instructlab-bot/pkg/jobs"
+	"go.uber.org/zap"
+)
+
+// newTestJobsAPI returns a job API serving the jobs of an in-memory Redis and
+// of the history database, if any, and the store of the jobs.
+func newTestJobsAPI(t *testing.T, historyStore history.Store) (*httptest.Server, *jobs.RedisStore) {
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { rdb.Close() })
+	store := jobs.NewRedisStore(rdb)
+	server := httptest.NewServer(&JobsAPIHandler{
+		Logger:   zap.NewNop().Sugar(),
+		JobStore: store,
+		Events:   store,
+		History:  historyStore,
+	})
+	t.Cleanup(server.Close)
+	return server, store
+}
+
+func getJSON(t *testing.T, url string, v any) int {
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusOK {
+		if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+			t.Fatalf("Invalid response of %s: %v", url, err)
+		}
+	}
+	return resp.StatusCode
+}
+
+func TestJobsAPIGetJob(t *testing.T) {
+	ctx := context.Background()
+	server, store := newTestJobsAPI(t, nil)
+	id, err := store.Create(ctx, &jobs.Job{PRNumber: 7, RepoOwner: "acme", RepoName: "taxonomy", JobType: jobs.TypePrecheck})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var job JobResponse
+	if code := getJSON(t, server.URL+JobsAPIRoute+"/"+id, &job); code != http.StatusOK {
+		t.Fatalf("GET job %s = %d, want %d", id, code, http.StatusOK)
+	}
+	if job.ID != id || job.PRNumber != 7 || job.Status != string(jobs.StatusPending) {
+		t.Errorf("Got job %+v, want pending job %s of PR 7", job, id)
+	}
+
+	if code := getJSON(t, server.URL+JobsAPIRoute+"/404", &job); code != http.StatusNotFound {
+		t.Errorf("GET unknown job = %d, want %d", code, http.StatusNotFound)
+	}
+	if code := getJSON(t, server.URL+JobsAPIRoute+"/404/events", nil); code != http.StatusNotFound {
+		t.Errorf("GET events of unknown job = %d, want %d", code, http.StatusNotFound)
+	}
+}
+
+func TestJobsAPIListPRJobs(t *testing.T) {
+	ctx := context.Background()
+	server, store := newTestJobsAPI(t, nil)
+	var want []string
+	for _, job := range []*jobs.Job{
+		{PRNumber: 7, RepoOwner: "acme", RepoName: "taxonomy", JobType: jobs.TypePrecheck},
+		{PRNumber: 8, RepoOwner: "acme", RepoName: "taxonomy", JobType: jobs.TypePrecheck},
+		{PRNumber: 7, RepoOwner: "other", RepoName: "taxonomy", JobType: jobs.TypePrecheck},
+		{PRNumber: 7, RepoOwner: "acme", RepoName: "taxonomy", JobType: jobs.TypeSDG},
+	} {
+		id, err := store.Create(ctx, job)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if job.PRNumber == 7 && job.RepoOwner == "acme" {
+			want = append(want, id)
+		}
+	}
+
+	var list []JobResponse
+	if code := getJSON(t, server.URL+PRsAPIRoute+"/acme/taxonomy/7/jobs", &list); code != http.StatusOK {
+		t.Fatalf("GET jobs of PR = %d, want %d", code, http.StatusOK)
+	}
+	var got []string
+	for _, job := range list {
+		got = append(got, job.ID)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Jobs of acme/taxonomy#7 = %v, want %v", got, want)
+	}
+
+	if code := getJSON(t, server.URL+PRsAPIRoute+"/acme/taxonomy/9/jobs", &list); code != http.StatusOK || len(list) != 0 {
+		t.Errorf("GET jobs of a PR without jobs = %d %v, want %d and no job", code, list, http.StatusOK)
+	}
+	if code := getJSON(t, server.URL+PRsAPIRoute+"/acme/taxonomy/seven/jobs", nil); code != http.StatusBadRequest {
+		t.Errorf("GET jobs of an invalid PR = %d, want %d", code, http.StatusBadRequest)
+	}
+}
+
+// readEvents returns the names of the Server-Sent Events of a stream, until
+// the server ends it.
+func readEvents(t *testing.T, resp *http.Response) []string {
+	var names []string
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		if name, ok := strings.CutPrefix(scanner.Text(), "event: "); ok {
+			names = append(names, name)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatalf("Reading the event stream: %v", err)
+	}
+	return names
+}
+
+func TestJobsAPIStreamEvents(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	server, store := newTestJobsAPI(t, nil)
+	id, err := store.Create(ctx, &jobs.Job{PRNumber: 7, JobType: jobs.TypePrecheck})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, server.URL+JobsAPIRoute+"/"+id+"/events", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if got := resp.Header.Get("Content-Type"); got != "text/event-stream" {
+		t.Errorf("Content-Type = %q, want text/event-stream", got)
+	}
+
+	// The stream is subscribed once its first event is sent. A repeated
+	// status is skipped, a cancellation request is sent and the stream ends
+	// on the terminal status.
+	for _, update := range []func() error{
+		func() error { return store.UpdateStatus(ctx, id, jobs.StatusPending) },
+		func() error { return store.UpdateStatus(ctx, id, jobs.StatusRunning) },
+		func() error { return store.UpdateStatus(ctx, id, jobs.StatusRunning) },
+		func() error { return store.Cancel(ctx, id, "cancelled by @alice") },
+		func() error { return store.UpdateStatus(ctx, id, jobs.StatusCancelled) },
+	} {
+		if err := update(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want := []string{"pending", "running", eventCancelRequested, "cancelled"}
+	if got := readEvents(t, resp); !reflect.DeepEqual(got, want) {
+		t.Errorf("Events = %v, want %v", got, want)
+	}
+
+	// The stream of a finished job only sends its status
+	resp, err = http.Get(server.URL + JobsAPIRoute + "/" + id + "/events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if got := readEvents(t, resp); !reflect.DeepEqual(got, []string{"cancelled"}) {
+		t.Errorf("Events of a finished job = %v, want [cancelled]", got)
+	}
+}
+
+func TestParseJobsFilter(t *testing.T) {
+	day := time.Date(2024, 5, 29, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		query   string
+		want    history.Filter
+		wantErr bool
+	}{
+		{"", history.Filter{Limit: defaultJobsLimit}, false},
+		{"owner=acme&repo=taxonomy&pr=7&author=alice&type=precheck&status=error",
+			history.Filter{RepoOwner: "acme", RepoName: "taxonomy", PRNumber: 7, Author: "alice",
+				JobType: jobs.TypePrecheck, Status: jobs.StatusError, Limit: defaultJobsLimit}, false},
+		{"since=2024-05-29&until=2024-05-30T12:00:00Z",
+			history.Filter{Since: day, Until: day.Add(36 * time.Hour), Limit: defaultJobsLimit}, false},
+		{"limit=5", history.Filter{Limit: 5}, false},
+		{"limit=1000", history.Filter{Limit: maxJobsLimit}, false},
+		{"limit=0", history.Filter{}, true},
+		{"limit=1001", history.Filter{}, true},
+		{"limit=ten", history.Filter{}, true},
+		{"pr=0", history.Filter{}, true},
+		{"pr=seven", history.Filter{}, true},
+		{"since=yesterday", history.Filter{}, true},
+		{"until=2024-13-01", history.Filter{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			got, err := parseJobsFilter(httptest.NewRequest(http.MethodGet, JobsAPIRoute+"?"+tt.query, nil))
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("parseJobsFilter(%q) = %+v, want an error", tt.query, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseJobsFilter(%q): %v", tt.query, err)
+			}
+			if !got.Since.Equal(tt.want.Since) || !got.Until.Equal(tt.want.Until) {
+				t.Errorf("parseJobsFilter(%q) dates = %v, %v, want %v, %v", tt.query, got.Since, got.Until, tt.want.Since, tt.want.Until)
+			}
+			got.Since, got.Until = tt.want.Since, tt.want.Until
+			if got != tt.want {
+				t.Errorf("parseJobsFilter(%q) = %+v, want %+v", tt.query, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestJobsAPIListJobs(t *testing.T) {
+	ctx := context.Background()
+	server, _ := newTestJobsAPI(t, nil)
+	if code := getJSON(t, server.URL+JobsAPIRoute, nil); code != http.StatusServiceUnavailable {
+		t.Errorf("GET jobs without a history database = %d, want %d", code, http.StatusServiceUnavailable)
+	}
+
+	historyStore, err := history.Open(ctx, history.DriverSQLite, filepath.Join(t.TempDir(), "history.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { historyStore.Close() })
+	day := time.Date(2024, 5, 29, 0, 0, 0, 0, time.UTC)
+	for _, job := range []*jobs.Job{
+		{ID: "1", RepoOwner: "acme", RepoName: "taxonomy", PRNumber: 7, Author: "alice", JobType: jobs.TypePrecheck, Status: jobs.StatusSuccess, RequestTime: day},
+		{ID: "2", RepoOwner: "acme", RepoName: "taxonomy", PRNumber: 7, Author: "alice", JobType: jobs.TypeSDG, Status: jobs.StatusError, RequestTime: day.Add(time.Hour)},
+		{ID: "3", RepoOwner: "acme", RepoName: "taxonomy", PRNumber: 8, Author: "bob", JobType: jobs.TypePrecheck, Status: jobs.StatusCancelled, RequestTime: day.Add(48 * time.Hour)},
+	} {
+		if err := historyStore.Record(ctx, job); err != nil {
+			t.Fatal(err)
+		}
+	}
+	server, _ = newTestJobsAPI(t, historyStore)
+
+	for _, tt := range []struct {
+		query string
+		want  []string
+	}{
+		{"", []string{"3", "2", "1"}},
+		{"?owner=acme&repo=taxonomy&pr=7", []string{"2", "1"}},
+		{"?author=bob", []string{"3"}},
+		{"?type=precheck", []string{"3", "1"}},
+		{"?status=error", []string{"2"}},
+		{"?since=2024-05-29T00:01:00Z&until=2024-05-30", []string{"2"}},
+		{"?limit=1", []string{"3"}},
+	} {
+		var list []JobResponse
+		if code := getJSON(t, server.URL+JobsAPIRoute+tt.query, &list); code != http.StatusOK {
+			t.Errorf("GET jobs%s = %d, want %d", tt.query, code, http.StatusOK)
+			continue
+		}
+		got := []string{}
+		for _, job := range list {
+			got = append(got, job.ID)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("GET jobs%s = %v, want %v", tt.query, got, tt.want)
+		}
+	}
+	for _, query := range []string{"?limit=0", "?pr=seven", "?since=yesterday"} {
+		if code := getJSON(t, server.URL+JobsAPIRoute+query, nil); code != http.StatusBadRequest {
+			t.Errorf("GET jobs%s = %d, want %d", query, code, http.StatusBadRequest)
+		}
+	}
+
+	// A job gone from Redis is served from the history database
+	var job JobResponse
+	if code := getJSON(t, server.URL+JobsAPIRoute+"/2", &job); code != http.StatusOK || job.Status != string(jobs.StatusError) {
+		t.Errorf("GET recorded job = %d %+v, want %d and the recorded job", code, job, http.StatusOK)
+	}
+}
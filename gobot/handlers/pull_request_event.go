@@ -3,10 +3,13 @@ package handlers
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 
 	"github.com/google/go-github/v61/github"
 	"github.com/instructlab/instructlab-bot/gobot/common"
 	"github.com/instructlab/instructlab-bot/gobot/util"
+	"github.com/instructlab/instructlab-bot/pkg/history"
+	"github.com/instructlab/instructlab-bot/pkg/jobs"
 	"github.com/palantir/go-githubapp/githubapp"
 	"github.com/pkg/errors"
 	"go.uber.org/zap"
@@ -14,10 +17,11 @@ import (
 
 type PullRequestEventHandler struct {
 	githubapp.ClientCreator
-	Logger         *zap.SugaredLogger
-	RequiredLabels []string
-	BotUsername    string
-	Maintainers    []string
+	Logger      *zap.SugaredLogger
+	JobStore    jobs.JobStore
+	Archiver    *history.Archiver
+	Repos       *util.RepoStore
+	BotUsername string
 }
 
 func (h *PullRequestEventHandler) Handles() []string {
@@ -30,31 +34,40 @@ func (h *PullRequestEventHandler) Handle(ctx context.Context, eventType, deliver
 		return errors.Wrap(err, "failed to parse issue comment event payload")
 	}
 
-	if event.GetRepo().GetName() != common.RepoName {
+	repo := event.GetRepo()
+	repoConfig, ok := h.Repos.Lookup(repo.GetOwner().GetLogin(), repo.GetName())
+	if !ok {
 		h.Logger.Warnf("Received unexpected event %s from %s/%s repo. Skipping the event.",
-			eventType, event.GetOrganization().GetLogin(), event.GetRepo().GetName())
+			eventType, repo.GetOwner().GetLogin(), repo.GetName())
+		return nil
+	}
+
+	if event.GetPullRequest().GetState() != "open" {
 		return nil
 	}
 
-	if event.GetPullRequest().GetState() != "open" || event.GetAction() != "labeled" {
+	switch event.GetAction() {
+	case "labeled":
+	case "synchronize":
+		return h.supersedeJobs(ctx, &event)
+	default:
 		return nil
 	}
 
 	h.Logger.Debugf("Received pull request event: %v", event)
 
 	installID := githubapp.GetInstallationIDFromEvent(&event)
-	repo := event.GetRepo()
 	repoOwner := repo.GetOwner().GetLogin()
 	repoName := repo.GetName()
 	prNum := event.GetPullRequest().GetNumber()
 	prSha := event.GetPullRequest().GetHead().GetSHA()
 
-	h.Logger.Infof("Checking for required labels: %v", h.RequiredLabels)
-	if len(h.RequiredLabels) == 0 {
+	h.Logger.Infof("Checking for required labels: %v", repoConfig.RequiredLabels)
+	if len(repoConfig.RequiredLabels) == 0 {
 		return nil
 	}
 
-	labelFound, err := util.CheckRequiredLabel(event.GetPullRequest().Labels, h.RequiredLabels)
+	labelFound, err := util.CheckRequiredLabel(event.GetPullRequest().Labels, repoConfig.RequiredLabels)
 	if err != nil {
 		h.Logger.Errorf("Failed to check required labels: %v", err)
 	}
@@ -88,10 +101,52 @@ func (h *PullRequestEventHandler) Handle(ctx context.Context, eventType, deliver
 		return nil
 	}
 
-	err = util.PostBotWelcomeMessage(ctx, client, repoOwner, repoName, prNum, prSha, h.BotUsername, h.Maintainers)
+	err = util.PostBotWelcomeMessage(ctx, client, repoOwner, repoName, prNum, prSha, h.BotUsername, repoConfig)
 	if err != nil {
 		h.Logger.Errorf("Failed to post bot welcome message on PR %s/%s#%d: %v", repoOwner, repoName, prNum, err)
 		return err
 	}
 	return nil
 }
+
+// supersedeJobs ends the queued jobs of a pull request that were requested
+// for a commit older than its new head as superseded.
+func (h *PullRequestEventHandler) supersedeJobs(ctx context.Context, event *github.PullRequestEvent) error {
+	repo := event.GetRepo()
+	repoOwner := repo.GetOwner().GetLogin()
+	repoName := repo.GetName()
+	prNum := event.GetPullRequest().GetNumber()
+	prSha := event.GetPullRequest().GetHead().GetSHA()
+
+	prJobs, err := activePRJobs(ctx, h.JobStore, repoOwner, repoName, prNum)
+	if err != nil {
+		h.Logger.Errorf("Failed to list jobs of PR %s/%s#%d: %v", repoOwner, repoName, prNum, err)
+		return err
+	}
+
+	var stale []*jobs.Job
+	for _, job := range prJobs {
+		if job.Status == jobs.StatusPending && job.PRSHA != prSha {
+			stale = append(stale, job)
+		}
+	}
+	if len(stale) == 0 {
+		return nil
+	}
+
+	client, err := h.NewInstallationClient(githubapp.GetInstallationIDFromEvent(event))
+	if err != nil {
+		h.Logger.Errorf("Failed to create installation client: %v", err)
+		return err
+	}
+
+	reason := fmt.Sprintf("superseded by commit %s", prSha)
+	for _, job := range stale {
+		if err := cancelJob(ctx, client, h.JobStore, h.Archiver, job, jobs.StatusSuperseded, reason); err != nil {
+			h.Logger.Errorf("Failed to cancel superseded job %s: %v", job.ID, err)
+			continue
+		}
+		h.Logger.Infof("Job %s on %s/%s#%d for commit %s %s", job.ID, repoOwner, repoName, prNum, job.PRSHA, reason)
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/go-github/v61/github"
+)
+
+// Comment modes, choosing how the bot acknowledges the commands of a comment:
+// with reactions to the comment, with reply comments, or both. The replies
+// carrying details, such as errors and results, are posted in every mode.
+const (
+	CommentModeReactions = "reactions"
+	CommentModeComments  = "comments"
+	CommentModeBoth      = "both"
+)
+
+// Reactions added to the comment of a command.
+const (
+	// reactionAccepted acknowledges a command the author is allowed to run.
+	reactionAccepted = "eyes"
+	// reactionQueued tells a job was queued for the command.
+	reactionQueued = "rocket"
+	// reactionDenied tells the command was refused.
+	reactionDenied = "-1"
+)
+
+// ValidateCommentMode checks a comment mode given in the configuration.
+func ValidateCommentMode(mode string) error {
+	switch mode {
+	case CommentModeReactions, CommentModeComments, CommentModeBoth:
+		return nil
+	}
+	return fmt.Errorf("unknown comment mode %q, expected %s, %s or %s", mode, CommentModeReactions, CommentModeComments, CommentModeBoth)
+}
+
+// acknowledgeWithComments reports whether commands are acknowledged with
+// reply comments.
+func (h *PRCommentHandler) acknowledgeWithComments() bool {
+	return h.CommentMode != CommentModeReactions
+}
+
+// react adds a reaction to the comment of a command, when the comment mode
+// uses reactions. Commands that did not come from a comment get none. A
+// reaction that can not be added is only logged.
+func (h *PRCommentHandler) react(ctx context.Context, client *github.Client, prComment *PRComment, reaction string) {
+	if prComment.commentID == 0 || (h.CommentMode != CommentModeReactions && h.CommentMode != CommentModeBoth) {
+		return
+	}
+	if _, _, err := client.Reactions.CreateIssueCommentReaction(ctx, prComment.repoOwner, prComment.repoName, prComment.commentID, reaction); err != nil {
+		h.Logger.Warnf("Failed to add reaction %s to comment %d on %s/%s#%d: %v", reaction, prComment.commentID,
+			prComment.repoOwner, prComment.repoName, prComment.prNum, err)
+	}
+}
+
+// refuseWithReaction adds the denied reaction to the comment of a refused
+// command, and reports whether that is the whole answer: in the reactions
+// mode, a command that came from a comment gets no reply comment.
+func (h *PRCommentHandler) refuseWithReaction(ctx context.Context, client *github.Client, prComment *PRComment) bool {
+	h.react(ctx, client, prComment, reactionDenied)
+	return !h.acknowledgeWithComments() && prComment.commentID != 0
+}
@@ -0,0 +1,73 @@
+package handlers
+
+import (
+	"context"
+	"reflect"
+	"slices"
+	"testing"
+
+	"github.com/instructlab/instructlab-bot/gobot/common"
+	"github.com/instructlab/instructlab-bot/gobot/util"
+	"github.com/instructlab/instructlab-bot/pkg/authz"
+)
+
+func TestRefusedCommandReplies(t *testing.T) {
+	const replyRoute = "POST /api/v3/repos/acme/taxonomy/issues/7/comments"
+	tests := []struct {
+		name          string
+		mode          string
+		comment       string
+		wantReactions []string
+		wantReply     bool
+	}{
+		{"disabled command with reactions", CommentModeReactions, "@instructlab-bot precheck", []string{reactionDenied}, false},
+		{"unknown command with reactions", CommentModeReactions, "@instructlab-bot frobnicate", []string{reactionDenied}, false},
+		{"disabled command with comments", CommentModeComments, "@instructlab-bot precheck", nil, true},
+		{"unknown command with comments", CommentModeComments, "@instructlab-bot frobnicate", nil, true},
+		{"disabled command with both", CommentModeBoth, "@instructlab-bot precheck", []string{reactionDenied}, true},
+		{"unknown command with both", CommentModeBoth, "@instructlab-bot frobnicate", []string{reactionDenied}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := newFakeGitHub(t)
+			defer func(web string) { util.WebURL = web }(util.WebURL)
+			util.WebURL = fake.URL
+			h, store, _ := newJobsCommentHandler(t, fake)
+			h.CommentMode = tt.mode
+			// Only the generate command is enabled, so precheck is refused
+			repos := util.Repos{{
+				Owner:    "acme",
+				Name:     common.RepoName,
+				Commands: []string{"generate"},
+				Policy:   &authz.Policy{Rules: []authz.Rule{{Users: []string{"alice"}}}},
+			}}
+			if err := repos.Prepare(nil); err != nil {
+				t.Fatal(err)
+			}
+			h.Repos = util.NewRepoStore(repos)
+
+			if err := h.Handle(context.Background(), "issue_comment", "delivery", issueCommentPayload(fake.URL, tt.comment)); err != nil {
+				t.Fatalf("Handle: %v", err)
+			}
+
+			prJobs, err := store.ListPR(context.Background(), "acme", "taxonomy", 7)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(prJobs) != 0 {
+				t.Errorf("Refused command queued %d jobs", len(prJobs))
+			}
+			fake.mu.Lock()
+			defer fake.mu.Unlock()
+			if !reflect.DeepEqual(fake.reactions, tt.wantReactions) {
+				t.Errorf("Reactions = %v, want %v", fake.reactions, tt.wantReactions)
+			}
+			if got := slices.Contains(fake.requests, replyRoute); got != tt.wantReply {
+				t.Errorf("Reply comment posted = %v, want %v", got, tt.wantReply)
+			}
+			if len(fake.unexpected) > 0 {
+				t.Errorf("Unexpected requests to the fake GitHub: %v", fake.unexpected)
+			}
+		})
+	}
+}
@@ -0,0 +1,67 @@
+package util
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// DefaultWebURL is the web address of github.com.
+const DefaultWebURL = "https://github.com"
+
+// WebURL is the address of the GitHub web interface, used in the links the
+// bot posts. It is set from the configuration at startup.
+var WebURL = DefaultWebURL
+
+// GitHubURLs are the addresses of a GitHub instance.
+type GitHubURLs struct {
+	// Web is the address of the web interface, and of the git repositories.
+	Web string
+	// V3API is the address of the REST API.
+	V3API string
+	// V4API is the address of the GraphQL API.
+	V4API string
+}
+
+// NewGitHubURLs derives the addresses of a GitHub instance from its web
+// address. github.com serves its APIs from api.github.com, while GitHub
+// Enterprise Server serves them from /api/v3/ and /api/graphql on its own
+// host. The API addresses given override the derived ones.
+func NewGitHubURLs(web, v3API, v4API string) (GitHubURLs, error) {
+	if web == "" {
+		web = DefaultWebURL
+	}
+	u, err := url.Parse(web)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return GitHubURLs{}, fmt.Errorf("invalid GitHub web URL %q", web)
+	}
+	web = strings.TrimSuffix(u.String(), "/")
+
+	urls := GitHubURLs{Web: web, V3API: v3API, V4API: v4API}
+	if strings.EqualFold(u.Host, "github.com") {
+		if urls.V3API == "" {
+			urls.V3API = "https://api.github.com/"
+		}
+		if urls.V4API == "" {
+			urls.V4API = "https://api.github.com/graphql"
+		}
+		return urls, nil
+	}
+	if urls.V3API == "" {
+		urls.V3API = web + "/api/v3/"
+	}
+	if urls.V4API == "" {
+		urls.V4API = web + "/api/graphql"
+	}
+	return urls, nil
+}
+
+// PullRequestURL returns the web address of a pull request.
+func PullRequestURL(owner, repo string, prNum int) string {
+	return fmt.Sprintf("%s/%s/%s/pull/%d", strings.TrimSuffix(WebURL, "/"), owner, repo, prNum)
+}
+
+// RepoGitURL returns the address a repository is cloned from.
+func RepoGitURL(owner, repo string) string {
+	return fmt.Sprintf("%s/%s/%s.git", strings.TrimSuffix(WebURL, "/"), owner, repo)
+}
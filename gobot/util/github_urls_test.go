@@ -0,0 +1,77 @@
+package util
+
+import "testing"
+
+func TestNewGitHubURLs(t *testing.T) {
+	tests := []struct {
+		name      string
+		web       string
+		v3API     string
+		v4API     string
+		want      GitHubURLs
+		wantError bool
+	}{
+		{
+			name: "github.com by default",
+			want: GitHubURLs{
+				Web:   "https://github.com",
+				V3API: "https://api.github.com/",
+				V4API: "https://api.github.com/graphql",
+			},
+		},
+		{
+			name: "enterprise server",
+			web:  "https://github.example.com/",
+			want: GitHubURLs{
+				Web:   "https://github.example.com",
+				V3API: "https://github.example.com/api/v3/",
+				V4API: "https://github.example.com/api/graphql",
+			},
+		},
+		{
+			name:  "explicit API URLs",
+			web:   "https://github.example.com",
+			v3API: "https://api.example.com/",
+			v4API: "https://api.example.com/graphql",
+			want: GitHubURLs{
+				Web:   "https://github.example.com",
+				V3API: "https://api.example.com/",
+				V4API: "https://api.example.com/graphql",
+			},
+		},
+		{
+			name:      "no scheme",
+			web:       "github.example.com",
+			wantError: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NewGitHubURLs(tt.web, tt.v3API, tt.v4API)
+			if tt.wantError {
+				if err == nil {
+					t.Fatalf("NewGitHubURLs(%q) = %+v, want an error", tt.web, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("NewGitHubURLs(%q): %v", tt.web, err)
+			}
+			if got != tt.want {
+				t.Errorf("NewGitHubURLs(%q) = %+v, want %+v", tt.web, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPullRequestURL(t *testing.T) {
+	defer func(web string) { WebURL = web }(WebURL)
+	WebURL = "https://github.example.com/"
+
+	if got, want := PullRequestURL("acme", "taxonomy", 7), "https://github.example.com/acme/taxonomy/pull/7"; got != want {
+		t.Errorf("PullRequestURL = %q, want %q", got, want)
+	}
+	if got, want := RepoGitURL("acme", "taxonomy"), "https://github.example.com/acme/taxonomy.git"; got != want {
+		t.Errorf("RepoGitURL = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,162 @@
+package util
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/instructlab/instructlab-bot/gobot/common"
+	"github.com/instructlab/instructlab-bot/pkg/jobs"
+)
+
+// JobTypeSpec describes a job type the bot queues in response to a command
+// on a pull request. Adding a command takes a RegisterJobType call here and a
+// runner for the job type in the worker.
+type JobTypeSpec struct {
+	// JobType is the type of the queued jobs, as understood by the worker.
+	JobType string
+	// Command is the word following the bot username that requests a job.
+	Command string
+	// CheckName is the name of the check run reporting the jobs.
+	CheckName string
+	// Help describes the command in the bot's help message.
+	Help string
+	// MaintainersOnly restricts the command to the members of the maintainer
+	// teams.
+	MaintainersOnly bool
+	// RequireLabels requires one of the bot's required labels on the PR.
+	RequireLabels bool
+	// DenyKnowledge rejects the command on knowledge contributions.
+	DenyKnowledge bool
+	// Options lists the job options accepted by the command, on top of
+	// --priority, as named in the jobs package.
+	Options []string
+}
+
+// optionUsage describes the value of each job option in the help message.
+var optionUsage = map[string]string{
+	jobs.ArgNumInstructions: "<count>",
+	jobs.ArgMaxSeed:         "<count>",
+	jobs.ArgModel:           "<model>",
+	jobs.ArgFiles:           "<path,...>",
+}
+
+// AcceptsOption reports whether the command takes the named option.
+func (s JobTypeSpec) AcceptsOption(name string) bool {
+	return name == OptionPriority || slices.Contains(s.Options, name)
+}
+
+// Usage returns the options of the command, as shown in the help message.
+func (s JobTypeSpec) Usage() string {
+	usage := []string{"[--" + OptionPriority + " high|normal|low]"}
+	for _, name := range s.Options {
+		usage = append(usage, fmt.Sprintf("[--%s %s]", name, optionUsage[name]))
+	}
+	return strings.Join(usage, " ")
+}
+
+// OptionPriority is the option overriding the priority class of a job. Every
+// job command accepts it.
+const OptionPriority = "priority"
+
+// jobTypeSpecs holds the registered job types, in the order they are listed
+// in the help message.
+var jobTypeSpecs []JobTypeSpec
+
+func init() {
+	RegisterJobType(JobTypeSpec{
+		JobType:         jobs.TypePrecheck,
+		Command:         "precheck",
+		CheckName:       common.PrecheckCheck,
+		Help:            "Check existing model behavior using the questions in this proposed change.",
+		MaintainersOnly: true,
+		RequireLabels:   true,
+		Options:         []string{jobs.ArgModel, jobs.ArgFiles},
+	})
+	RegisterJobType(JobTypeSpec{
+		JobType:         jobs.TypeSDG,
+		Command:         "generate",
+		CheckName:       common.GenerateSDGCheck,
+		Help:            "Generate a sample of synthetic data using the synthetic data generation backend infrastructure.",
+		MaintainersOnly: true,
+		RequireLabels:   true,
+		DenyKnowledge:   true,
+		Options:         []string{jobs.ArgNumInstructions, jobs.ArgMaxSeed, jobs.ArgFiles},
+	})
+	RegisterJobType(JobTypeSpec{
+		JobType:         jobs.TypeGenerateLocal,
+		Command:         "generate-local",
+		CheckName:       common.GenerateLocalCheck,
+		Help:            "Generate a sample of synthetic data using a local model.",
+		MaintainersOnly: true,
+		RequireLabels:   true,
+		Options:         []string{jobs.ArgNumInstructions, jobs.ArgModel},
+	})
+}
+
+// RegisterJobType adds a job type to the registry. It panics if the job type
+// or its command is already registered.
+func RegisterJobType(spec JobTypeSpec) {
+	for _, registered := range jobTypeSpecs {
+		if registered.JobType == spec.JobType || registered.Command == spec.Command {
+			panic(fmt.Sprintf("job type %q with command %q is already registered", spec.JobType, spec.Command))
+		}
+	}
+	jobTypeSpecs = append(jobTypeSpecs, spec)
+}
+
+// CancelCommand is the command cancelling the jobs of a pull request.
+const CancelCommand = "cancel"
+
+// AuthorizedCommands returns the commands an authorization policy decides
+// on: the job commands and CancelCommand.
+func AuthorizedCommands() []string {
+	commands := make([]string, 0, len(jobTypeSpecs)+1)
+	for _, spec := range jobTypeSpecs {
+		commands = append(commands, spec.Command)
+	}
+	return append(commands, CancelCommand)
+}
+
+// JobTypes returns the registered job types.
+func JobTypes() []JobTypeSpec {
+	return jobTypeSpecs
+}
+
+// JobTypeByCommand returns the job type requested by a command word.
+func JobTypeByCommand(command string) (JobTypeSpec, bool) {
+	for _, spec := range jobTypeSpecs {
+		if spec.Command == command {
+			return spec, true
+		}
+	}
+	return JobTypeSpec{}, false
+}
+
+// JobTypeByType returns the registered job type with the given type.
+func JobTypeByType(jobType string) (JobTypeSpec, bool) {
+	for _, spec := range jobTypeSpecs {
+		if spec.JobType == jobType {
+			return spec, true
+		}
+	}
+	return JobTypeSpec{}, false
+}
+
+// JobTypeByCheckName returns the job type reported by the check run with the
+// given name.
+func JobTypeByCheckName(checkName string) (JobTypeSpec, bool) {
+	for _, spec := range jobTypeSpecs {
+		if spec.CheckName == checkName {
+			return spec, true
+		}
+	}
+	return JobTypeSpec{}, false
+}
+
+// JobCheckName returns the name of the check run reporting a job type, or an
+// empty string for an unknown job type.
+func JobCheckName(jobType string) string {
+	spec, _ := JobTypeByType(jobType)
+	return spec.CheckName
+}
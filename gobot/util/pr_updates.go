@@ -2,6 +2,7 @@ package util
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	"strings"
@@ -9,6 +10,7 @@ import (
 
 	"github.com/google/go-github/v61/github"
 	"github.com/instructlab/instructlab-bot/gobot/common"
+	"github.com/instructlab/instructlab-bot/pkg/jobs"
 )
 
 type PullRequestStatusParams struct {
@@ -20,9 +22,10 @@ type PullRequestStatusParams struct {
 	Comment      string
 	StatusDesc   string
 
-	JobType string
-	JobID   string
-	JobErr  string
+	JobType    string
+	JobID      string
+	JobErr     string
+	CheckRunID int64
 
 	RepoOwner string
 	RepoName  string
@@ -63,39 +66,189 @@ func PostPullRequestComment(ctx context.Context, client *github.Client, params P
 	return nil
 }
 
-func PostPullRequestCheck(ctx context.Context, client *github.Client, params PullRequestStatusParams) error {
+// Check run actions offered on the check runs of the jobs. Their identifiers
+// come back in the requested_action events of the check runs.
+const (
+	CheckActionCancel = "cancel"
+	CheckActionRerun  = "rerun"
+)
 
-	checkRequest := github.CreateCheckRunOptions{
-		Name:      params.CheckName,
-		HeadSHA:   params.PrSha,
-		Status:    github.String(params.Status),
-		StartedAt: &github.Timestamp{Time: time.Now()}, // Optional
-		Output: &github.CheckRunOutput{
-			Title:   github.String(params.CheckName),
-			Summary: github.String(params.CheckSummary),
-			Text:    github.String(params.CheckDetails),
-		},
+// PostPullRequestCheck creates a check run, or updates the check run
+// params.CheckRunID when it is set, and returns its ID. The check run of a
+// job, with params.JobID set, refers to the job and offers to cancel it while
+// it is queued or running, and to run it again once it completed.
+func PostPullRequestCheck(ctx context.Context, client *github.Client, params PullRequestStatusParams) (int64, error) {
+	output := &github.CheckRunOutput{
+		Title:   github.String(params.CheckName),
+		Summary: github.String(params.CheckSummary),
+		Text:    github.String(params.CheckDetails),
 	}
-
+	var conclusion *string
+	var completedAt *github.Timestamp
 	if params.Conclusion != "" {
-		checkRequest.Conclusion = github.String(params.Conclusion)
-		checkRequest.CompletedAt = &github.Timestamp{Time: time.Now().Add(time.Duration(40))}
+		conclusion = github.String(params.Conclusion)
+		completedAt = &github.Timestamp{Time: time.Now()}
+	}
+	var actions []*github.CheckRunAction
+	if params.JobID != "" {
+		actions = jobCheckActions(params.Status)
+	}
 
+	if params.CheckRunID != 0 {
+		_, _, err := client.Checks.UpdateCheckRun(ctx, params.RepoOwner, params.RepoName, params.CheckRunID, github.UpdateCheckRunOptions{
+			Name:        params.CheckName,
+			Status:      github.String(params.Status),
+			Conclusion:  conclusion,
+			CompletedAt: completedAt,
+			Output:      output,
+			Actions:     actions,
+		})
+		if err != nil {
+			return 0, err
+		}
+		return params.CheckRunID, nil
+	}
+
+	checkRequest := github.CreateCheckRunOptions{
+		Name:        params.CheckName,
+		HeadSHA:     params.PrSha,
+		Status:      github.String(params.Status),
+		Conclusion:  conclusion,
+		StartedAt:   &github.Timestamp{Time: time.Now()}, // Optional
+		CompletedAt: completedAt,
+		Output:      output,
+		Actions:     actions,
+	}
+	if params.JobID != "" {
+		checkRequest.ExternalID = github.String(params.JobID)
 	}
 
-	_, _, err := client.Checks.CreateCheckRun(ctx, params.RepoOwner, params.RepoName, checkRequest)
+	checkRun, _, err := client.Checks.CreateCheckRun(ctx, params.RepoOwner, params.RepoName, checkRequest)
 	if err != nil {
-		return err
+		return 0, err
 	}
-	return nil
+	return checkRun.GetID(), nil
+}
+
+// jobCheckActions returns the actions offered on the check run of a job with
+// the given check status.
+func jobCheckActions(status string) []*github.CheckRunAction {
+	if status == common.CheckComplete {
+		return []*github.CheckRunAction{{
+			Label:       "Re-run",
+			Description: "Run this job again",
+			Identifier:  CheckActionRerun,
+		}}
+	}
+	return []*github.CheckRunAction{{
+		Label:       "Cancel",
+		Description: "Cancel this job",
+		Identifier:  CheckActionCancel,
+	}}
+}
+
+// PostJobResults posts the check and the comment presenting the results of
+// a successful job. The note, if any, is added to the comment.
+func PostJobResults(ctx context.Context, client *github.Client, job *jobs.Job, note string) error {
+	modelName := job.ModelName
+	if modelName == "" || modelName == "unknown" {
+		modelName = ""
+	} else {
+		modelName = "using the model " + modelName
+	}
+
+	// Add the model name only if it's not empty
+	detailsMsg := fmt.Sprintf("Beep, boop 🤖, Here are the %s results for your PR", job.JobType)
+	if modelName != "" {
+		detailsMsg += " " + modelName
+	}
+	detailsMsg += fmt.Sprintf("!\n\nResults can be found [here](%s).", job.S3URL)
+	if note != "" {
+		detailsMsg += "\n\n" + note
+	}
+
+	summaryMsg := fmt.Sprintf("Job ID: %s completed successfully. Check Details.", job.ID)
+
+	params := PullRequestStatusParams{
+		Status:       common.CheckComplete,
+		Conclusion:   common.CheckStatusSuccess,
+		JobID:        job.ID,
+		JobType:      job.JobType,
+		CheckName:    JobCheckName(job.JobType),
+		CheckSummary: summaryMsg,
+		CheckDetails: detailsMsg,
+		Comment:      detailsMsg,
+		RepoOwner:    job.RepoOwner,
+		RepoName:     job.RepoName,
+		PrNum:        job.PRNumber,
+		PrSha:        job.PRSHA,
+		CheckRunID:   job.CheckRunID,
+	}
+
+	_, checkErr := PostPullRequestCheck(ctx, client, params)
+	if checkErr != nil {
+		checkErr = fmt.Errorf("failed to post check: %w", checkErr)
+	}
+	commentErr := PostPullRequestComment(ctx, client, params)
+	if commentErr != nil {
+		commentErr = fmt.Errorf("failed to post comment: %w", commentErr)
+	}
+	return errors.Join(checkErr, commentErr)
+}
+
+// PostJobNoChangesCheck concludes the check run of a job as neutral, as the
+// pull request changed no taxonomy file for it to work on.
+func PostJobNoChangesCheck(ctx context.Context, client *github.Client, job *jobs.Job) error {
+	params := PullRequestStatusParams{
+		Status:       common.CheckComplete,
+		Conclusion:   common.CheckStatusNeutral,
+		CheckName:    JobCheckName(job.JobType),
+		CheckSummary: fmt.Sprintf("Job ID: %s found no taxonomy changes.", job.ID),
+		CheckDetails: fmt.Sprintf("Beep, boop 🤖, The *%s* job %s had nothing to do: the pull request changes no taxonomy file.", job.JobType, job.ID),
+		JobType:      job.JobType,
+		JobID:        job.ID,
+		RepoOwner:    job.RepoOwner,
+		RepoName:     job.RepoName,
+		PrNum:        job.PRNumber,
+		PrSha:        job.PRSHA,
+		CheckRunID:   job.CheckRunID,
+	}
+	_, err := PostPullRequestCheck(ctx, client, params)
+	return err
+}
+
+// PostJobCancelledCheck concludes the check run of a cancelled or superseded
+// job as cancelled.
+func PostJobCancelledCheck(ctx context.Context, client *github.Client, job *jobs.Job) error {
+	state := "cancelled"
+	if job.Status == jobs.StatusSuperseded {
+		state = "superseded"
+	}
+	params := PullRequestStatusParams{
+		Status:       common.CheckComplete,
+		Conclusion:   common.CheckStatusCancelled,
+		CheckName:    JobCheckName(job.JobType),
+		CheckSummary: fmt.Sprintf("Job ID: %s was %s.", job.ID, state),
+		CheckDetails: fmt.Sprintf("Beep, boop 🤖, The *%s* job %s was %s: %s.", job.JobType, job.ID, state, job.CancelReason),
+		JobType:      job.JobType,
+		JobID:        job.ID,
+		RepoOwner:    job.RepoOwner,
+		RepoName:     job.RepoName,
+		PrNum:        job.PRNumber,
+		PrSha:        job.PRSHA,
+		CheckRunID:   job.CheckRunID,
+	}
+	_, err := PostPullRequestCheck(ctx, client, params)
+	return err
 }
 
 func PostPullRequestStatus(ctx context.Context, client *github.Client, params PullRequestStatusParams) error {
 	status := &github.RepoStatus{
-		State:       github.String(params.Conclusion),        // Status state: success, failure, error, or pending
-		Description: github.String(params.StatusDesc),        // Status description
-		Context:     github.String(params.CheckName),         // Status context
-		TargetURL:   github.String(common.InstructLabBotUrl), // Target URL to redirect
+		State:       github.String(params.Conclusion), // Status state: success, failure, error, or pending
+		Description: github.String(params.StatusDesc), // Status description
+		Context:     github.String(params.CheckName),  // Status context
+		// Target URL to redirect, the checks of the PR on the GitHub instance
+		TargetURL: github.String(PullRequestURL(params.RepoOwner, params.RepoName, params.PrNum) + "/checks"),
 	}
 	_, _, err := client.Repositories.CreateStatus(ctx, params.RepoOwner, params.RepoName, params.PrSha, status)
 	if err != nil {
@@ -104,7 +257,7 @@ func PostPullRequestStatus(ctx context.Context, client *github.Client, params Pu
 	return nil
 }
 
-func PostBotWelcomeMessage(ctx context.Context, client *github.Client, repoOwner string, repoName string, prNum int, prSha string, botName string, maintainers []string) error {
+func PostBotWelcomeMessage(ctx context.Context, client *github.Client, repoOwner string, repoName string, prNum int, prSha string, botName string, repo *RepoConfig) error {
 	params := PullRequestStatusParams{
 		CheckName: common.BotReadyStatus,
 		RepoOwner: repoOwner,
@@ -114,16 +267,20 @@ func PostBotWelcomeMessage(ctx context.Context, client *github.Client, repoOwner
 	}
 	detailsMsg := fmt.Sprintf("Beep, boop 🤖, Hi, I'm %s and I'm going to help you"+
 		" with your pull request. Thanks for you contribution! 🎉\n\n", botName)
-	detailsMsg += fmt.Sprintf("I support the following commands:\n\n"+
-		"* `%s precheck` -- Check existing model behavior using the questions in this proposed change.\n"+
-		"* `%s generate` -- Generate a sample of synthetic data using the synthetic data generation backend infrastructure.\n"+
-		"* `%s generate-local` -- Generate a sample of synthetic data using a local model.\n"+
+	detailsMsg += "I support the following commands:\n\n"
+	for _, spec := range repo.JobTypes() {
+		detailsMsg += fmt.Sprintf("* `%s %s %s` -- %s\n", botName, spec.Command, spec.Usage(), spec.Help)
+	}
+	detailsMsg += fmt.Sprintf("* `%s cancel [job-id]` -- Cancel the queued and running jobs of this pull request, or only the given job.\n"+
 		"* `%s help` -- Print this help message again.\n"+
+		"> [!NOTE] \n > **Jobs wait in a queue served round-robin across pull requests and authors. "+
+		"Maintainers can move a job ahead with `--priority high`.**\n\n"+
+		"> [!NOTE] \n > **Job options are bounded by the bot configuration, and `--files` restricts a job to some of the changed files.**\n\n"+
 		"> [!NOTE] \n > **Results or Errors of these commands will be posted as a pull request check in the Checks section below**\n\n",
-		botName, botName, botName, botName)
+		botName, botName)
 
-	if len(maintainers) > 0 {
-		detailsMsg += fmt.Sprintf("> [!NOTE] \n > **Currently only maintainers belongs to [%v] teams are allowed to run these commands**.\n", maintainers)
+	if len(repo.Maintainers) > 0 {
+		detailsMsg += fmt.Sprintf("> [!NOTE] \n > **Currently only maintainers belongs to [%v] teams are allowed to run these commands**.\n", repo.Maintainers)
 	}
 	params.Status = common.CheckComplete
 	params.Conclusion = common.CheckStatusSuccess
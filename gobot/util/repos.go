@@ -0,0 +1,180 @@
+package util
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/instructlab/instructlab-bot/pkg/authz"
+)
+
+// DefaultBaseBranch is the branch pull requests are compared to when a
+// repository does not set its own.
+const DefaultBaseBranch = "main"
+
+// RepoConfig is a repository the bot serves, along with its own settings.
+type RepoConfig struct {
+	// Owner is the user or organization owning the repository. An empty
+	// owner matches every owner.
+	Owner string `yaml:"owner,omitempty" json:"owner,omitempty"`
+	Name  string `yaml:"name" json:"name"`
+	// GitRemote is the URL the workers clone the repository from. If empty,
+	// the workers use their --git-remote.
+	GitRemote string `yaml:"git_remote,omitempty" json:"git_remote,omitempty"`
+	// BaseBranch is the branch pull requests are compared to.
+	BaseBranch     string   `yaml:"base_branch,omitempty" json:"base_branch,omitempty"`
+	RequiredLabels []string `yaml:"required_labels,omitempty" json:"required_labels,omitempty"`
+	// Maintainers lists the teams allowed to run every command when the
+	// repository has no policy of its own.
+	Maintainers []string `yaml:"maintainers,omitempty" json:"maintainers,omitempty"`
+	// Commands lists the job commands enabled on the repository. Every job
+	// command is enabled when it is empty.
+	Commands []string `yaml:"commands,omitempty" json:"commands,omitempty"`
+	// Policy decides who may run each command on the repository.
+	Policy *authz.Policy `yaml:"policy,omitempty" json:"policy,omitempty"`
+}
+
+// Repos is the allowlist of the repositories the bot serves.
+type Repos []*RepoConfig
+
+// Lookup returns the configuration of a repository, or false if the bot does
+// not serve it.
+func (r Repos) Lookup(owner, name string) (*RepoConfig, bool) {
+	for _, repo := range r {
+		if strings.EqualFold(repo.Name, name) && (repo.Owner == "" || strings.EqualFold(repo.Owner, owner)) {
+			return repo, true
+		}
+	}
+	return nil, false
+}
+
+// Prepare checks the repositories and fills in their defaults. Repositories
+// without a policy get one allowing their maintainers, or the given policy
+// when they have none.
+func (r Repos) Prepare(policy *authz.Policy) error {
+	if len(r) == 0 {
+		return fmt.Errorf("no repository configured")
+	}
+	for _, repo := range r {
+		if repo.Name == "" {
+			return fmt.Errorf("repository without a name")
+		}
+		for _, command := range repo.Commands {
+			if _, ok := JobTypeByCommand(command); !ok {
+				return fmt.Errorf("repository %s: unknown command %q", repo, command)
+			}
+		}
+		if repo.BaseBranch == "" {
+			repo.BaseBranch = DefaultBaseBranch
+		}
+		switch {
+		case repo.Policy != nil:
+			if err := repo.Policy.Validate(AuthorizedCommands()); err != nil {
+				return fmt.Errorf("repository %s: %w", repo, err)
+			}
+		case len(repo.Maintainers) > 0:
+			repo.Policy = authz.TeamsPolicy(repo.Maintainers)
+		default:
+			repo.Policy = policy
+		}
+	}
+	return nil
+}
+
+// String returns the owner and the name of the repository.
+func (c *RepoConfig) String() string {
+	owner := c.Owner
+	if owner == "" {
+		owner = "*"
+	}
+	return owner + "/" + c.Name
+}
+
+// JobTypes returns the job types enabled on the repository.
+func (c *RepoConfig) JobTypes() []JobTypeSpec {
+	var specs []JobTypeSpec
+	for _, spec := range JobTypes() {
+		if c.CommandEnabled(spec.Command) {
+			specs = append(specs, spec)
+		}
+	}
+	return specs
+}
+
+// CommandEnabled reports whether a job command is enabled on the repository.
+func (c *RepoConfig) CommandEnabled(command string) bool {
+	return len(c.Commands) == 0 || slices.Contains(c.Commands, command)
+}
+
+// RepoSnapshot is a version of the configuration of the repositories.
+type RepoSnapshot struct {
+	// Version counts the configurations loaded since the bot started.
+	Version int `json:"version"`
+	// Digest identifies the content of the configuration.
+	Digest   string    `json:"digest"`
+	LoadedAt time.Time `json:"loaded_at"`
+	Repos    Repos     `json:"repositories"`
+}
+
+// RepoStore holds the active configuration of the repositories. Reloading
+// replaces it as a whole, so an event is handled with a single version of it.
+type RepoStore struct {
+	mu     sync.Mutex
+	active atomic.Pointer[RepoSnapshot]
+}
+
+// NewRepoStore returns a store holding the given repositories.
+func NewRepoStore(repos Repos) *RepoStore {
+	s := &RepoStore{}
+	s.Replace(repos)
+	return s
+}
+
+// Active returns the active configuration.
+func (s *RepoStore) Active() *RepoSnapshot {
+	return s.active.Load()
+}
+
+// Lookup returns the active configuration of a repository, or false if the
+// bot does not serve it.
+func (s *RepoStore) Lookup(owner, name string) (*RepoConfig, bool) {
+	return s.Active().Repos.Lookup(owner, name)
+}
+
+// Replace makes the repositories the active configuration, unless they are
+// the same as the active ones. It returns the active configuration and
+// whether it changed.
+func (s *RepoStore) Replace(repos Repos) (*RepoSnapshot, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	digest := repos.digest()
+	active := s.active.Load()
+	if active != nil && active.Digest == digest {
+		return active, false
+	}
+	snapshot := &RepoSnapshot{Version: 1, Digest: digest, LoadedAt: time.Now().UTC(), Repos: repos}
+	if active != nil {
+		snapshot.Version = active.Version + 1
+	}
+	s.active.Store(snapshot)
+	return snapshot, true
+}
+
+// digest returns a short hash of the repositories and their settings.
+func (r Repos) digest() string {
+	data, err := json.Marshal(r)
+	if err != nil {
+		// The configuration always encodes, but a failure must not look like
+		// an unchanged configuration
+		return fmt.Sprintf("error-%d", time.Now().UnixNano())
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:6])
+}
@@ -0,0 +1,28 @@
+package util
+
+import "testing"
+
+func TestRepoStoreReplace(t *testing.T) {
+	store := NewRepoStore(Repos{{Name: "taxonomy", RequiredLabels: []string{"skill"}}})
+	first := store.Active()
+	if first.Version != 1 || first.Digest == "" {
+		t.Fatalf("Initial snapshot = %+v, want version 1 with a digest", first)
+	}
+
+	if snapshot, changed := store.Replace(Repos{{Name: "taxonomy", RequiredLabels: []string{"skill"}}}); changed || snapshot != first {
+		t.Errorf("Replace with the same repositories changed the snapshot to %+v", snapshot)
+	}
+
+	second, changed := store.Replace(Repos{{Name: "taxonomy", RequiredLabels: []string{"knowledge"}}})
+	if !changed || second.Version != 2 || second.Digest == first.Digest {
+		t.Fatalf("Replace with new labels = %+v, %v, want version 2 with a new digest", second, changed)
+	}
+	repo, ok := store.Lookup("instructlab", "taxonomy")
+	if !ok || repo.RequiredLabels[0] != "knowledge" {
+		t.Errorf("Lookup = %+v, %v, want the new labels", repo, ok)
+	}
+	// Events already holding the first snapshot keep its settings
+	if first.Repos[0].RequiredLabels[0] != "skill" {
+		t.Errorf("First snapshot changed to %v", first.Repos[0].RequiredLabels)
+	}
+}
@@ -0,0 +1,174 @@
+// Package authz decides who may run the commands of the bot on a pull
+// request. A Policy is a list of rules, each allowing some commands to the
+// users matching any of its conditions.
+package authz
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Repository permission levels, from the lowest to the highest.
+const (
+	PermissionNone     = "none"
+	PermissionRead     = "read"
+	PermissionTriage   = "triage"
+	PermissionWrite    = "write"
+	PermissionMaintain = "maintain"
+	PermissionAdmin    = "admin"
+)
+
+// permissions lists the repository permission levels in increasing order.
+var permissions = []string{PermissionNone, PermissionRead, PermissionTriage, PermissionWrite, PermissionMaintain, PermissionAdmin}
+
+// Directory answers the questions the rules ask about a user. It is backed by
+// the GitHub API in the bot.
+type Directory interface {
+	// TeamMember reports whether the user is an active member of the team.
+	TeamMember(ctx context.Context, team, user string) (bool, error)
+	// Permission returns the permission level of the user on the repository
+	// of the pull request.
+	Permission(ctx context.Context, user string) (string, error)
+}
+
+// Request is a command a user asks to run on a pull request.
+type Request struct {
+	User    string
+	Command string
+	// PRAuthor is the author of the pull request.
+	PRAuthor string
+}
+
+// Rule allows its commands to the users matching any of its conditions.
+type Rule struct {
+	// Commands lists the commands the rule applies to. A rule without
+	// commands applies to every command.
+	Commands []string `yaml:"commands,omitempty" json:"commands,omitempty"`
+	// Teams allows the members of these teams of the organization.
+	Teams []string `yaml:"teams,omitempty" json:"teams,omitempty"`
+	// Permission allows the users with at least this permission level on the
+	// repository.
+	Permission string `yaml:"permission,omitempty" json:"permission,omitempty"`
+	// Users allows these users.
+	Users []string `yaml:"users,omitempty" json:"users,omitempty"`
+	// PRAuthor allows the author of the pull request.
+	PRAuthor bool `yaml:"pr_author,omitempty" json:"pr_author,omitempty"`
+}
+
+// Policy decides who may run each command. A command no rule allows is
+// denied.
+type Policy struct {
+	// Deny lists users denied every command, whatever the rules say.
+	Deny  []string `yaml:"deny,omitempty" json:"deny,omitempty"`
+	Rules []Rule   `yaml:"rules" json:"rules"`
+}
+
+// Decision is the outcome of a request, with the reason for it.
+type Decision struct {
+	Allowed bool
+	Reason  string
+}
+
+// Validate checks the permission levels and the commands of the rules. A rule
+// command that is not one of commands would never apply, so it is refused.
+func (p *Policy) Validate(commands []string) error {
+	for i, rule := range p.Rules {
+		if rule.Permission != "" && !slices.Contains(permissions, rule.Permission) {
+			return fmt.Errorf("rule %d: unknown permission %q, expected one of %s", i+1, rule.Permission, strings.Join(permissions, ", "))
+		}
+		for _, command := range rule.Commands {
+			if !slices.Contains(commands, command) {
+				return fmt.Errorf("rule %d: unknown command %q, expected one of %s", i+1, command, strings.Join(commands, ", "))
+			}
+		}
+	}
+	return nil
+}
+
+// Authorize decides whether the request is allowed. The rules that apply to
+// the command are tried in order, and the first condition a user matches
+// allows the request. A failed lookup only fails its own condition, and is
+// reported in the reason of a denial.
+func (p *Policy) Authorize(ctx context.Context, dir Directory, req Request) Decision {
+	if containsUser(p.Deny, req.User) {
+		return Decision{Reason: fmt.Sprintf("user %s is on the deny list", req.User)}
+	}
+
+	applied := false
+	var lookupErrs []string
+	for i, rule := range p.Rules {
+		if len(rule.Commands) > 0 && !slices.Contains(rule.Commands, req.Command) {
+			continue
+		}
+		applied = true
+		reason, err := rule.allows(ctx, dir, req)
+		if err != nil {
+			lookupErrs = append(lookupErrs, fmt.Sprintf("rule %d: %v", i+1, err))
+		}
+		if reason != "" {
+			return Decision{Allowed: true, Reason: fmt.Sprintf("rule %d allows %s", i+1, reason)}
+		}
+	}
+
+	if !applied {
+		return Decision{Reason: fmt.Sprintf("no rule applies to the %s command", req.Command)}
+	}
+	reason := fmt.Sprintf("no rule allows user %s to run the %s command", req.User, req.Command)
+	if len(lookupErrs) > 0 {
+		reason += fmt.Sprintf(" (%s)", strings.Join(lookupErrs, "; "))
+	}
+	return Decision{Reason: reason}
+}
+
+// allows returns why the rule allows the request, or an empty string if it
+// does not. The error is that of the last failed lookup.
+func (r *Rule) allows(ctx context.Context, dir Directory, req Request) (string, error) {
+	if containsUser(r.Users, req.User) {
+		return fmt.Sprintf("user %s", req.User), nil
+	}
+	if r.PRAuthor && req.PRAuthor != "" && strings.EqualFold(req.User, req.PRAuthor) {
+		return "the author of the pull request", nil
+	}
+
+	var lookupErr error
+	for _, team := range r.Teams {
+		member, err := dir.TeamMember(ctx, team, req.User)
+		if err != nil {
+			lookupErr = fmt.Errorf("membership of team %s: %w", team, err)
+			continue
+		}
+		if member {
+			return fmt.Sprintf("the members of team %s", team), nil
+		}
+	}
+	if r.Permission != "" {
+		level, err := dir.Permission(ctx, req.User)
+		if err != nil {
+			return "", fmt.Errorf("repository permission: %w", err)
+		}
+		if slices.Index(permissions, level) >= slices.Index(permissions, r.Permission) {
+			return fmt.Sprintf("the users with %s permission (%s has %s)", r.Permission, req.User, level), nil
+		}
+	}
+	return "", lookupErr
+}
+
+// containsUser reports whether the user is in the list. GitHub logins are
+// case insensitive.
+func containsUser(users []string, user string) bool {
+	return slices.ContainsFunc(users, func(u string) bool {
+		return strings.EqualFold(u, user)
+	})
+}
+
+// TeamsPolicy returns a policy allowing every command to the members of the
+// teams. Without teams, it allows every command to the users with write
+// permission on the repository.
+func TeamsPolicy(teams []string) *Policy {
+	if len(teams) == 0 {
+		return &Policy{Rules: []Rule{{Permission: PermissionWrite}}}
+	}
+	return &Policy{Rules: []Rule{{Teams: teams}}}
+}
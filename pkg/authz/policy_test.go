@@ -0,0 +1,117 @@
+package authz
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeDirectory answers lookups from fixed team members and permissions.
+type fakeDirectory struct {
+	teams       map[string][]string
+	permissions map[string]string
+	err         error
+}
+
+func (d *fakeDirectory) TeamMember(ctx context.Context, team, user string) (bool, error) {
+	if d.err != nil {
+		return false, d.err
+	}
+	return containsUser(d.teams[team], user), nil
+}
+
+func (d *fakeDirectory) Permission(ctx context.Context, user string) (string, error) {
+	if d.err != nil {
+		return "", d.err
+	}
+	if level, ok := d.permissions[user]; ok {
+		return level, nil
+	}
+	return PermissionNone, nil
+}
+
+func TestPolicyAuthorize(t *testing.T) {
+	policy := &Policy{
+		Deny: []string{"mallory"},
+		Rules: []Rule{
+			{Commands: []string{"precheck"}, Teams: []string{"taxonomy-triagers"}},
+			{Commands: []string{"generate", "generate-local"}, Teams: []string{"backend-maintainers"}},
+			{Commands: []string{"precheck", "generate"}, Permission: PermissionMaintain},
+			{Commands: []string{"cancel"}, PRAuthor: true, Users: []string{"Carol"}},
+		},
+	}
+	dir := &fakeDirectory{
+		teams: map[string][]string{
+			"taxonomy-triagers":   {"alice", "mallory"},
+			"backend-maintainers": {"bob"},
+		},
+		permissions: map[string]string{
+			"dave":  PermissionAdmin,
+			"erin":  PermissionWrite,
+			"alice": PermissionRead,
+		},
+	}
+
+	tests := []struct {
+		name    string
+		dir     Directory
+		req     Request
+		allowed bool
+	}{
+		{"triager runs precheck", dir, Request{User: "alice", Command: "precheck"}, true},
+		{"triager can not generate", dir, Request{User: "alice", Command: "generate"}, false},
+		{"backend maintainer generates", dir, Request{User: "bob", Command: "generate-local"}, true},
+		{"denied user in allowed team", dir, Request{User: "mallory", Command: "precheck"}, false},
+		{"admin passes maintain permission", dir, Request{User: "dave", Command: "generate"}, true},
+		{"write below maintain permission", dir, Request{User: "erin", Command: "precheck"}, false},
+		{"permission rule does not cover command", dir, Request{User: "dave", Command: "generate-local"}, false},
+		{"PR author cancels", dir, Request{User: "frank", Command: "cancel", PRAuthor: "Frank"}, true},
+		{"other user cancels", dir, Request{User: "frank", Command: "cancel", PRAuthor: "grace"}, false},
+		{"allowed user, case insensitive", dir, Request{User: "carol", Command: "cancel"}, true},
+		{"no rule for command", dir, Request{User: "dave", Command: "help"}, false},
+		{"failed lookups deny", &fakeDirectory{err: errors.New("rate limited")}, Request{User: "alice", Command: "precheck"}, false},
+		{"failed lookups spare user rules", &fakeDirectory{err: errors.New("rate limited")}, Request{User: "carol", Command: "cancel"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			decision := policy.Authorize(context.Background(), tt.dir, tt.req)
+			assert.Equal(t, tt.allowed, decision.Allowed, decision.Reason)
+			assert.NotEmpty(t, decision.Reason)
+		})
+	}
+}
+
+func TestTeamsPolicy(t *testing.T) {
+	dir := &fakeDirectory{
+		teams:       map[string][]string{"maintainers": {"alice"}},
+		permissions: map[string]string{"bob": PermissionWrite, "carol": PermissionTriage},
+	}
+	tests := []struct {
+		name    string
+		teams   []string
+		user    string
+		allowed bool
+	}{
+		{"team member", []string{"maintainers"}, "alice", true},
+		{"not a member", []string{"maintainers"}, "bob", false},
+		{"no teams, writer", nil, "bob", true},
+		{"no teams, triager", nil, "carol", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			decision := TeamsPolicy(tt.teams).Authorize(context.Background(), dir, Request{User: tt.user, Command: "precheck"})
+			assert.Equal(t, tt.allowed, decision.Allowed, decision.Reason)
+		})
+	}
+}
+
+func TestPolicyValidate(t *testing.T) {
+	commands := []string{"generate", "precheck", "cancel"}
+	assert.NoError(t, (&Policy{Rules: []Rule{{Permission: PermissionTriage}}}).Validate(commands))
+	assert.Error(t, (&Policy{Rules: []Rule{{Permission: "owner"}}}).Validate(commands))
+	assert.NoError(t, (&Policy{Rules: []Rule{{Commands: []string{"precheck", "cancel"}, Permission: PermissionWrite}}}).Validate(commands))
+	err := (&Policy{Rules: []Rule{{Commands: []string{"precheck"}}, {Commands: []string{"generat"}, Teams: []string{"maintainers"}}}}).Validate(commands)
+	assert.ErrorContains(t, err, `rule 2: unknown command "generat"`)
+}
@@ -0,0 +1,387 @@
+// Package config loads the settings of the bot and the worker. Every setting
+// is a command line flag, which can also be given in an environment variable
+// or in a YAML configuration file keyed by the flag names. A flag given on
+// the command line wins over the environment, which wins over the file.
+package config
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/instructlab/instructlab-bot/pkg/secrets"
+	"github.com/spf13/pflag"
+	"gopkg.in/yaml.v3"
+)
+
+// FlagName is the flag giving the configuration file. It can not be set from
+// the file itself.
+const FlagName = "config"
+
+// Sources of a setting, from the lowest precedence to the highest.
+const (
+	SourceDefault = "default"
+	SourceFile    = "file"
+	SourceEnv     = "env"
+	SourceFlag    = "flag"
+	// SourceSecretFile is the source of a secret read from the file given
+	// with its file flag.
+	SourceSecretFile = "secret-file"
+	// SourceSecretProvider is the source of a secret read from the secret
+	// provider.
+	SourceSecretProvider = "secret-provider"
+)
+
+const (
+	// secretAnnotation marks the flags holding secrets.
+	secretAnnotation = "instructlab-bot/secret"
+	// secretFileAnnotation marks the file flags, naming their secret flag.
+	secretFileAnnotation = "instructlab-bot/secret-file"
+)
+
+// SecretFileSuffix ends the name of the flag giving the file of a secret.
+const SecretFileSuffix = "-file"
+
+// Redacted replaces the secrets in a dumped configuration.
+const Redacted = secrets.Redacted
+
+// MarkSecret marks flags as holding secrets, which are redacted from dumps.
+func MarkSecret(flags *pflag.FlagSet, names ...string) {
+	for _, name := range names {
+		if err := flags.SetAnnotation(name, secretAnnotation, []string{"true"}); err != nil {
+			panic(err)
+		}
+	}
+}
+
+// IsSecret reports whether a flag holds a secret.
+func IsSecret(f *pflag.Flag) bool {
+	_, ok := f.Annotations[secretAnnotation]
+	return ok
+}
+
+// AddSecretFileFlags adds a flag giving the file to read each secret from,
+// named after the flag of the secret with SecretFileSuffix. The secrets must
+// be marked first.
+func AddSecretFileFlags(flags *pflag.FlagSet) {
+	var names []string
+	flags.VisitAll(func(f *pflag.Flag) {
+		if IsSecret(f) {
+			names = append(names, f.Name)
+		}
+	})
+	for _, name := range names {
+		flags.String(name+SecretFileSuffix, "", fmt.Sprintf("File to read --%s from, such as a mounted secret", name))
+		if err := flags.SetAnnotation(name+SecretFileSuffix, secretFileAnnotation, []string{name}); err != nil {
+			panic(err)
+		}
+	}
+}
+
+// Loader applies the environment and a configuration file to flags.
+type Loader struct {
+	// EnvPrefix prefixes the environment variable of every flag, whose name
+	// is upper cased with dashes turned into underscores.
+	EnvPrefix string
+	// EnvAliases maps flag names to older environment variables still read
+	// when the variable of the flag is not set.
+	EnvAliases map[string]string
+}
+
+// Settings are the flags of a command along with where each value came from.
+type Settings struct {
+	Flags   *pflag.FlagSet
+	Sources map[string]string
+}
+
+// EnvName returns the environment variable of a flag.
+func (l Loader) EnvName(flag string) string {
+	return strings.ToUpper(l.EnvPrefix + "_" + strings.ReplaceAll(flag, "-", "_"))
+}
+
+// Load applies the environment, then the configuration file if path is not
+// empty, to the flags not given on the command line. Unknown keys and values
+// that do not fit their flag are errors.
+func (l Loader) Load(flags *pflag.FlagSet, path string) (*Settings, error) {
+	var file map[string]any
+	if path != "" {
+		var err error
+		if file, err = ReadFile(path); err != nil {
+			return nil, err
+		}
+	}
+
+	s := &Settings{Flags: flags, Sources: map[string]string{}}
+	var errs []error
+	flags.VisitAll(func(f *pflag.Flag) {
+		s.Sources[f.Name] = SourceDefault
+		if f.Changed {
+			s.Sources[f.Name] = SourceFlag
+			return
+		}
+		if value, name, ok := l.lookupEnv(f.Name); ok {
+			if err := flags.Set(f.Name, value); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", name, err))
+				return
+			}
+			s.Sources[f.Name] = SourceEnv
+		}
+	})
+
+	keys := make([]string, 0, len(file))
+	for key := range file {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		f := flags.Lookup(key)
+		if f == nil || key == FlagName || key == "help" {
+			errs = append(errs, fmt.Errorf("%s: unknown setting %q", path, key))
+			continue
+		}
+		if s.Sources[key] != SourceDefault {
+			continue
+		}
+		value, err := flagValue(file[key])
+		if err == nil {
+			err = flags.Set(key, value)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %s: %w", path, key, err))
+			continue
+		}
+		s.Sources[key] = SourceFile
+	}
+	return s, errors.Join(errs...)
+}
+
+// Reload applies the configuration file again to the named flags whose value
+// came from the file or is their default. A flag removed from the file goes
+// back to its default. The flags given on the command line or in the
+// environment keep their value.
+func (l Loader) Reload(s *Settings, path string, names ...string) error {
+	file, err := ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, name := range names {
+		f := s.Flags.Lookup(name)
+		if f == nil {
+			errs = append(errs, fmt.Errorf("unknown setting %q", name))
+			continue
+		}
+		if source := s.Sources[name]; source != SourceFile && source != SourceDefault {
+			continue
+		}
+		v, ok := file[name]
+		if !ok {
+			if err := resetFlag(f); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", name, err))
+				continue
+			}
+			s.Sources[name] = SourceDefault
+			continue
+		}
+		value, err := flagValue(v)
+		if err == nil {
+			err = setFlag(f, value)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %s: %w", path, name, err))
+			continue
+		}
+		s.Sources[name] = SourceFile
+	}
+	return errors.Join(errs...)
+}
+
+// ResolveSecrets reads the secrets whose file flag is set from their file.
+// The other secrets left empty are read from the provider, if not nil. A
+// secret given both directly and with its file flag is an error.
+func (l Loader) ResolveSecrets(ctx context.Context, s *Settings, provider secrets.Provider) error {
+	var errs []error
+	s.Flags.VisitAll(func(f *pflag.Flag) {
+		if !IsSecret(f) {
+			return
+		}
+		if file := s.Flags.Lookup(f.Name + SecretFileSuffix); file != nil && file.Value.String() != "" {
+			if s.Sources[f.Name] != SourceDefault {
+				errs = append(errs, fmt.Errorf("%s is set both directly (%s) and with %s", f.Name, s.Sources[f.Name], file.Name))
+				return
+			}
+			value, err := secrets.ReadFile(file.Value.String())
+			if err == nil {
+				err = f.Value.Set(value)
+			}
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", file.Name, err))
+				return
+			}
+			s.Sources[f.Name] = SourceSecretFile
+			return
+		}
+		if provider == nil || f.Value.String() != "" {
+			return
+		}
+		value, err := provider.Secret(ctx, f.Name)
+		if errors.Is(err, secrets.ErrNotFound) {
+			return
+		}
+		if err == nil {
+			err = f.Value.Set(value)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", f.Name, err))
+			return
+		}
+		s.Sources[f.Name] = SourceSecretProvider
+	})
+	return errors.Join(errs...)
+}
+
+// Secrets returns the values of the secrets that are set, to redact them.
+func (s *Settings) Secrets() []string {
+	var values []string
+	s.Flags.VisitAll(func(f *pflag.Flag) {
+		if IsSecret(f) && f.Value.String() != "" {
+			values = append(values, f.Value.String())
+		}
+	})
+	return values
+}
+
+// setFlag replaces the value of a flag. Slice flags are replaced rather than
+// appended to.
+func setFlag(f *pflag.Flag, value string) error {
+	if slice, ok := f.Value.(pflag.SliceValue); ok {
+		var items []string
+		if value != "" {
+			items = strings.Split(value, ",")
+		}
+		return slice.Replace(items)
+	}
+	return f.Value.Set(value)
+}
+
+// resetFlag puts a flag back to its default value.
+func resetFlag(f *pflag.Flag) error {
+	if _, ok := f.Value.(pflag.SliceValue); ok {
+		return setFlag(f, strings.Trim(f.DefValue, "[]"))
+	}
+	return f.Value.Set(f.DefValue)
+}
+
+// lookupEnv returns the value of the environment variable of a flag, or of
+// its alias, along with the variable name.
+func (l Loader) lookupEnv(flag string) (string, string, bool) {
+	name := l.EnvName(flag)
+	if value, ok := os.LookupEnv(name); ok {
+		return value, name, true
+	}
+	if alias, ok := l.EnvAliases[flag]; ok {
+		if value, ok := os.LookupEnv(alias); ok {
+			return value, alias, true
+		}
+	}
+	return "", "", false
+}
+
+// ReadFile reads a configuration file, a YAML mapping of flag names to values.
+func ReadFile(path string) (map[string]any, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read configuration: %w", err)
+	}
+	var file map[string]any
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse configuration %s: %w", path, err)
+	}
+	return file, nil
+}
+
+// flagValue turns a value of the file into the text a flag parses. Lists are
+// joined with commas for the slice flags.
+func flagValue(v any) (string, error) {
+	switch v := v.(type) {
+	case nil:
+		return "", nil
+	case []any:
+		items := make([]string, len(v))
+		for i, item := range v {
+			s, err := flagValue(item)
+			if err != nil {
+				return "", err
+			}
+			items[i] = s
+		}
+		return strings.Join(items, ","), nil
+	case map[string]any:
+		return "", errors.New("expected a value or a list, not a mapping")
+	default:
+		return fmt.Sprint(v), nil
+	}
+}
+
+// Dump writes the settings as a configuration file, noting where each value
+// came from. The secrets that are set are replaced with Redacted when redact
+// is true. The secrets read from their file or from the secret provider are
+// left empty, as the dump loads them from there again.
+func (s *Settings) Dump(w io.Writer, redact bool) error {
+	doc := &yaml.Node{Kind: yaml.MappingNode}
+	s.Flags.VisitAll(func(f *pflag.Flag) {
+		if f.Name == FlagName || f.Name == "help" {
+			return
+		}
+		key := &yaml.Node{Kind: yaml.ScalarNode, Value: f.Name, HeadComment: f.Usage}
+		value := dumpValue(f)
+		switch source := s.Sources[f.Name]; {
+		case !IsSecret(f) || f.Value.String() == "":
+		case source == SourceSecretFile || source == SourceSecretProvider:
+			value = &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str"}
+		case redact:
+			value = &yaml.Node{Kind: yaml.ScalarNode, Value: Redacted}
+		}
+		value.LineComment = s.Sources[f.Name]
+		doc.Content = append(doc.Content, key, value)
+	})
+
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(doc); err != nil {
+		return err
+	}
+	if err := enc.Close(); err != nil {
+		return err
+	}
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// dumpValue returns the YAML node of the value of a flag, typed like the flag.
+func dumpValue(f *pflag.Flag) *yaml.Node {
+	if slice, ok := f.Value.(pflag.SliceValue); ok {
+		node := &yaml.Node{Kind: yaml.SequenceNode, Style: yaml.FlowStyle}
+		for _, item := range slice.GetSlice() {
+			node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: item})
+		}
+		return node
+	}
+	value := f.Value.String()
+	switch f.Value.Type() {
+	case "bool":
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!bool", Value: value}
+	case "int", "int64", "uint", "uint64":
+		if _, err := strconv.ParseInt(value, 10, 64); err == nil {
+			return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!int", Value: value}
+		}
+	}
+	return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: value}
+}
@@ -0,0 +1,222 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/instructlab/instructlab-bot/pkg/secrets"
+	"github.com/spf13/pflag"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+type testFlags struct {
+	set      *pflag.FlagSet
+	redis    string
+	port     int
+	labels   []string
+	timeout  time.Duration
+	debug    bool
+	token    string
+	endpoint string
+}
+
+func newTestFlags(t *testing.T, args ...string) *testFlags {
+	f := &testFlags{set: pflag.NewFlagSet("test", pflag.ContinueOnError)}
+	f.set.StringVar(&f.redis, "redis", "redis:6379", "The Redis instance")
+	f.set.IntVar(&f.port, "http-port", 8081, "HTTP port")
+	f.set.StringSliceVar(&f.labels, "required-labels", []string{}, "Required labels")
+	f.set.DurationVar(&f.timeout, "visibility-timeout", time.Minute, "Visibility timeout")
+	f.set.BoolVar(&f.debug, "debug", false, "Debug logging")
+	f.set.StringVar(&f.token, "github-token", "", "GitHub token")
+	f.set.StringVar(&f.endpoint, "precheck-endpoint-url", "http://localhost:8000/v1", "Precheck endpoint")
+	f.set.String(FlagName, "", "Configuration file")
+	MarkSecret(f.set, "github-token")
+	require.NoError(t, f.set.Parse(args))
+	return f
+}
+
+func writeConfig(t *testing.T, content string) string {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	path := writeConfig(t, `
+redis: file:6379
+http-port: 9000
+required-labels: [skill, knowledge]
+visibility-timeout: 2m
+debug: true
+`)
+	t.Setenv("ILBOT_HTTP_PORT", "9100")
+	t.Setenv("ILBOT_DEBUG", "false")
+	flags := newTestFlags(t, "--debug=true")
+
+	settings, err := Loader{EnvPrefix: "ILBOT"}.Load(flags.set, path)
+	require.NoError(t, err)
+
+	assert.Equal(t, "file:6379", flags.redis)
+	assert.Equal(t, 9100, flags.port)
+	assert.Equal(t, []string{"skill", "knowledge"}, flags.labels)
+	assert.Equal(t, 2*time.Minute, flags.timeout)
+	assert.True(t, flags.debug)
+	assert.Equal(t, "http://localhost:8000/v1", flags.endpoint)
+
+	assert.Equal(t, SourceFile, settings.Sources["redis"])
+	assert.Equal(t, SourceEnv, settings.Sources["http-port"])
+	assert.Equal(t, SourceFlag, settings.Sources["debug"])
+	assert.Equal(t, SourceDefault, settings.Sources["precheck-endpoint-url"])
+}
+
+func TestLoadEnvAlias(t *testing.T) {
+	t.Setenv("PECHECK_ENDPOINT", "http://alias:8000/v1")
+	flags := newTestFlags(t)
+	loader := Loader{EnvPrefix: "ILWORKER", EnvAliases: map[string]string{"precheck-endpoint-url": "PECHECK_ENDPOINT"}}
+
+	_, err := loader.Load(flags.set, "")
+	require.NoError(t, err)
+	assert.Equal(t, "http://alias:8000/v1", flags.endpoint)
+
+	// The variable of the flag wins over its alias
+	t.Setenv("ILWORKER_PRECHECK_ENDPOINT_URL", "http://flag:8000/v1")
+	flags = newTestFlags(t)
+	_, err = loader.Load(flags.set, "")
+	require.NoError(t, err)
+	assert.Equal(t, "http://flag:8000/v1", flags.endpoint)
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{"unknown setting", "redis-host: redis:6379\n", `unknown setting "redis-host"`},
+		{"config in the file", "config: other.yaml\n", `unknown setting "config"`},
+		{"invalid integer", "http-port: eighty\n", "http-port"},
+		{"invalid duration", "visibility-timeout: 5\n", "visibility-timeout"},
+		{"mapping", "redis:\n  host: redis\n", "not a mapping"},
+		{"not a mapping", "- redis\n", "failed to parse"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Loader{EnvPrefix: "ILBOT"}.Load(newTestFlags(t).set, writeConfig(t, tt.content))
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.want)
+		})
+	}
+}
+
+func TestDump(t *testing.T) {
+	flags := newTestFlags(t, "--github-token", "ghp_secret", "--required-labels", "skill")
+	settings, err := Loader{EnvPrefix: "ILBOT"}.Load(flags.set, "")
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	require.NoError(t, settings.Dump(&buf, true))
+	assert.NotContains(t, buf.String(), "ghp_secret")
+	assert.NotContains(t, buf.String(), "config:")
+
+	var dumped map[string]any
+	require.NoError(t, yaml.Unmarshal(buf.Bytes(), &dumped))
+	assert.Equal(t, Redacted, dumped["github-token"])
+	assert.Equal(t, 8081, dumped["http-port"])
+	assert.Equal(t, false, dumped["debug"])
+	assert.Equal(t, []any{"skill"}, dumped["required-labels"])
+
+	// A dump without secrets loads back to the same settings
+	buf.Reset()
+	require.NoError(t, settings.Dump(&buf, false))
+	reloaded := newTestFlags(t)
+	_, err = Loader{EnvPrefix: "ILBOT"}.Load(reloaded.set, writeConfig(t, buf.String()))
+	require.NoError(t, err)
+	assert.Equal(t, "ghp_secret", reloaded.token)
+	assert.Equal(t, []string{"skill"}, reloaded.labels)
+	assert.Equal(t, time.Minute, reloaded.timeout)
+}
+
+func TestReload(t *testing.T) {
+	path := writeConfig(t, "required-labels: [skill]\nhttp-port: 9000\n")
+	t.Setenv("ILBOT_REDIS", "env:6379")
+	flags := newTestFlags(t, "--debug")
+	loader := Loader{EnvPrefix: "ILBOT"}
+	settings, err := loader.Load(flags.set, path)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"skill"}, flags.labels)
+
+	require.NoError(t, os.WriteFile(path, []byte("required-labels: [knowledge, skill]\nredis: file:6379\ndebug: false\n"), 0o600))
+	require.NoError(t, loader.Reload(settings, path, "required-labels", "http-port", "redis", "debug"))
+	assert.Equal(t, []string{"knowledge", "skill"}, flags.labels)
+	// Removed from the file, back to its default
+	assert.Equal(t, 8081, flags.port)
+	assert.Equal(t, SourceDefault, settings.Sources["http-port"])
+	// The environment and the command line still win
+	assert.Equal(t, "env:6379", flags.redis)
+	assert.True(t, flags.debug)
+
+	require.NoError(t, os.WriteFile(path, []byte("required-labels: []\n"), 0o600))
+	require.NoError(t, loader.Reload(settings, path, "required-labels"))
+	assert.Empty(t, flags.labels)
+
+	require.NoError(t, os.WriteFile(path, []byte("http-port: eighty\n"), 0o600))
+	assert.Error(t, loader.Reload(settings, path, "http-port"))
+}
+
+type fakeProvider map[string]string
+
+func (p fakeProvider) Secret(_ context.Context, name string) (string, error) {
+	value, ok := p[name]
+	if !ok {
+		return "", secrets.ErrNotFound
+	}
+	return value, nil
+}
+
+func TestResolveSecrets(t *testing.T) {
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	require.NoError(t, os.WriteFile(tokenFile, []byte("ghp_from_file\n"), 0o600))
+	flags := newTestFlags(t)
+	AddSecretFileFlags(flags.set)
+	require.NotNil(t, flags.set.Lookup("github-token-file"))
+	assert.Nil(t, flags.set.Lookup("redis-file"))
+
+	loader := Loader{EnvPrefix: "ILBOT"}
+	settings, err := loader.Load(flags.set, writeConfig(t, "github-token-file: "+tokenFile+"\n"))
+	require.NoError(t, err)
+	require.NoError(t, loader.ResolveSecrets(context.Background(), settings, fakeProvider{"github-token": "ghp_from_provider"}))
+	assert.Equal(t, "ghp_from_file", flags.token)
+	assert.Equal(t, SourceSecretFile, settings.Sources["github-token"])
+	assert.Equal(t, []string{"ghp_from_file"}, settings.Secrets())
+
+	// The dump names the file rather than holding the secret
+	var buf bytes.Buffer
+	require.NoError(t, settings.Dump(&buf, false))
+	assert.NotContains(t, buf.String(), "ghp_from_file")
+	assert.Contains(t, buf.String(), tokenFile)
+
+	// Without a file, the empty secrets come from the provider
+	flags = newTestFlags(t)
+	AddSecretFileFlags(flags.set)
+	settings, err = loader.Load(flags.set, "")
+	require.NoError(t, err)
+	require.NoError(t, loader.ResolveSecrets(context.Background(), settings, fakeProvider{"github-token": "ghp_from_provider"}))
+	assert.Equal(t, "ghp_from_provider", flags.token)
+	assert.Equal(t, SourceSecretProvider, settings.Sources["github-token"])
+
+	// A secret given twice is refused
+	flags = newTestFlags(t, "--github-token", "ghp_flag")
+	AddSecretFileFlags(flags.set)
+	require.NoError(t, flags.set.Set("github-token-file", tokenFile))
+	settings, err = loader.Load(flags.set, "")
+	require.NoError(t, err)
+	err = loader.ResolveSecrets(context.Background(), settings, nil)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "github-token-file")
+}
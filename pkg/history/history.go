@@ -0,0 +1,70 @@
+// Package history keeps the records of finished jobs in a database, so they
+// outlive the Redis keys the bot and the workers share while a job runs.
+package history
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/instructlab/instructlab-bot/pkg/jobs"
+)
+
+// Filter selects the jobs returned by Store.List. Zero fields match every job.
+// Since and Until bound the request time of the jobs.
+type Filter struct {
+	RepoOwner string
+	RepoName  string
+	PRNumber  int
+	Author    string
+	JobType   string
+	Status    jobs.Status
+	Since     time.Time
+	Until     time.Time
+	Limit     int
+}
+
+// Store keeps the records of finished jobs.
+type Store interface {
+	// Record inserts a job, or replaces the record of a job with the same ID.
+	Record(ctx context.Context, job *jobs.Job) error
+	// Get returns the record of the job with the given ID, or jobs.ErrNotFound.
+	Get(ctx context.Context, id string) (*jobs.Job, error)
+	// List returns the records matching the filter, most recent first.
+	List(ctx context.Context, filter Filter) ([]*jobs.Job, error)
+	// Close releases the resources of the store.
+	Close() error
+}
+
+// Archiver moves finished jobs from a JobStore to a Store.
+type Archiver struct {
+	store   jobs.JobStore
+	history Store
+	ttl     time.Duration
+}
+
+// NewArchiver returns an Archiver recording jobs in history and expiring them
+// from store after ttl.
+func NewArchiver(store jobs.JobStore, history Store, ttl time.Duration) *Archiver {
+	return &Archiver{store: store, history: history, ttl: ttl}
+}
+
+// Archive records a finished job in the history, then lets it expire from the
+// JobStore. The job is kept in the JobStore if it could not be recorded. A nil
+// Archiver archives nothing.
+func (a *Archiver) Archive(ctx context.Context, id string) error {
+	if a == nil {
+		return nil
+	}
+	job, err := a.store.Get(ctx, id)
+	if err != nil {
+		return err
+	}
+	if job.Active() {
+		return fmt.Errorf("job %s is still %s", id, job.Status)
+	}
+	if err := a.history.Record(ctx, job); err != nil {
+		return err
+	}
+	return a.store.Expire(ctx, id, a.ttl)
+}
@@ -0,0 +1,266 @@
+package history
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/instructlab/instructlab-bot/pkg/jobs"
+
+	// Database drivers supported by Open
+	_ "github.com/lib/pq"
+	_ "modernc.org/sqlite"
+)
+
+// Database drivers supported by Open.
+const (
+	DriverSQLite   = "sqlite"
+	DriverPostgres = "postgres"
+)
+
+var _ Store = (*SQLStore)(nil)
+
+// schema holds the statements creating the jobs table. Times are stored as
+// Unix seconds, zero when unset.
+var schema = []string{
+	`CREATE TABLE IF NOT EXISTS jobs (
+		id BIGINT PRIMARY KEY,
+		repo_owner TEXT NOT NULL,
+		repo_name TEXT NOT NULL,
+		pr_number INTEGER NOT NULL,
+		pr_sha TEXT NOT NULL,
+		author TEXT NOT NULL,
+		installation_id BIGINT NOT NULL,
+		job_type TEXT NOT NULL,
+		args TEXT NOT NULL,
+		priority TEXT NOT NULL,
+		status TEXT NOT NULL,
+		worker TEXT NOT NULL,
+		attempts INTEGER NOT NULL,
+		request_time BIGINT NOT NULL,
+		start_time BIGINT NOT NULL,
+		finish_time BIGINT NOT NULL,
+		duration BIGINT NOT NULL,
+		model_name TEXT NOT NULL,
+		cmd TEXT NOT NULL,
+		s3_url TEXT NOT NULL,
+		errors TEXT NOT NULL,
+		cancel_reason TEXT NOT NULL
+	)`,
+	`CREATE INDEX IF NOT EXISTS jobs_pr ON jobs (repo_owner, repo_name, pr_number)`,
+	`CREATE INDEX IF NOT EXISTS jobs_request_time ON jobs (request_time)`,
+}
+
+// columns lists the columns of the jobs table in the order used by scan and
+// values.
+var columns = []string{
+	"id", "repo_owner", "repo_name", "pr_number", "pr_sha", "author", "installation_id",
+	"job_type", "args", "priority", "status", "worker", "attempts",
+	"request_time", "start_time", "finish_time", "duration",
+	"model_name", "cmd", "s3_url", "errors", "cancel_reason",
+}
+
+// SQLStore is a Store backed by a SQLite or PostgreSQL database.
+type SQLStore struct {
+	db     *sql.DB
+	driver string
+}
+
+// ValidateDriver checks a database driver given in the configuration.
+func ValidateDriver(driver string) error {
+	if driver != DriverSQLite && driver != DriverPostgres {
+		return fmt.Errorf("unknown history database driver %q, expected %s or %s", driver, DriverSQLite, DriverPostgres)
+	}
+	return nil
+}
+
+// Open connects to the database of the given driver and creates the jobs
+// table if needed.
+func Open(ctx context.Context, driver, dsn string) (*SQLStore, error) {
+	if err := ValidateDriver(driver); err != nil {
+		return nil, err
+	}
+	db, err := sql.Open(driver, dsn)
+	if err != nil {
+		return nil, err
+	}
+	if driver == DriverSQLite {
+		// SQLite allows a single writer at a time
+		db.SetMaxOpenConns(1)
+	}
+	for _, statement := range schema {
+		if _, err := db.ExecContext(ctx, statement); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("failed to create the history schema: %w", err)
+		}
+	}
+	return &SQLStore{db: db, driver: driver}, nil
+}
+
+func (s *SQLStore) Record(ctx context.Context, job *jobs.Job) error {
+	values, err := jobValues(job)
+	if err != nil {
+		return err
+	}
+	updates := make([]string, 0, len(columns)-1)
+	for _, column := range columns[1:] {
+		updates = append(updates, fmt.Sprintf("%s = excluded.%s", column, column))
+	}
+	query := fmt.Sprintf("INSERT INTO jobs (%s) VALUES (%s) ON CONFLICT (id) DO UPDATE SET %s",
+		strings.Join(columns, ", "),
+		strings.TrimSuffix(strings.Repeat("?, ", len(columns)), ", "),
+		strings.Join(updates, ", "))
+	_, err = s.db.ExecContext(ctx, s.rebind(query), values...)
+	return err
+}
+
+func (s *SQLStore) Get(ctx context.Context, id string) (*jobs.Job, error) {
+	query := fmt.Sprintf("SELECT %s FROM jobs WHERE id = ?", strings.Join(columns, ", "))
+	job, err := scanJob(s.db.QueryRowContext(ctx, s.rebind(query), id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, jobs.ErrNotFound
+	}
+	return job, err
+}
+
+func (s *SQLStore) List(ctx context.Context, filter Filter) ([]*jobs.Job, error) {
+	var where []string
+	var args []interface{}
+	add := func(condition string, arg interface{}) {
+		where = append(where, condition)
+		args = append(args, arg)
+	}
+	if filter.RepoOwner != "" {
+		add("repo_owner = ?", filter.RepoOwner)
+	}
+	if filter.RepoName != "" {
+		add("repo_name = ?", filter.RepoName)
+	}
+	if filter.PRNumber != 0 {
+		add("pr_number = ?", filter.PRNumber)
+	}
+	if filter.Author != "" {
+		add("author = ?", filter.Author)
+	}
+	if filter.JobType != "" {
+		add("job_type = ?", filter.JobType)
+	}
+	if filter.Status != "" {
+		add("status = ?", string(filter.Status))
+	}
+	if !filter.Since.IsZero() {
+		add("request_time >= ?", filter.Since.Unix())
+	}
+	if !filter.Until.IsZero() {
+		add("request_time < ?", filter.Until.Unix())
+	}
+
+	query := fmt.Sprintf("SELECT %s FROM jobs", strings.Join(columns, ", "))
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	query += " ORDER BY request_time DESC, id DESC"
+	if filter.Limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, filter.Limit)
+	}
+
+	rows, err := s.db.QueryContext(ctx, s.rebind(query), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var list []*jobs.Job
+	for rows.Next() {
+		job, err := scanJob(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, job)
+	}
+	return list, rows.Err()
+}
+
+func (s *SQLStore) Close() error {
+	return s.db.Close()
+}
+
+// rebind replaces the ? placeholders of a query with the $n placeholders
+// PostgreSQL expects.
+func (s *SQLStore) rebind(query string) string {
+	if s.driver != DriverPostgres {
+		return query
+	}
+	var b strings.Builder
+	n := 0
+	for _, r := range query {
+		if r == '?' {
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// jobValues returns the values of a job in the order of columns.
+func jobValues(job *jobs.Job) ([]interface{}, error) {
+	id, err := strconv.ParseInt(job.ID, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid job ID %q: %w", job.ID, err)
+	}
+	return []interface{}{
+		id, job.RepoOwner, job.RepoName, job.PRNumber, job.PRSHA, job.Author, job.InstallationID,
+		job.JobType, job.Args, string(job.Priority), string(job.Status), job.Worker, job.Attempts,
+		unixTime(job.RequestTime), unixTime(job.StartTime), unixTime(job.FinishTime), int64(job.Duration.Seconds()),
+		job.ModelName, job.Cmd, job.S3URL, job.Errors, job.CancelReason,
+	}, nil
+}
+
+// scanJob reads a job from a row holding the columns.
+func scanJob(row interface {
+	Scan(dest ...interface{}) error
+}) (*jobs.Job, error) {
+	var (
+		job                                          jobs.Job
+		id                                           int64
+		priority, status                             string
+		requestTime, startTime, finishTime, duration int64
+	)
+	err := row.Scan(
+		&id, &job.RepoOwner, &job.RepoName, &job.PRNumber, &job.PRSHA, &job.Author, &job.InstallationID,
+		&job.JobType, &job.Args, &priority, &status, &job.Worker, &job.Attempts,
+		&requestTime, &startTime, &finishTime, &duration,
+		&job.ModelName, &job.Cmd, &job.S3URL, &job.Errors, &job.CancelReason,
+	)
+	if err != nil {
+		return nil, err
+	}
+	job.ID = strconv.FormatInt(id, 10)
+	job.Priority = jobs.Priority(priority)
+	job.Status = jobs.Status(status)
+	job.RequestTime = fromUnix(requestTime)
+	job.StartTime = fromUnix(startTime)
+	job.FinishTime = fromUnix(finishTime)
+	job.Duration = time.Duration(duration) * time.Second
+	return &job, nil
+}
+
+func unixTime(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.Unix()
+}
+
+func fromUnix(seconds int64) time.Time {
+	if seconds == 0 {
+		return time.Time{}
+	}
+	return time.Unix(seconds, 0)
+}
@@ -0,0 +1,111 @@
+package history
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	goredis "github.com/go-redis/redis/v8"
+	"github.com/instructlab/instructlab-bot/pkg/jobs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func openSQLite(t *testing.T) *SQLStore {
+	store, err := Open(context.Background(), DriverSQLite, filepath.Join(t.TempDir(), "history.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { store.Close() })
+	return store
+}
+
+// TestSQLStoreFilters records jobs and reads them back through each filter.
+func TestSQLStoreFilters(t *testing.T) {
+	ctx := context.Background()
+	store := openSQLite(t)
+	day := time.Unix(1717000000, 0)
+
+	records := []*jobs.Job{
+		{ID: "1", RepoOwner: "org", RepoName: "taxonomy", PRNumber: 1, Author: "alice", JobType: jobs.TypePrecheck, Status: jobs.StatusSuccess, RequestTime: day},
+		{ID: "2", RepoOwner: "org", RepoName: "taxonomy", PRNumber: 1, Author: "alice", JobType: jobs.TypeSDG, Status: jobs.StatusError, RequestTime: day.Add(time.Hour), Errors: "boom"},
+		{ID: "3", RepoOwner: "org", RepoName: "taxonomy", PRNumber: 2, Author: "bob", JobType: jobs.TypePrecheck, Status: jobs.StatusCancelled, RequestTime: day.Add(48 * time.Hour)},
+	}
+	for _, job := range records {
+		require.NoError(t, store.Record(ctx, job))
+	}
+
+	ids := func(filter Filter) []string {
+		list, err := store.List(ctx, filter)
+		require.NoError(t, err)
+		var got []string
+		for _, job := range list {
+			got = append(got, job.ID)
+		}
+		return got
+	}
+	assert.Equal(t, []string{"3", "2", "1"}, ids(Filter{}))
+	assert.Equal(t, []string{"2", "1"}, ids(Filter{RepoOwner: "org", RepoName: "taxonomy", PRNumber: 1}))
+	assert.Equal(t, []string{"3"}, ids(Filter{Author: "bob"}))
+	assert.Equal(t, []string{"3", "1"}, ids(Filter{JobType: jobs.TypePrecheck}))
+	assert.Equal(t, []string{"2"}, ids(Filter{Status: jobs.StatusError}))
+	assert.Equal(t, []string{"2"}, ids(Filter{Since: day.Add(time.Minute), Until: day.Add(24 * time.Hour)}))
+	assert.Equal(t, []string{"3"}, ids(Filter{Limit: 1}))
+
+	// Recording a job again replaces its record
+	records[1].Status = jobs.StatusSuccess
+	records[1].Errors = ""
+	require.NoError(t, store.Record(ctx, records[1]))
+	job, err := store.Get(ctx, "2")
+	require.NoError(t, err)
+	assert.Equal(t, jobs.StatusSuccess, job.Status)
+	assert.Empty(t, job.Errors)
+	assert.Equal(t, day.Add(time.Hour), job.RequestTime)
+	assert.True(t, job.StartTime.IsZero())
+
+	_, err = store.Get(ctx, "404")
+	assert.ErrorIs(t, err, jobs.ErrNotFound)
+}
+
+func TestRebind(t *testing.T) {
+	query := "SELECT id FROM jobs WHERE author = ? AND status = ? LIMIT ?"
+	assert.Equal(t, query, (&SQLStore{driver: DriverSQLite}).rebind(query))
+	assert.Equal(t, "SELECT id FROM jobs WHERE author = $1 AND status = $2 LIMIT $3",
+		(&SQLStore{driver: DriverPostgres}).rebind(query))
+	assert.Equal(t, "SELECT id FROM jobs", (&SQLStore{driver: DriverPostgres}).rebind("SELECT id FROM jobs"))
+}
+
+// TestArchiver verifies an archived job is recorded, then expires from Redis.
+func TestArchiver(t *testing.T) {
+	ctx := context.Background()
+	mr := miniredis.RunT(t)
+	client := goredis.NewClient(&goredis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { client.Close() })
+	store := jobs.NewRedisStore(client)
+	history := openSQLite(t)
+
+	id, err := store.Create(ctx, &jobs.Job{RepoOwner: "org", RepoName: "taxonomy", PRNumber: 1, JobType: jobs.TypePrecheck})
+	require.NoError(t, err)
+	require.NoError(t, store.UpdateStatus(ctx, id, jobs.StatusRunning))
+	require.NoError(t, store.Complete(ctx, id, jobs.Result{Duration: time.Minute, S3URL: "https://example.com", ModelName: "merlinite"}))
+	job, err := store.Get(ctx, id)
+	require.NoError(t, err)
+
+	archiver := NewArchiver(store, history, time.Hour)
+	require.NoError(t, archiver.Archive(ctx, id))
+	recorded, err := history.Get(ctx, id)
+	require.NoError(t, err)
+	assert.Equal(t, job, recorded)
+
+	mr.FastForward(time.Hour)
+	_, err = store.Get(ctx, id)
+	assert.ErrorIs(t, err, jobs.ErrNotFound)
+
+	// Active jobs are not archived
+	active, err := store.Create(ctx, &jobs.Job{JobType: jobs.TypePrecheck})
+	require.NoError(t, err)
+	assert.Error(t, archiver.Archive(ctx, active))
+
+	var disabled *Archiver
+	assert.NoError(t, disabled.Archive(ctx, active))
+}
@@ -0,0 +1,106 @@
+package jobs
+
+import (
+	"fmt"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Names of the job options, given as --<name> on a job command.
+const (
+	ArgNumInstructions = "num-instructions"
+	ArgMaxSeed         = "max-seed"
+	ArgModel           = "model"
+	ArgFiles           = "files"
+)
+
+// Args are the options of a job that change its results. An option left to
+// its zero value keeps the default of the worker. Args are stored in Job.Args
+// in the form of command options, as returned by String.
+type Args struct {
+	// NumInstructions is the number of instructions to generate.
+	NumInstructions int
+	// MaxSeed caps the number of seed examples of each file sent to the
+	// generation.
+	MaxSeed int
+	// Model is the model the job runs against.
+	Model string
+	// Files restricts the job to these taxonomy files, relative to the root of
+	// the taxonomy.
+	Files []string
+}
+
+// Set parses the value of the named option.
+func (a *Args) Set(name, value string) error {
+	switch name {
+	case ArgNumInstructions, ArgMaxSeed:
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 {
+			return fmt.Errorf("--%s must be a positive number, got %q", name, value)
+		}
+		if name == ArgNumInstructions {
+			a.NumInstructions = n
+		} else {
+			a.MaxSeed = n
+		}
+	case ArgModel:
+		if value == "" || strings.ContainsAny(value, " \t\n") {
+			return fmt.Errorf("invalid --%s %q", name, value)
+		}
+		a.Model = value
+	case ArgFiles:
+		files := strings.FieldsFunc(value, func(r rune) bool { return r == ',' })
+		if len(files) == 0 {
+			return fmt.Errorf("--%s needs at least one file", name)
+		}
+		for _, file := range files {
+			if path.IsAbs(file) || path.Clean(file) != file || strings.HasPrefix(file, "../") || path.Ext(file) != ".yaml" {
+				return fmt.Errorf("invalid --%s %q, expected paths of yaml files in the taxonomy", name, file)
+			}
+			if !slices.Contains(a.Files, file) {
+				a.Files = append(a.Files, file)
+			}
+		}
+		slices.Sort(a.Files)
+	default:
+		return fmt.Errorf("unknown option --%s", name)
+	}
+	return nil
+}
+
+// String returns the options set in args, in a fixed order so identical
+// options give the same string. It is empty when no option is set.
+func (a Args) String() string {
+	var opts []string
+	if a.NumInstructions != 0 {
+		opts = append(opts, "--"+ArgNumInstructions, strconv.Itoa(a.NumInstructions))
+	}
+	if a.MaxSeed != 0 {
+		opts = append(opts, "--"+ArgMaxSeed, strconv.Itoa(a.MaxSeed))
+	}
+	if a.Model != "" {
+		opts = append(opts, "--"+ArgModel, a.Model)
+	}
+	if len(a.Files) > 0 {
+		opts = append(opts, "--"+ArgFiles, strings.Join(a.Files, ","))
+	}
+	return strings.Join(opts, " ")
+}
+
+// ParseArgs parses the options of a job as returned by Args.String.
+func ParseArgs(s string) (Args, error) {
+	var args Args
+	words := strings.Fields(s)
+	for i := 0; i < len(words); i += 2 {
+		name, ok := strings.CutPrefix(words[i], "--")
+		if !ok || i+1 == len(words) {
+			return Args{}, fmt.Errorf("invalid job options %q", s)
+		}
+		if err := args.Set(name, words[i+1]); err != nil {
+			return Args{}, err
+		}
+	}
+	return args, nil
+}
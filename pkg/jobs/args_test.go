@@ -0,0 +1,39 @@
+package jobs
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestArgs(t *testing.T) {
+	var args Args
+	assert.Equal(t, "", args.String())
+
+	require.NoError(t, args.Set(ArgFiles, "knowledge/b/qna.yaml,compositional_skills/a/qna.yaml"))
+	require.NoError(t, args.Set(ArgModel, "granite-7b-lab"))
+	require.NoError(t, args.Set(ArgNumInstructions, "50"))
+	require.NoError(t, args.Set(ArgFiles, "knowledge/b/qna.yaml"))
+	assert.Equal(t, "--num-instructions 50 --model granite-7b-lab --files compositional_skills/a/qna.yaml,knowledge/b/qna.yaml", args.String())
+
+	parsed, err := ParseArgs(args.String())
+	require.NoError(t, err)
+	assert.Equal(t, args, parsed)
+
+	for name, value := range map[string]string{
+		ArgNumInstructions: "0",
+		ArgMaxSeed:         "ten",
+		ArgModel:           "",
+		ArgFiles:           "../secrets.yaml",
+		"temperature":      "1",
+	} {
+		assert.Error(t, args.Set(name, value), "--%s %s", name, value)
+	}
+	for _, value := range []string{"/etc/qna.yaml", "knowledge/./qna.yaml", "knowledge/attribution.txt", ","} {
+		assert.Error(t, args.Set(ArgFiles, value), value)
+	}
+
+	_, err = ParseArgs("--max-seed")
+	assert.Error(t, err)
+}
@@ -0,0 +1,69 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+const (
+	keyDedup = "dedup"
+	// dedupTTL bounds how long a job is reused for identical requests.
+	dedupTTL = 7 * 24 * time.Hour
+)
+
+// DedupKey returns the key identifying the work done by a job: the same job
+// type run with the same arguments on the same commit of a pull request gives
+// the same results. The priority of the job does not change its results and
+// is left out.
+func DedupKey(job *Job) string {
+	return fmt.Sprintf("%s:%s/%s:%d:%s:%s:%s", keyDedup, job.RepoOwner, job.RepoName, job.PRNumber, job.PRSHA, job.JobType, job.Args)
+}
+
+// DedupIndex remembers the last job requested for each DedupKey, so identical
+// requests can reuse it instead of running the same work again.
+type DedupIndex struct {
+	client *redis.Client
+	store  *RedisStore
+}
+
+// NewDedupIndex returns a dedup index stored with the given client.
+func NewDedupIndex(client *redis.Client) *DedupIndex {
+	return &DedupIndex{client: client, store: NewRedisStore(client)}
+}
+
+// Lookup returns the job recorded for the DedupKey of job if it can be
+// reused: a job still queued or running, or one that completed successfully.
+// It returns nil when a new job must be run.
+func (d *DedupIndex) Lookup(ctx context.Context, job *Job) (*Job, error) {
+	id, err := d.client.Get(ctx, DedupKey(job)).Result()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	existing, err := d.store.Get(ctx, id)
+	if err == ErrNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if existing.Cancelled() {
+		return nil, nil
+	}
+	if existing.Active() || existing.Status == StatusSuccess {
+		return existing, nil
+	}
+	return nil, nil
+}
+
+// Record makes job the one reused for identical requests. Two identical
+// requests racing between Lookup and Record may both run.
+func (d *DedupIndex) Record(ctx context.Context, job *Job) error {
+	return d.client.Set(ctx, DedupKey(job), job.ID, dedupTTL).Err()
+}
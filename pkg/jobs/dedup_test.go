@@ -0,0 +1,72 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	goredis "github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestDedupIndexReusesJobs verifies identical requests reuse an active or
+// successful job, and run again once it failed or was cancelled.
+func TestDedupIndexReusesJobs(t *testing.T) {
+	ctx := context.Background()
+	mr := miniredis.RunT(t)
+	client := goredis.NewClient(&goredis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { client.Close() })
+	store := NewRedisStore(client)
+	index := NewDedupIndex(client)
+
+	request := func() *Job {
+		return &Job{RepoOwner: "org", RepoName: "taxonomy", PRNumber: 1, PRSHA: "abc", JobType: TypePrecheck}
+	}
+	record := func() *Job {
+		job := request()
+		_, err := store.Create(ctx, job)
+		require.NoError(t, err)
+		require.NoError(t, index.Record(ctx, job))
+		return job
+	}
+	lookup := func(job *Job) *Job {
+		existing, err := index.Lookup(ctx, job)
+		require.NoError(t, err)
+		return existing
+	}
+
+	assert.Nil(t, lookup(request()))
+
+	// A queued job is reused, whatever the priority of the new request
+	first := record()
+	again := request()
+	again.Priority = PriorityHigh
+	require.NotNil(t, lookup(again))
+	assert.Equal(t, first.ID, lookup(again).ID)
+
+	// Other commits, job types and arguments run their own job
+	other := request()
+	other.PRSHA = "def"
+	assert.Nil(t, lookup(other))
+	other = request()
+	other.JobType = TypeSDG
+	assert.Nil(t, lookup(other))
+	other = request()
+	other.Args = "--num-instructions 5"
+	assert.Nil(t, lookup(other))
+
+	// A successful job keeps being reused
+	require.NoError(t, store.Complete(ctx, first.ID, Result{S3URL: "https://example.com"}))
+	require.NotNil(t, lookup(request()))
+	assert.Equal(t, StatusSuccess, lookup(request()).Status)
+
+	// A failed or cancelled job is run again
+	failed := record()
+	require.NoError(t, store.Fail(ctx, failed.ID, errors.New("boom")))
+	assert.Nil(t, lookup(request()))
+	cancelled := record()
+	require.NoError(t, store.Cancel(ctx, cancelled.ID, "test"))
+	assert.Nil(t, lookup(request()))
+}
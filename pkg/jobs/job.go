@@ -0,0 +1,548 @@
+// Package jobs holds the job model shared by the bot and the worker, and the
+// JobStore implementations used to persist it in Redis.
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Status is the lifecycle state of a job.
+type Status string
+
+const (
+	StatusPending   Status = "pending"
+	StatusRunning   Status = "running"
+	StatusSuccess   Status = "success"
+	StatusError     Status = "error"
+	StatusCancelled Status = "cancelled"
+	// StatusSuperseded ends a queued job replaced by a job on a newer commit
+	// of its pull request.
+	StatusSuperseded Status = "superseded"
+)
+
+// Priority is the scheduling class of a job. Jobs of a higher class are
+// handed to the workers first.
+type Priority string
+
+const (
+	PriorityHigh   Priority = "high"
+	PriorityNormal Priority = "normal"
+	PriorityLow    Priority = "low"
+)
+
+// Priorities lists the priority classes from the highest to the lowest.
+var Priorities = []Priority{PriorityHigh, PriorityNormal, PriorityLow}
+
+// ParsePriority returns the priority class with the given name.
+func ParsePriority(name string) (Priority, error) {
+	for _, p := range Priorities {
+		if string(p) == name {
+			return p, nil
+		}
+	}
+	return "", fmt.Errorf("unknown priority %q, expected one of %s, %s or %s", name, PriorityHigh, PriorityNormal, PriorityLow)
+}
+
+// Job types, as set by the bot and understood by the worker.
+const (
+	TypeGenerateLocal = "generate"
+	TypePrecheck      = "precheck"
+	TypeSDG           = "sdg-svc"
+)
+
+// Redis queues the jobs move through. Jobs wait on the queue of their type,
+// named by GenerateQueue.
+const (
+	QueueGenerate   = "generate"
+	QueueResults    = "results"
+	QueueArchived   = "archived"
+	QueueDeadLetter = "dead_letter"
+)
+
+// ChannelEvents is the pub/sub channel job status transitions and cancellation
+// requests are published on.
+const ChannelEvents = "job_events"
+
+// KeyJobs is both the job ID counter and the prefix of every job attribute key.
+const KeyJobs = "jobs"
+
+// KeyRunning is the set of the IDs of the running jobs.
+const KeyRunning = "jobs:running"
+
+// Job attribute fields, stored as jobs:<id>:<field>.
+const (
+	FieldPRNumber       = "pr_number"
+	FieldPRSHA          = "pr_sha"
+	FieldAuthor         = "author"
+	FieldInstallationID = "installation_id"
+	FieldRepoOwner      = "repo_owner"
+	FieldRepoName       = "repo_name"
+	FieldJobType        = "job_type"
+	FieldErrors         = "errors"
+	FieldRequestTime    = "request_time"
+	FieldDuration       = "duration"
+	FieldStatus         = "status"
+	FieldS3URL          = "s3_url"
+	FieldModelName      = "model_name"
+	FieldCmd            = "cmd"
+	FieldAttempts       = "attempts"
+	FieldWorker         = "worker"
+	FieldCancelReason   = "cancel_reason"
+	FieldPriority       = "priority"
+	FieldArgs           = "args"
+	FieldStartTime      = "start_time"
+	FieldFinishTime     = "finish_time"
+	FieldStepsDone      = "steps_done"
+	FieldStepsTotal     = "steps_total"
+	FieldCheckRunID     = "check_run_id"
+	FieldGitRemote      = "git_remote"
+	FieldBaseBranch     = "base_branch"
+)
+
+// fields lists every job attribute in the order used for MGET/MSET.
+var fields = []string{
+	FieldPRNumber,
+	FieldPRSHA,
+	FieldAuthor,
+	FieldInstallationID,
+	FieldRepoOwner,
+	FieldRepoName,
+	FieldJobType,
+	FieldErrors,
+	FieldRequestTime,
+	FieldDuration,
+	FieldStatus,
+	FieldS3URL,
+	FieldModelName,
+	FieldCmd,
+	FieldAttempts,
+	FieldWorker,
+	FieldCancelReason,
+	FieldPriority,
+	FieldArgs,
+	FieldStartTime,
+	FieldFinishTime,
+	FieldStepsDone,
+	FieldStepsTotal,
+	FieldCheckRunID,
+	FieldGitRemote,
+	FieldBaseBranch,
+}
+
+// ErrNotFound is returned when a job does not exist in the store.
+var ErrNotFound = errors.New("job not found")
+
+// Job is a single unit of work requested on a pull request. Args holds the
+// command arguments that change the results of the job. StartTime and
+// FinishTime are zero until the job starts running and finishes. StepsDone
+// and StepsTotal count the progress of a running job. CheckRunID is the
+// GitHub check run reporting the job, zero until it is created. GitRemote and
+// BaseBranch locate the repository of the pull request; when empty, the
+// worker uses its own defaults.
+type Job struct {
+	ID             string
+	PRNumber       int
+	PRSHA          string
+	Author         string
+	InstallationID int64
+	RepoOwner      string
+	RepoName       string
+	JobType        string
+	Errors         string
+	RequestTime    time.Time
+	Duration       time.Duration
+	Status         Status
+	S3URL          string
+	ModelName      string
+	Cmd            string
+	Attempts       int
+	Worker         string
+	CancelReason   string
+	Priority       Priority
+	Args           string
+	StartTime      time.Time
+	FinishTime     time.Time
+	StepsDone      int
+	StepsTotal     int
+	CheckRunID     int64
+	GitRemote      string
+	BaseBranch     string
+}
+
+// Active reports whether a job is still queued or running.
+func (j *Job) Active() bool {
+	return j.Status == StatusPending || j.Status == StatusRunning
+}
+
+// PercentComplete returns the progress of a job, or -1 if it reported none.
+func (j *Job) PercentComplete() int {
+	if j.StepsTotal <= 0 {
+		return -1
+	}
+	return min(100, 100*j.StepsDone/j.StepsTotal)
+}
+
+// NoChanges reports whether a job completed without anything to do on the
+// pull request.
+func (j *Job) NoChanges() bool {
+	return j.Status == StatusSuccess && j.S3URL == ""
+}
+
+// Cancelled reports whether a job has been flagged for cancellation.
+func (j *Job) Cancelled() bool {
+	return j.CancelReason != ""
+}
+
+// Result holds what a worker records when a job completes successfully. The
+// S3URL is empty when the job had nothing to do on the pull request.
+type Result struct {
+	Duration  time.Duration
+	S3URL     string
+	ModelName string
+	Cmd       string
+}
+
+// Event notifies subscribers that a job changed status, or that its
+// cancellation was requested. A cancellation request carries its reason and
+// the status of the job at the time.
+type Event struct {
+	JobID        string `json:"job_id"`
+	Status       Status `json:"status"`
+	CancelReason string `json:"cancel_reason,omitempty"`
+}
+
+// CancelRequested reports whether the event is a cancellation request rather
+// than a status transition.
+func (e Event) CancelRequested() bool {
+	return e.CancelReason != ""
+}
+
+// JobStore persists jobs and their state transitions. Every status change and
+// cancellation request is also published as an Event on ChannelEvents.
+type JobStore interface {
+	// Create allocates a new job ID, stores the job as pending and returns the ID.
+	Create(ctx context.Context, job *Job) (string, error)
+	// Get returns the job with the given ID, or ErrNotFound.
+	Get(ctx context.Context, id string) (*Job, error)
+	// UpdateStatus sets the status of a job.
+	UpdateStatus(ctx context.Context, id string, status Status) error
+	// Transition sets the status of a job only if it is one of from, and
+	// reports whether it did. Of the bot and a worker racing to change the
+	// status of a job, only one wins.
+	Transition(ctx context.Context, id string, to Status, from ...Status) (bool, error)
+	// Complete records the result of a successful job.
+	Complete(ctx context.Context, id string, result Result) error
+	// Fail records the error of a failed job.
+	Fail(ctx context.Context, id string, jobErr error) error
+	// Cancel flags a job for cancellation with the given reason, and
+	// publishes the request. A worker skips a flagged job, or stops it if it
+	// is already running.
+	Cancel(ctx context.Context, id, reason string) error
+	// List returns all jobs in the store ordered by ID. It scans the whole
+	// keyspace, Running and ListPR read an index instead.
+	List(ctx context.Context) ([]*Job, error)
+	// Running returns the running jobs ordered by ID.
+	Running(ctx context.Context) ([]*Job, error)
+	// ListPR returns the jobs of a pull request still in the store ordered by
+	// ID.
+	ListPR(ctx context.Context, repoOwner, repoName string, prNum int) ([]*Job, error)
+	// SetCheckRun records the GitHub check run reporting a job.
+	SetCheckRun(ctx context.Context, id string, checkRunID int64) error
+	// Progress records how many of the steps of a running job are done.
+	Progress(ctx context.Context, id string, done, total int) error
+	// Expire removes a job and its log from the store once the ttl has elapsed.
+	Expire(ctx context.Context, id string, ttl time.Duration) error
+}
+
+// Key returns the Redis key of a job attribute.
+func Key(id, field string) string {
+	return fmt.Sprintf("%s:%s:%s", KeyJobs, id, field)
+}
+
+// PRKey returns the key of the set of the IDs of the jobs of a pull request.
+func PRKey(repoOwner, repoName string, prNum int) string {
+	return fmt.Sprintf("pr:%s/%s/%d:jobs", repoOwner, repoName, prNum)
+}
+
+// prKey returns the PRKey of the pull request of a job.
+func (j *Job) prKey() string {
+	return PRKey(j.RepoOwner, j.RepoName, j.PRNumber)
+}
+
+// encode returns the key/value pairs of a job suitable for MSET.
+func (j *Job) encode() []interface{} {
+	values := map[string]string{
+		FieldPRNumber:       strconv.Itoa(j.PRNumber),
+		FieldPRSHA:          j.PRSHA,
+		FieldAuthor:         j.Author,
+		FieldInstallationID: strconv.FormatInt(j.InstallationID, 10),
+		FieldRepoOwner:      j.RepoOwner,
+		FieldRepoName:       j.RepoName,
+		FieldJobType:        j.JobType,
+		FieldErrors:         j.Errors,
+		FieldRequestTime:    formatTime(j.RequestTime),
+		FieldStatus:         string(j.Status),
+		FieldPriority:       string(j.Priority),
+		FieldArgs:           j.Args,
+		FieldGitRemote:      j.GitRemote,
+		FieldBaseBranch:     j.BaseBranch,
+	}
+	pairs := make([]interface{}, 0, 2*len(values))
+	for _, field := range fields {
+		if value, ok := values[field]; ok {
+			pairs = append(pairs, Key(j.ID, field), value)
+		}
+	}
+	return pairs
+}
+
+// keys returns the Redis keys of every job attribute, in the order of fields.
+func keys(id string) []string {
+	ks := make([]string, len(fields))
+	for i, field := range fields {
+		ks[i] = Key(id, field)
+	}
+	return ks
+}
+
+// decode builds a job from values read in the order of fields.
+func decode(id string, values []string) (*Job, error) {
+	v := make(map[string]string, len(fields))
+	for i, field := range fields {
+		if i < len(values) {
+			v[field] = values[i]
+		}
+	}
+	if v[FieldJobType] == "" {
+		return nil, ErrNotFound
+	}
+
+	job := &Job{
+		ID:           id,
+		PRSHA:        v[FieldPRSHA],
+		Author:       v[FieldAuthor],
+		RepoOwner:    v[FieldRepoOwner],
+		RepoName:     v[FieldRepoName],
+		JobType:      v[FieldJobType],
+		Errors:       v[FieldErrors],
+		Status:       Status(v[FieldStatus]),
+		S3URL:        v[FieldS3URL],
+		ModelName:    v[FieldModelName],
+		Cmd:          v[FieldCmd],
+		Worker:       v[FieldWorker],
+		CancelReason: v[FieldCancelReason],
+		Priority:     Priority(v[FieldPriority]),
+		Args:         v[FieldArgs],
+		GitRemote:    v[FieldGitRemote],
+		BaseBranch:   v[FieldBaseBranch],
+	}
+
+	var err error
+	if v[FieldPRNumber] != "" {
+		if job.PRNumber, err = strconv.Atoi(v[FieldPRNumber]); err != nil {
+			return nil, fmt.Errorf("invalid %s for job %s: %w", FieldPRNumber, id, err)
+		}
+	}
+	for field, n := range map[string]*int{
+		FieldAttempts:   &job.Attempts,
+		FieldStepsDone:  &job.StepsDone,
+		FieldStepsTotal: &job.StepsTotal,
+	} {
+		if v[field] == "" {
+			continue
+		}
+		if *n, err = strconv.Atoi(v[field]); err != nil {
+			return nil, fmt.Errorf("invalid %s for job %s: %w", field, id, err)
+		}
+	}
+	for field, n := range map[string]*int64{
+		FieldInstallationID: &job.InstallationID,
+		FieldCheckRunID:     &job.CheckRunID,
+	} {
+		if v[field] == "" {
+			continue
+		}
+		if *n, err = strconv.ParseInt(v[field], 10, 64); err != nil {
+			return nil, fmt.Errorf("invalid %s for job %s: %w", field, id, err)
+		}
+	}
+	for field, t := range map[string]*time.Time{
+		FieldRequestTime: &job.RequestTime,
+		FieldStartTime:   &job.StartTime,
+		FieldFinishTime:  &job.FinishTime,
+	} {
+		if v[field] == "" {
+			continue
+		}
+		seconds, err := strconv.ParseInt(v[field], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s for job %s: %w", field, id, err)
+		}
+		*t = time.Unix(seconds, 0)
+	}
+	if v[FieldDuration] != "" {
+		// Older workers wrote the duration as a float
+		duration, err := strconv.ParseFloat(v[FieldDuration], 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s for job %s: %w", FieldDuration, id, err)
+		}
+		job.Duration = time.Duration(duration) * time.Second
+	}
+	return job, nil
+}
+
+// prepare fills in the defaults of a job about to be created.
+func (j *Job) prepare(id string) {
+	j.ID = id
+	if j.Status == "" {
+		j.Status = StatusPending
+	}
+	if j.Priority == "" {
+		j.Priority = PriorityNormal
+	}
+	if j.RequestTime.IsZero() {
+		j.RequestTime = time.Now()
+	}
+}
+
+// encodeEvent returns the pub/sub payload of an event.
+func encodeEvent(event Event) string {
+	payload, _ := json.Marshal(event)
+	return string(payload)
+}
+
+// DecodeEvent parses a message published on ChannelEvents.
+func DecodeEvent(payload string) (Event, error) {
+	var event Event
+	err := json.Unmarshal([]byte(payload), &event)
+	return event, err
+}
+
+// transitionSource is the Lua script of Transition. KEYS holds the keys of
+// statusPairs followed by KeyRunning, and ARGV their values followed by the
+// job ID and the statuses the job may leave. It records the status change and
+// keeps KeyRunning up to date only if the current status is one of them, and
+// returns 1 if it did.
+const transitionSource = `
+local current = redis.call('GET', KEYS[1])
+local allowed = false
+for i = #KEYS + 1, #ARGV do
+	if ARGV[i] == current then
+		allowed = true
+	end
+end
+if not allowed then
+	return 0
+end
+for i = 1, #KEYS - 1 do
+	redis.call('SET', KEYS[i], ARGV[i])
+end
+if ARGV[1] == 'running' then
+	redis.call('SADD', KEYS[#KEYS], ARGV[#KEYS])
+else
+	redis.call('SREM', KEYS[#KEYS], ARGV[#KEYS])
+end
+return 1
+`
+
+// transitionArgs returns the keys and the arguments of transitionSource.
+func transitionArgs(id string, to Status, from []Status) ([]string, []interface{}) {
+	pairs := statusPairs(id, to)
+	var ks []string
+	var args []interface{}
+	for i := 0; i < len(pairs); i += 2 {
+		ks = append(ks, pairs[i].(string))
+		args = append(args, pairs[i+1])
+	}
+	ks = append(ks, KeyRunning)
+	args = append(args, id)
+	for _, status := range from {
+		args = append(args, string(status))
+	}
+	return ks, args
+}
+
+// statusPairs returns the key/value pairs recording a status change of a job,
+// along with the time the job started or finished.
+func statusPairs(id string, status Status) []interface{} {
+	pairs := []interface{}{Key(id, FieldStatus), string(status)}
+	switch status {
+	case StatusRunning:
+		pairs = append(pairs, Key(id, FieldStartTime), formatTime(time.Now()))
+	case StatusSuccess, StatusError, StatusCancelled, StatusSuperseded:
+		pairs = append(pairs, Key(id, FieldFinishTime), formatTime(time.Now()))
+	}
+	return pairs
+}
+
+// formatTime stores a time as Unix seconds.
+func formatTime(t time.Time) string {
+	return strconv.FormatInt(t.Unix(), 10)
+}
+
+// formatDuration rounds a job duration up to whole seconds.
+func formatDuration(d time.Duration) string {
+	return strconv.FormatInt(int64(math.Ceil(d.Seconds())), 10)
+}
+
+// idsFromKeys extracts the job IDs from a list of job attribute keys.
+func idsFromKeys(ks []string) []string {
+	ids := make([]string, 0, len(ks))
+	prefix := KeyJobs + ":"
+	for _, k := range ks {
+		rest := strings.TrimPrefix(k, prefix)
+		if i := strings.Index(rest, ":"); i > 0 {
+			ids = append(ids, rest[:i])
+		}
+	}
+	return ids
+}
+
+// missingIDs returns the IDs of an index that list, read from it, lacks: the
+// jobs that expired since they were indexed.
+func missingIDs(ids []string, list []*Job) []interface{} {
+	found := make(map[string]bool, len(list))
+	for _, job := range list {
+		found[job.ID] = true
+	}
+	var missing []interface{}
+	for _, id := range ids {
+		if !found[id] {
+			missing = append(missing, id)
+		}
+	}
+	return missing
+}
+
+// listJobs fetches the given jobs from the store, ordered by numeric ID.
+func listJobs(ctx context.Context, store JobStore, ids []string) ([]*Job, error) {
+	sort.Slice(ids, func(i, j int) bool {
+		a, errA := strconv.ParseInt(ids[i], 10, 64)
+		b, errB := strconv.ParseInt(ids[j], 10, 64)
+		if errA != nil || errB != nil {
+			return ids[i] < ids[j]
+		}
+		return a < b
+	})
+
+	list := make([]*Job, 0, len(ids))
+	for _, id := range ids {
+		job, err := store.Get(ctx, id)
+		if errors.Is(err, ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, job)
+	}
+	return list, nil
+}
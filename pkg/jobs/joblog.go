@@ -0,0 +1,110 @@
+package jobs
+
+import (
+	"bytes"
+	"context"
+	"sync"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+const (
+	// keyLog is the job attribute holding the log stream of a job.
+	keyLog = "log"
+	// logFieldLine is the entry field holding a log line.
+	logFieldLine = "line"
+	// logMaxLen caps the number of lines kept in the log of a job.
+	logMaxLen = 1000
+	// logMaxLineLen caps the length of a log line, longer lines are cut.
+	logMaxLineLen = 1024
+)
+
+// LogKey returns the Redis stream holding the log lines of a job.
+func LogKey(id string) string {
+	return Key(id, keyLog)
+}
+
+// LogWriter appends the lines written to it to the log stream of a job.
+// Progress bars redrawing a line with a carriage return give a line per
+// redraw.
+type LogWriter struct {
+	ctx     context.Context
+	pool    *redis.Pool
+	id      string
+	redact  func(string) string
+	mu      sync.Mutex
+	partial []byte
+}
+
+// NewLogWriter returns a LogWriter for the job with the given ID.
+func NewLogWriter(ctx context.Context, pool *redis.Pool, id string) *LogWriter {
+	return &LogWriter{ctx: ctx, pool: pool, id: id}
+}
+
+// WithRedaction makes the writer pass every line through redact before
+// appending it, to keep secrets out of the log.
+func (l *LogWriter) WithRedaction(redact func(string) string) *LogWriter {
+	l.redact = redact
+	return l
+}
+
+// Write appends the complete lines of p to the log, and keeps the rest until
+// the line is complete. Redis errors are ignored so a command writing to the
+// log does not fail because of it.
+func (l *LogWriter) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.partial = append(l.partial, p...)
+	var lines [][]byte
+	for {
+		i := bytes.IndexAny(l.partial, "\r\n")
+		if i < 0 {
+			break
+		}
+		if line := bytes.TrimSpace(l.partial[:i]); len(line) > 0 {
+			lines = append(lines, line)
+		}
+		l.partial = l.partial[i+1:]
+	}
+	if len(l.partial) > logMaxLineLen {
+		lines = append(lines, l.partial)
+		l.partial = nil
+	}
+	l.append(lines)
+	return len(p), nil
+}
+
+// Flush appends the incomplete line written last, if any.
+func (l *LogWriter) Flush() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if line := bytes.TrimSpace(l.partial); len(line) > 0 {
+		l.append([][]byte{line})
+	}
+	l.partial = nil
+}
+
+func (l *LogWriter) append(lines [][]byte) {
+	if len(lines) == 0 {
+		return
+	}
+	conn, err := l.pool.GetContext(l.ctx)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	for _, line := range lines {
+		if l.redact != nil {
+			line = []byte(l.redact(string(line)))
+		}
+		if len(line) > logMaxLineLen {
+			line = line[:logMaxLineLen]
+		}
+		_ = conn.Send("XADD", LogKey(l.id), "MAXLEN", "~", logMaxLen, "*", logFieldLine, string(line))
+	}
+	_ = conn.Flush()
+	for range lines {
+		_, _ = conn.Receive()
+	}
+}
@@ -0,0 +1,74 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	goredis "github.com/go-redis/redis/v8"
+	"github.com/gomodule/redigo/redis"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestJobLog verifies the lines written by the worker are tailed by the bot,
+// and expire with the job.
+func TestJobLog(t *testing.T) {
+	ctx := context.Background()
+	mr := miniredis.RunT(t)
+	client := goredis.NewClient(&goredis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { client.Close() })
+	pool := &redis.Pool{
+		Dial: func() (redis.Conn, error) {
+			return redis.Dial("tcp", mr.Addr())
+		},
+	}
+	t.Cleanup(func() { pool.Close() })
+	store := NewRedisStore(client)
+
+	id, err := store.Create(ctx, &Job{JobType: TypeGenerateLocal})
+	require.NoError(t, err)
+	log := NewLogWriter(ctx, pool, id)
+
+	lines, err := store.Tail(ctx, id, 10)
+	require.NoError(t, err)
+	assert.Empty(t, lines)
+
+	// Lines are split on newlines and carriage returns, partial lines wait
+	_, err = fmt.Fprint(log, "starting\n 10%\r 50%\r\n\nhalf")
+	require.NoError(t, err)
+	_, err = fmt.Fprint(log, " done\nno newline")
+	require.NoError(t, err)
+	log.Flush()
+
+	lines, err = store.Tail(ctx, id, 10)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"starting", "10%", "50%", "half done", "no newline"}, lines)
+	lines, err = store.Tail(ctx, id, 2)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"half done", "no newline"}, lines)
+
+	// Overlong lines are cut
+	_, err = fmt.Fprint(log, strings.Repeat("x", 2*logMaxLineLen))
+	require.NoError(t, err)
+	lines, err = store.Tail(ctx, id, 1)
+	require.NoError(t, err)
+	assert.Len(t, lines[0], logMaxLineLen)
+
+	// Lines are redacted before they are stored
+	log.WithRedaction(func(line string) string { return strings.ReplaceAll(line, "sk-1234", "REDACTED") })
+	_, err = fmt.Fprint(log, "ilab model chat --api-key sk-1234\n")
+	require.NoError(t, err)
+	lines, err = store.Tail(ctx, id, 1)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"ilab model chat --api-key REDACTED"}, lines)
+
+	require.NoError(t, store.Expire(ctx, id, time.Hour))
+	mr.FastForward(time.Hour)
+	lines, err = store.Tail(ctx, id, 10)
+	require.NoError(t, err)
+	assert.Empty(t, lines)
+}
@@ -0,0 +1,222 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+var _ Consumer = (*ListConsumer)(nil)
+
+// ListConsumer is the worker side of the lists backend.
+type ListConsumer struct {
+	pool     *redis.Pool
+	workerID string
+	jobTypes []string
+	slots    int
+	// queues are polled in order, so jobs of the first types are taken first.
+	queues []string
+	// next is the queue to block on when all of them are empty, rotated so
+	// every job type is waited on in turn.
+	next atomic.Uint32
+}
+
+// NewListConsumer returns the queue consumer of the given worker, consuming
+// jobs of the given types with up to slots jobs at the same time.
+func NewListConsumer(pool *redis.Pool, workerID string, jobTypes []string, slots int) *ListConsumer {
+	queues := make([]string, 0, len(jobTypes))
+	for _, jobType := range jobTypes {
+		queues = append(queues, GenerateQueue(jobType))
+	}
+	return &ListConsumer{pool: pool, workerID: workerID, jobTypes: jobTypes, slots: slots, queues: queues}
+}
+
+// Dequeue moves the next job into the worker's processing list. The job
+// leaves the processing list once the delivery is acknowledged. A job
+// already waiting on any queue is taken right away, otherwise Dequeue blocks
+// on a single queue as BLMOVE cannot watch several lists.
+func (q *ListConsumer) Dequeue(ctx context.Context, timeout time.Duration) (*Delivery, error) {
+	if len(q.queues) == 0 {
+		return nil, errors.New("no job types to consume")
+	}
+	conn, err := q.pool.GetContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	for _, queue := range q.queues {
+		id, err := redis.String(redis.DoContext(conn, ctx, "LMOVE",
+			queue, ProcessingQueue(q.workerID), "RIGHT", "LEFT"))
+		if errors.Is(err, redis.ErrNil) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		return q.deliver(ctx, conn, queue, id)
+	}
+
+	queue := q.queues[int(q.next.Add(1))%len(q.queues)]
+	id, err := redis.String(redis.DoContext(conn, ctx, "BLMOVE",
+		queue, ProcessingQueue(q.workerID), "RIGHT", "LEFT", timeout.Seconds()))
+	if errors.Is(err, redis.ErrNil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return q.deliver(ctx, conn, queue, id)
+}
+
+// deliver claims a job moved from queue into the processing list.
+func (q *ListConsumer) deliver(ctx context.Context, conn redis.Conn, queue, id string) (*Delivery, error) {
+	delivery := &Delivery{
+		JobID: id,
+		ack: func(ctx context.Context) error {
+			return q.ack(ctx, id)
+		},
+		requeue: func(ctx context.Context) error {
+			return q.requeue(ctx, queue, id)
+		},
+	}
+	return delivery, claim(ctx, conn, id, q.workerID)
+}
+
+func (q *ListConsumer) ack(ctx context.Context, id string) error {
+	conn, err := q.pool.GetContext(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	_, err = redis.DoContext(conn, ctx, "LREM", ProcessingQueue(q.workerID), 1, id)
+	return err
+}
+
+// requeue moves a job from the processing list back to the consuming end of
+// the queue it came from. A job that finished meanwhile only leaves the
+// processing list.
+func (q *ListConsumer) requeue(ctx context.Context, queue, id string) error {
+	requeued, err := NewRedigoStore(q.pool).Transition(ctx, id, StatusPending, StatusPending, StatusRunning)
+	if err != nil {
+		return err
+	}
+
+	conn, err := q.pool.GetContext(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if !requeued {
+		_, err = redis.DoContext(conn, ctx, "LREM", ProcessingQueue(q.workerID), 1, id)
+		return err
+	}
+	_ = conn.Send("MULTI")
+	_ = conn.Send("LREM", ProcessingQueue(q.workerID), 1, id)
+	_ = conn.Send("RPUSH", queue, id)
+	_ = conn.Send("DECR", Key(id, FieldAttempts))
+	_, err = redis.DoContext(conn, ctx, "EXEC")
+	return err
+}
+
+// PostResult pushes a finished job to QueueResults.
+func (q *ListConsumer) PostResult(ctx context.Context, id string) error {
+	conn, err := q.pool.GetContext(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	_, err = redis.DoContext(conn, ctx, "LPUSH", QueueResults, id)
+	return err
+}
+
+// Heartbeat marks the worker alive for the next ttl.
+func (q *ListConsumer) Heartbeat(ctx context.Context, ttl time.Duration) error {
+	conn, err := q.pool.GetContext(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	return heartbeat(ctx, conn, q.workerID, q.jobTypes, q.slots, ttl)
+}
+
+// Pending returns the content of every processing list, whatever the type of
+// the jobs.
+func (q *ListConsumer) Pending(ctx context.Context) ([]Pending, error) {
+	conn, err := q.pool.GetContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	var pending []Pending
+	cursor := 0
+	for {
+		var queues []string
+		reply, err := redis.Values(redis.DoContext(conn, ctx, "SCAN", cursor, "MATCH", ProcessingQueue("*")))
+		if err != nil {
+			return nil, err
+		}
+		if _, err := redis.Scan(reply, &cursor, &queues); err != nil {
+			return nil, err
+		}
+		for _, queue := range queues {
+			ids, err := redis.Strings(redis.DoContext(conn, ctx, "LRANGE", queue, 0, -1))
+			if err != nil {
+				return nil, err
+			}
+			for _, id := range ids {
+				attempts, err := redis.Int(redis.DoContext(conn, ctx, "GET", Key(id, FieldAttempts)))
+				if err != nil && !errors.Is(err, redis.ErrNil) {
+					return nil, err
+				}
+				pending = append(pending, Pending{
+					JobID:    id,
+					WorkerID: strings.TrimPrefix(queue, ProcessingQueue("")),
+					Attempts: attempts,
+				})
+			}
+		}
+		if cursor == 0 {
+			return pending, nil
+		}
+	}
+}
+
+// claim counts a new attempt of a job and records the worker running it.
+func claim(ctx context.Context, conn redis.Conn, id, workerID string) error {
+	if _, err := redis.DoContext(conn, ctx, "INCR", Key(id, FieldAttempts)); err != nil {
+		return err
+	}
+	_, err := redis.DoContext(conn, ctx, "SET", Key(id, FieldWorker), workerID)
+	return err
+}
+
+// heartbeat refreshes the heartbeat key of a worker, and the job slots it
+// registered in the ConsumersKey of its job types.
+func heartbeat(ctx context.Context, conn redis.Conn, workerID string, jobTypes []string, slots int, ttl time.Duration) error {
+	now := time.Now()
+	expiry := now.Add(ttl).UnixMilli()
+	_ = conn.Send("MULTI")
+	_ = conn.Send("SET", HeartbeatKey(workerID), now.Unix(), "PX", ttl.Milliseconds())
+	for _, jobType := range jobTypes {
+		args := redis.Args{ConsumersKey(jobType)}
+		for i := 0; i < slots; i++ {
+			args = args.Add(expiry, fmt.Sprintf("%s/%d", workerID, i))
+		}
+		if len(args) > 1 {
+			_ = conn.Send("ZADD", args...)
+		}
+	}
+	_, err := redis.DoContext(conn, ctx, "EXEC")
+	return err
+}
@@ -0,0 +1,180 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	goredis "github.com/go-redis/redis/v8"
+	"github.com/gomodule/redigo/redis"
+)
+
+// Each job type has a queue of its own, and a worker only consumes the queues
+// of the job types it accepts.
+//
+// Jobs travel between the bot and the workers over one of two transports,
+// selected with the --queue-backend flag of both binaries:
+//
+// The lists backend consumes the generate queues with the reliable-queue
+// pattern: a worker atomically moves a job from a GenerateQueue into its own
+// processing list and keeps a heartbeat key alive while it is running. When
+// a worker dies, its heartbeat expires and the bot's reaper moves the jobs
+// left in its processing list back to the queue of their type, or to
+// QueueDeadLetter once they have used up their attempts.
+//
+// The streams backend appends jobs to a GenerateStream and results to
+// StreamResults, read through the GroupWorkers and GroupBot consumer groups.
+// Entries stay pending in their group until acknowledged. Workers keep the
+// entries they are running fresh by claiming them again on every heartbeat,
+// and the bot reclaims entries idle for longer than the visibility timeout
+// with XAUTOCLAIM, then requeues or dead-letters them.
+
+// Queue backends.
+const (
+	BackendLists   = "lists"
+	BackendStreams = "streams"
+)
+
+const (
+	keyProcessing = "processing"
+	keyWorkers    = "workers"
+	keyConsumers  = "consumers"
+)
+
+// ProcessingQueue returns the processing list of a worker.
+func ProcessingQueue(workerID string) string {
+	return keyProcessing + ":" + workerID
+}
+
+// HeartbeatKey returns the key a worker refreshes while it is alive.
+func HeartbeatKey(workerID string) string {
+	return keyWorkers + ":" + workerID + ":heartbeat"
+}
+
+// ConsumersKey returns the sorted set of the job slots of the workers
+// consuming a job type, scored by the time their heartbeat expires in Unix
+// milliseconds.
+func ConsumersKey(jobType string) string {
+	return keyConsumers + ":" + jobType
+}
+
+// Consumers returns the number of job slots of the live workers consuming a
+// job type, and forgets the slots of the dead ones.
+func Consumers(ctx context.Context, client *goredis.Client, jobType string) (int, error) {
+	key := ConsumersKey(jobType)
+	now := strconv.FormatInt(time.Now().UnixMilli(), 10)
+	if err := client.ZRemRangeByScore(ctx, key, "-inf", now).Err(); err != nil {
+		return 0, err
+	}
+	n, err := client.ZCard(ctx, key).Result()
+	return int(n), err
+}
+
+// Delivery is a job handed out by a transport. It is delivered again unless
+// it is acknowledged once handled.
+type Delivery struct {
+	JobID   string
+	ack     func(ctx context.Context) error
+	requeue func(ctx context.Context) error
+}
+
+// Ack acknowledges the delivery.
+func (d *Delivery) Ack(ctx context.Context) error {
+	if d.ack == nil {
+		return nil
+	}
+	return d.ack(ctx)
+}
+
+// Requeue hands the job back to the queue as pending without counting the
+// attempt, for a worker that stops before finishing it.
+func (d *Delivery) Requeue(ctx context.Context) error {
+	if d.requeue == nil {
+		return nil
+	}
+	return d.requeue(ctx)
+}
+
+// Pending is a job claimed by a worker and not acknowledged yet.
+type Pending struct {
+	JobID    string
+	WorkerID string
+	Attempts int
+	// Idle is the time since the entry was last claimed. It is only known
+	// with the streams backend.
+	Idle time.Duration
+}
+
+// Dispatcher is the bot side of a job transport.
+type Dispatcher interface {
+	// Enqueue hands a job over to the workers accepting its type.
+	Enqueue(ctx context.Context, id, jobType string) error
+	// NextResult waits up to timeout for a finished job. It returns nil when
+	// none arrived in time.
+	NextResult(ctx context.Context, timeout time.Duration) (*Delivery, error)
+	// Recover requeues the jobs abandoned by dead workers, and dead-letters
+	// those that used up their attempts.
+	Recover(ctx context.Context) ([]Reaped, error)
+	// Waiting returns the number of jobs of a type enqueued and not picked up
+	// by a worker yet.
+	Waiting(ctx context.Context, jobType string) (int, error)
+}
+
+// Consumer is the worker side of a job transport.
+type Consumer interface {
+	// Dequeue waits up to timeout for a job of the types the consumer
+	// accepts and claims it for this worker, counting a new attempt. It
+	// returns nil when no job arrived in time.
+	Dequeue(ctx context.Context, timeout time.Duration) (*Delivery, error)
+	// PostResult notifies the bot that a job finished.
+	PostResult(ctx context.Context, id string) error
+	// Heartbeat keeps the jobs claimed by this worker for the next ttl, and
+	// registers its job slots with the consumers of its job types.
+	Heartbeat(ctx context.Context, ttl time.Duration) error
+	// Pending lists the unacknowledged jobs of every worker.
+	Pending(ctx context.Context) ([]Pending, error)
+}
+
+// DispatcherOptions configures how a Dispatcher recovers abandoned jobs.
+type DispatcherOptions struct {
+	// MaxAttempts is the number of attempts after which an abandoned job is
+	// dead-lettered.
+	MaxAttempts int
+	// VisibilityTimeout is how long a streams entry may go unclaimed before
+	// it is considered abandoned.
+	VisibilityTimeout time.Duration
+}
+
+// ValidateBackend checks a queue backend given in the configuration.
+func ValidateBackend(backend string) error {
+	if backend != BackendLists && backend != BackendStreams {
+		return fmt.Errorf("unknown queue backend %q, expected %s or %s", backend, BackendLists, BackendStreams)
+	}
+	return nil
+}
+
+// NewDispatcher returns the bot side of the given queue backend.
+func NewDispatcher(ctx context.Context, backend string, client *goredis.Client, opts DispatcherOptions) (Dispatcher, error) {
+	switch backend {
+	case BackendLists:
+		return NewListDispatcher(client, opts.MaxAttempts), nil
+	case BackendStreams:
+		return NewStreamDispatcher(ctx, client, opts)
+	default:
+		return nil, fmt.Errorf("unknown queue backend %q", backend)
+	}
+}
+
+// NewConsumer returns the worker side of the given queue backend, consuming
+// jobs of the given types with up to slots jobs at the same time.
+func NewConsumer(ctx context.Context, backend string, pool *redis.Pool, workerID string, jobTypes []string, slots int) (Consumer, error) {
+	switch backend {
+	case BackendLists:
+		return NewListConsumer(pool, workerID, jobTypes, slots), nil
+	case BackendStreams:
+		return NewStreamConsumer(ctx, pool, workerID, jobTypes, slots)
+	default:
+		return nil, fmt.Errorf("unknown queue backend %q", backend)
+	}
+}
@@ -0,0 +1,355 @@
+package jobs
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	goredis "github.com/go-redis/redis/v8"
+	"github.com/gomodule/redigo/redis"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestReaperRecoversAbandonedJobs verifies jobs of a dead worker are requeued
+// until they run out of attempts, and are dead-lettered afterwards.
+func TestReaperRecoversAbandonedJobs(t *testing.T) {
+	ctx := context.Background()
+	mr := miniredis.RunT(t)
+	client := goredis.NewClient(&goredis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { client.Close() })
+	pool := &redis.Pool{
+		Dial: func() (redis.Conn, error) {
+			return redis.Dial("tcp", mr.Addr())
+		},
+	}
+	t.Cleanup(func() { pool.Close() })
+
+	store := NewRedisStore(client)
+	id, err := store.Create(ctx, &Job{PRNumber: 1, JobType: TypePrecheck})
+	require.NoError(t, err)
+	require.NoError(t, client.LPush(ctx, GenerateQueue(TypePrecheck), id).Err())
+
+	queue := NewListConsumer(pool, "worker-a", Types, 1)
+	reaper := NewReaper(client, 2)
+
+	// A live worker keeps its job
+	require.NoError(t, queue.Heartbeat(ctx, time.Minute))
+	got, err := queue.Dequeue(ctx, time.Second)
+	require.NoError(t, err)
+	assert.Equal(t, id, got.JobID)
+	reaped, err := reaper.Reap(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, reaped)
+
+	// Once the heartbeat expires the job goes back to the generate queue
+	mr.FastForward(2 * time.Minute)
+	reaped, err = reaper.Reap(ctx)
+	require.NoError(t, err)
+	require.Len(t, reaped, 1)
+	assert.False(t, reaped[0].DeadLettered)
+	assert.Equal(t, []string{id}, mustList(t, mr, GenerateQueue(TypePrecheck)))
+
+	// The second abandoned attempt is dead-lettered and reported
+	got, err = queue.Dequeue(ctx, time.Second)
+	require.NoError(t, err)
+	assert.Equal(t, id, got.JobID)
+	pending, err := queue.Pending(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []Pending{{JobID: id, WorkerID: "worker-a", Attempts: 2}}, pending)
+	reaped, err = reaper.Reap(ctx)
+	require.NoError(t, err)
+	require.Len(t, reaped, 1)
+	assert.True(t, reaped[0].DeadLettered)
+	assert.Equal(t, 2, reaped[0].Attempts)
+	assert.Equal(t, []string{id}, mustList(t, mr, QueueDeadLetter))
+	assert.Equal(t, []string{id}, mustList(t, mr, QueueResults))
+
+	job, err := store.Get(ctx, id)
+	require.NoError(t, err)
+	assert.Equal(t, StatusError, job.Status)
+
+	// An acknowledged job is never reaped
+	require.NoError(t, client.LPush(ctx, GenerateQueue(TypePrecheck), id).Err())
+	got, err = queue.Dequeue(ctx, time.Second)
+	require.NoError(t, err)
+	require.NoError(t, got.Ack(ctx))
+	assert.False(t, mr.Exists(ProcessingQueue("worker-a")))
+
+	// A job without a type is dead-lettered instead of being requeued
+	require.NoError(t, client.LPush(ctx, ProcessingQueue("worker-b"), "404").Err())
+	reaped, err = reaper.Reap(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []Reaped{{JobID: "404", WorkerID: "worker-b", DeadLettered: true}}, reaped)
+	assert.Equal(t, []string{"404", id}, mustList(t, mr, QueueDeadLetter))
+	assert.False(t, mr.Exists(GenerateQueue("")))
+
+	// A finished job, left behind by a worker dying before acknowledging it,
+	// is dropped instead of being run again
+	done, err := store.Create(ctx, &Job{PRNumber: 2, JobType: TypePrecheck})
+	require.NoError(t, err)
+	require.NoError(t, store.Complete(ctx, done, Result{S3URL: "https://example.com/index.html"}))
+	require.NoError(t, client.LPush(ctx, ProcessingQueue("worker-c"), done).Err())
+	reaped, err = reaper.Reap(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []Reaped{{JobID: done, WorkerID: "worker-c", Finished: true}}, reaped)
+	assert.False(t, mr.Exists(ProcessingQueue("worker-c")))
+	assert.False(t, mr.Exists(GenerateQueue(TypePrecheck)))
+	job, err = store.Get(ctx, done)
+	require.NoError(t, err)
+	assert.Equal(t, StatusSuccess, job.Status)
+}
+
+// TestStreamRecoversAbandonedJobs verifies stream entries are kept alive by
+// heartbeats, reclaimed once idle and dead-lettered after their attempts.
+func TestStreamRecoversAbandonedJobs(t *testing.T) {
+	ctx := context.Background()
+	mr := miniredis.RunT(t)
+	now := time.Now()
+	mr.SetTime(now)
+	client := goredis.NewClient(&goredis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { client.Close() })
+	pool := &redis.Pool{
+		Dial: func() (redis.Conn, error) {
+			return redis.Dial("tcp", mr.Addr())
+		},
+	}
+	t.Cleanup(func() { pool.Close() })
+
+	dispatcher, err := NewDispatcher(ctx, BackendStreams, client, DispatcherOptions{
+		MaxAttempts:       2,
+		VisibilityTimeout: time.Minute,
+	})
+	require.NoError(t, err)
+	queue, err := NewConsumer(ctx, BackendStreams, pool, "worker-a", Types, 1)
+	require.NoError(t, err)
+
+	store := NewRedisStore(client)
+	id, err := store.Create(ctx, &Job{PRNumber: 1, JobType: TypePrecheck})
+	require.NoError(t, err)
+	require.NoError(t, dispatcher.Enqueue(ctx, id, TypePrecheck))
+
+	// A job claimed again by its worker's heartbeat is not reclaimed
+	got, err := queue.Dequeue(ctx, time.Second)
+	require.NoError(t, err)
+	require.NotNil(t, got)
+	assert.Equal(t, id, got.JobID)
+	now = now.Add(50 * time.Second)
+	mr.SetTime(now)
+	require.NoError(t, queue.Heartbeat(ctx, time.Minute))
+	now = now.Add(50 * time.Second)
+	mr.SetTime(now)
+	reaped, err := dispatcher.Recover(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, reaped)
+
+	pending, err := queue.Pending(ctx)
+	require.NoError(t, err)
+	require.Len(t, pending, 1)
+	assert.Equal(t, id, pending[0].JobID)
+	assert.Equal(t, "worker-a", pending[0].WorkerID)
+	assert.Equal(t, 1, pending[0].Attempts)
+	assert.Equal(t, 50*time.Second, pending[0].Idle)
+
+	// Once idle for too long the job is appended again
+	now = now.Add(time.Minute)
+	mr.SetTime(now)
+	reaped, err = dispatcher.Recover(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []Reaped{{JobID: id, WorkerID: "worker-a", Attempts: 1}}, reaped)
+
+	// The second abandoned attempt is dead-lettered and reported
+	got, err = queue.Dequeue(ctx, time.Second)
+	require.NoError(t, err)
+	require.NotNil(t, got)
+	assert.Equal(t, id, got.JobID)
+	now = now.Add(2 * time.Minute)
+	mr.SetTime(now)
+	reaped, err = dispatcher.Recover(ctx)
+	require.NoError(t, err)
+	require.Len(t, reaped, 1)
+	assert.True(t, reaped[0].DeadLettered)
+	assert.Equal(t, []string{id}, mustList(t, mr, QueueDeadLetter))
+
+	result, err := dispatcher.NextResult(ctx, time.Second)
+	require.NoError(t, err)
+	require.NotNil(t, result)
+	assert.Equal(t, id, result.JobID)
+	require.NoError(t, result.Ack(ctx))
+
+	job, err := store.Get(ctx, id)
+	require.NoError(t, err)
+	assert.Equal(t, StatusError, job.Status)
+
+	// Acknowledged jobs and results are not delivered again
+	pending, err = queue.Pending(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, pending)
+	require.NoError(t, queue.PostResult(ctx, id))
+	result, err = dispatcher.NextResult(ctx, time.Second)
+	require.NoError(t, err)
+	require.NotNil(t, result)
+	require.NoError(t, result.Ack(ctx))
+	result, err = dispatcher.NextResult(ctx, 10*time.Millisecond)
+	require.NoError(t, err)
+	assert.Nil(t, result)
+	// A finished job whose entry was never acknowledged is dropped
+	require.NoError(t, dispatcher.Enqueue(ctx, id, TypePrecheck))
+	got, err = queue.Dequeue(ctx, time.Second)
+	require.NoError(t, err)
+	require.NotNil(t, got)
+	now = now.Add(2 * time.Minute)
+	mr.SetTime(now)
+	reaped, err = dispatcher.Recover(ctx)
+	require.NoError(t, err)
+	require.Len(t, reaped, 1)
+	assert.True(t, reaped[0].Finished)
+	assert.False(t, reaped[0].DeadLettered)
+	pending, err = queue.Pending(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, pending)
+	assert.Equal(t, []string{id}, mustList(t, mr, QueueDeadLetter))
+}
+
+// TestRequeueReturnsJobToQueue verifies a requeued job is delivered again
+// without its interrupted attempt being counted.
+func TestRequeueReturnsJobToQueue(t *testing.T) {
+	for _, backend := range []string{BackendLists, BackendStreams} {
+		t.Run(backend, func(t *testing.T) {
+			ctx := context.Background()
+			mr := miniredis.RunT(t)
+			client := goredis.NewClient(&goredis.Options{Addr: mr.Addr()})
+			t.Cleanup(func() { client.Close() })
+			pool := &redis.Pool{
+				Dial: func() (redis.Conn, error) {
+					return redis.Dial("tcp", mr.Addr())
+				},
+			}
+			t.Cleanup(func() { pool.Close() })
+
+			dispatcher, err := NewDispatcher(ctx, backend, client, DispatcherOptions{
+				MaxAttempts:       2,
+				VisibilityTimeout: time.Minute,
+			})
+			require.NoError(t, err)
+			queue, err := NewConsumer(ctx, backend, pool, "worker-a", Types, 1)
+			require.NoError(t, err)
+
+			store := NewRedisStore(client)
+			id, err := store.Create(ctx, &Job{PRNumber: 1, JobType: TypePrecheck})
+			require.NoError(t, err)
+			require.NoError(t, dispatcher.Enqueue(ctx, id, TypePrecheck))
+
+			got, err := queue.Dequeue(ctx, time.Second)
+			require.NoError(t, err)
+			require.NotNil(t, got)
+			require.NoError(t, store.UpdateStatus(ctx, id, StatusRunning))
+			require.NoError(t, got.Requeue(ctx))
+
+			pending, err := queue.Pending(ctx)
+			require.NoError(t, err)
+			assert.Empty(t, pending)
+			job, err := store.Get(ctx, id)
+			require.NoError(t, err)
+			assert.Equal(t, StatusPending, job.Status)
+			assert.Equal(t, 0, job.Attempts)
+
+			got, err = queue.Dequeue(ctx, time.Second)
+			require.NoError(t, err)
+			require.NotNil(t, got)
+			assert.Equal(t, id, got.JobID)
+			require.NoError(t, got.Ack(ctx))
+
+			// A job that finished meanwhile only leaves the processing list
+			require.NoError(t, dispatcher.Enqueue(ctx, id, TypePrecheck))
+			got, err = queue.Dequeue(ctx, time.Second)
+			require.NoError(t, err)
+			require.NotNil(t, got)
+			require.NoError(t, store.Complete(ctx, id, Result{S3URL: "https://example.com/index.html"}))
+			require.NoError(t, got.Requeue(ctx))
+			pending, err = queue.Pending(ctx)
+			require.NoError(t, err)
+			assert.Empty(t, pending)
+			job, err = store.Get(ctx, id)
+			require.NoError(t, err)
+			assert.Equal(t, StatusSuccess, job.Status)
+			got, err = queue.Dequeue(ctx, 10*time.Millisecond)
+			require.NoError(t, err)
+			assert.Nil(t, got)
+		})
+	}
+}
+
+// TestConsumerRoutesJobTypes verifies workers only receive the job types they
+// accept.
+func TestConsumerRoutesJobTypes(t *testing.T) {
+	for _, backend := range []string{BackendLists, BackendStreams} {
+		t.Run(backend, func(t *testing.T) {
+			ctx := context.Background()
+			mr := miniredis.RunT(t)
+			client := goredis.NewClient(&goredis.Options{Addr: mr.Addr()})
+			t.Cleanup(func() { client.Close() })
+			pool := &redis.Pool{
+				Dial: func() (redis.Conn, error) {
+					return redis.Dial("tcp", mr.Addr())
+				},
+			}
+			t.Cleanup(func() { pool.Close() })
+
+			dispatcher, err := NewDispatcher(ctx, backend, client, DispatcherOptions{
+				MaxAttempts:       2,
+				VisibilityTimeout: time.Minute,
+			})
+			require.NoError(t, err)
+			sdgWorker, err := NewConsumer(ctx, backend, pool, "worker-sdg", []string{TypeSDG}, 1)
+			require.NoError(t, err)
+			anyWorker, err := NewConsumer(ctx, backend, pool, "worker-any", Types, 1)
+			require.NoError(t, err)
+
+			store := NewRedisStore(client)
+			precheck, err := store.Create(ctx, &Job{PRNumber: 1, JobType: TypePrecheck})
+			require.NoError(t, err)
+			require.NoError(t, dispatcher.Enqueue(ctx, precheck, TypePrecheck))
+
+			got, err := sdgWorker.Dequeue(ctx, 10*time.Millisecond)
+			require.NoError(t, err)
+			assert.Nil(t, got)
+
+			sdg, err := store.Create(ctx, &Job{PRNumber: 1, JobType: TypeSDG})
+			require.NoError(t, err)
+			require.NoError(t, dispatcher.Enqueue(ctx, sdg, TypeSDG))
+			got, err = sdgWorker.Dequeue(ctx, time.Second)
+			require.NoError(t, err)
+			require.NotNil(t, got)
+			assert.Equal(t, sdg, got.JobID)
+			require.NoError(t, got.Ack(ctx))
+
+			got, err = anyWorker.Dequeue(ctx, time.Second)
+			require.NoError(t, err)
+			require.NotNil(t, got)
+			assert.Equal(t, precheck, got.JobID)
+			require.NoError(t, got.Ack(ctx))
+		})
+	}
+}
+
+func TestParseTypes(t *testing.T) {
+	parsed, err := ParseTypes(nil)
+	require.NoError(t, err)
+	assert.Equal(t, Types, parsed)
+
+	parsed, err = ParseTypes([]string{"sdg-svc", " precheck", "sdg-svc"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{TypePrecheck, TypeSDG}, parsed)
+
+	_, err = ParseTypes([]string{"train"})
+	assert.Error(t, err)
+}
+
+func mustList(t *testing.T, mr *miniredis.Miniredis, key string) []string {
+	t.Helper()
+	list, err := mr.List(key)
+	require.NoError(t, err)
+	return list
+}
@@ -0,0 +1,176 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+var _ Dispatcher = (*ListDispatcher)(nil)
+
+// ListDispatcher is the bot side of the lists backend.
+type ListDispatcher struct {
+	client *redis.Client
+	reaper *Reaper
+}
+
+// NewListDispatcher returns a dispatcher that dead-letters abandoned jobs
+// after maxAttempts attempts.
+func NewListDispatcher(client *redis.Client, maxAttempts int) *ListDispatcher {
+	return &ListDispatcher{client: client, reaper: NewReaper(client, maxAttempts)}
+}
+
+// Enqueue pushes a job to the GenerateQueue of its type.
+func (d *ListDispatcher) Enqueue(ctx context.Context, id, jobType string) error {
+	return d.client.LPush(ctx, GenerateQueue(jobType), id).Err()
+}
+
+// NextResult moves the next finished job from QueueResults to QueueArchived.
+// Its delivery needs no acknowledgement.
+func (d *ListDispatcher) NextResult(ctx context.Context, timeout time.Duration) (*Delivery, error) {
+	id, err := d.client.BLMove(ctx, QueueResults, QueueArchived, "RIGHT", "LEFT", timeout).Result()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &Delivery{JobID: id}, nil
+}
+
+// Waiting returns the length of the GenerateQueue of a type.
+func (d *ListDispatcher) Waiting(ctx context.Context, jobType string) (int, error) {
+	n, err := d.client.LLen(ctx, GenerateQueue(jobType)).Result()
+	return int(n), err
+}
+
+// Recover runs the reaper.
+func (d *ListDispatcher) Recover(ctx context.Context) ([]Reaped, error) {
+	return d.reaper.Reap(ctx)
+}
+
+// Reaper recovers the jobs left in the processing lists of dead workers.
+type Reaper struct {
+	client      *redis.Client
+	store       *RedisStore
+	maxAttempts int
+}
+
+// Reaped describes a job recovered from a dead worker.
+type Reaped struct {
+	JobID        string
+	WorkerID     string
+	Attempts     int
+	DeadLettered bool
+	// Finished is set for a job that had already finished, left behind by a
+	// worker that died between reporting it and acknowledging it. It is
+	// dropped instead of being run again.
+	Finished bool
+}
+
+// NewReaper returns a reaper that dead-letters jobs after maxAttempts attempts.
+func NewReaper(client *redis.Client, maxAttempts int) *Reaper {
+	return &Reaper{
+		client:      client,
+		store:       NewRedisStore(client),
+		maxAttempts: maxAttempts,
+	}
+}
+
+// Reap requeues the jobs of every worker whose heartbeat has expired. Jobs
+// that already used up their attempts, or that have no type, are failed, moved to QueueDeadLetter
+// and pushed to QueueResults so the bot can report the failure. Jobs that
+// already finished are dropped from the processing list.
+func (r *Reaper) Reap(ctx context.Context) ([]Reaped, error) {
+	var reaped []Reaped
+	iter := r.client.Scan(ctx, 0, ProcessingQueue("*"), 0).Iterator()
+	for iter.Next(ctx) {
+		queue := iter.Val()
+		workerID := strings.TrimPrefix(queue, ProcessingQueue(""))
+
+		alive, err := r.client.Exists(ctx, HeartbeatKey(workerID)).Result()
+		if err != nil {
+			return reaped, err
+		}
+		if alive > 0 {
+			continue
+		}
+
+		for {
+			id, err := r.client.LIndex(ctx, queue, -1).Result()
+			if err == redis.Nil {
+				break
+			}
+			if err != nil {
+				return reaped, err
+			}
+
+			attempts, err := r.client.Get(ctx, Key(id, FieldAttempts)).Int()
+			if err != nil && err != redis.Nil {
+				return reaped, err
+			}
+
+			jobType, err := r.client.Get(ctx, Key(id, FieldJobType)).Result()
+			if err != nil && err != redis.Nil {
+				return reaped, err
+			}
+			status, err := r.client.Get(ctx, Key(id, FieldStatus)).Result()
+			if err != nil && err != redis.Nil {
+				return reaped, err
+			}
+
+			item := Reaped{JobID: id, WorkerID: workerID, Attempts: attempts}
+			switch {
+			case jobType == "":
+				// No queue would ever run it again
+				item.DeadLettered = true
+				err = r.deadLetter(ctx, queue, item, fmt.Errorf("job was abandoned by worker %s and has no type", workerID))
+			case Status(status) != StatusPending && Status(status) != StatusRunning:
+				item.Finished = true
+				err = r.client.LRem(ctx, queue, -1, id).Err()
+			case attempts >= r.maxAttempts:
+				item.DeadLettered = true
+				err = r.deadLetter(ctx, queue, item, fmt.Errorf("job was abandoned by worker %s and exceeded the maximum of %d attempts", workerID, r.maxAttempts))
+			default:
+				var requeued bool
+				requeued, err = r.requeue(ctx, queue, id, jobType)
+				item.Finished = !requeued
+			}
+			if err != nil {
+				return reaped, err
+			}
+			reaped = append(reaped, item)
+		}
+	}
+	return reaped, iter.Err()
+}
+
+// requeue puts a job back at the head of the queue of its type, and reports
+// whether it did. A job that finished meanwhile is dropped from the
+// processing list instead.
+func (r *Reaper) requeue(ctx context.Context, queue, id, jobType string) (bool, error) {
+	requeued, err := r.store.Transition(ctx, id, StatusPending, StatusPending, StatusRunning)
+	if err != nil {
+		return false, err
+	}
+	if !requeued {
+		return false, r.client.LRem(ctx, queue, -1, id).Err()
+	}
+	return true, r.client.LMove(ctx, queue, GenerateQueue(jobType), "RIGHT", "RIGHT").Err()
+}
+
+// deadLetter fails a job with jobErr and parks it in the dead-letter queue.
+func (r *Reaper) deadLetter(ctx context.Context, queue string, item Reaped, jobErr error) error {
+	if err := r.store.Fail(ctx, item.JobID, jobErr); err != nil {
+		return err
+	}
+	_, err := r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.LMove(ctx, queue, QueueDeadLetter, "RIGHT", "LEFT")
+		pipe.LPush(ctx, QueueResults, item.JobID)
+		return nil
+	})
+	return err
+}
@@ -0,0 +1,212 @@
+package jobs
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+var _ JobStore = (*RedigoStore)(nil)
+
+// redigoTransitionScript takes its number of keys as its first argument.
+var redigoTransitionScript = redis.NewScript(-1, transitionSource)
+
+// RedigoStore is a JobStore backed by a redigo connection pool.
+type RedigoStore struct {
+	pool *redis.Pool
+}
+
+// NewRedigoStore returns a JobStore using the given redigo pool.
+func NewRedigoStore(pool *redis.Pool) *RedigoStore {
+	return &RedigoStore{pool: pool}
+}
+
+func (s *RedigoStore) do(ctx context.Context, command string, args ...interface{}) (interface{}, error) {
+	conn, err := s.pool.GetContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	return redis.DoContext(conn, ctx, command, args...)
+}
+
+func (s *RedigoStore) Create(ctx context.Context, job *Job) (string, error) {
+	jobNumber, err := redis.Int64(s.do(ctx, "INCR", KeyJobs))
+	if err != nil {
+		return "", err
+	}
+	job.prepare(strconv.FormatInt(jobNumber, 10))
+	err = s.multi(ctx, func(conn redis.Conn) {
+		_ = conn.Send("MSET", job.encode()...)
+		_ = conn.Send("SADD", job.prKey(), job.ID)
+	})
+	if err != nil {
+		return "", err
+	}
+	return job.ID, s.publish(ctx, job.ID, job.Status)
+}
+
+func (s *RedigoStore) Get(ctx context.Context, id string) (*Job, error) {
+	values, err := redis.Strings(s.do(ctx, "MGET", redis.Args{}.AddFlat(keys(id))...))
+	if err != nil {
+		return nil, err
+	}
+	return decode(id, values)
+}
+
+func (s *RedigoStore) UpdateStatus(ctx context.Context, id string, status Status) error {
+	return s.setStatus(ctx, id, status)
+}
+
+func (s *RedigoStore) Transition(ctx context.Context, id string, to Status, from ...Status) (bool, error) {
+	conn, err := s.pool.GetContext(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close()
+	ks, args := transitionArgs(id, to, from)
+	changed, err := redis.Bool(redigoTransitionScript.DoContext(ctx, conn, append(redis.Args{len(ks)}.AddFlat(ks), args...)...))
+	if err != nil || !changed {
+		return false, err
+	}
+	return true, s.publish(ctx, id, to)
+}
+
+func (s *RedigoStore) Complete(ctx context.Context, id string, result Result) error {
+	return s.setStatus(ctx, id, StatusSuccess,
+		Key(id, FieldDuration), formatDuration(result.Duration),
+		Key(id, FieldS3URL), result.S3URL,
+		Key(id, FieldCmd), result.Cmd,
+		Key(id, FieldModelName), result.ModelName,
+	)
+}
+
+func (s *RedigoStore) Fail(ctx context.Context, id string, jobErr error) error {
+	return s.setStatus(ctx, id, StatusError, Key(id, FieldErrors), jobErr.Error())
+}
+
+func (s *RedigoStore) Cancel(ctx context.Context, id, reason string) error {
+	conn, err := s.pool.GetContext(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	_ = conn.Send("MULTI")
+	_ = conn.Send("SET", Key(id, FieldCancelReason), reason)
+	_ = conn.Send("GET", Key(id, FieldStatus))
+	reply, err := redis.Values(redis.DoContext(conn, ctx, "EXEC"))
+	if err != nil {
+		return err
+	}
+	status, err := redis.String(reply[1], nil)
+	if err != nil && err != redis.ErrNil {
+		return err
+	}
+	_, err = redis.DoContext(conn, ctx, "PUBLISH", ChannelEvents, encodeEvent(Event{JobID: id, Status: Status(status), CancelReason: reason}))
+	return err
+}
+
+func (s *RedigoStore) List(ctx context.Context) ([]*Job, error) {
+	var ids []string
+	cursor := 0
+	for {
+		reply, err := redis.Values(s.do(ctx, "SCAN", cursor, "MATCH", Key("*", FieldJobType)))
+		if err != nil {
+			return nil, err
+		}
+		var ks []string
+		if _, err := redis.Scan(reply, &cursor, &ks); err != nil {
+			return nil, err
+		}
+		ids = append(ids, idsFromKeys(ks)...)
+		if cursor == 0 {
+			break
+		}
+	}
+	return listJobs(ctx, s, ids)
+}
+
+func (s *RedigoStore) Running(ctx context.Context) ([]*Job, error) {
+	return s.listIndex(ctx, KeyRunning)
+}
+
+func (s *RedigoStore) ListPR(ctx context.Context, repoOwner, repoName string, prNum int) ([]*Job, error) {
+	return s.listIndex(ctx, PRKey(repoOwner, repoName, prNum))
+}
+
+// listIndex returns the jobs of an index set, and drops the expired ones from
+// it.
+func (s *RedigoStore) listIndex(ctx context.Context, key string) ([]*Job, error) {
+	ids, err := redis.Strings(s.do(ctx, "SMEMBERS", key))
+	if err != nil {
+		return nil, err
+	}
+	list, err := listJobs(ctx, s, ids)
+	if err != nil {
+		return nil, err
+	}
+	if missing := missingIDs(ids, list); len(missing) > 0 {
+		if _, err := s.do(ctx, "SREM", append([]interface{}{key}, missing...)...); err != nil {
+			return nil, err
+		}
+	}
+	return list, nil
+}
+
+func (s *RedigoStore) SetCheckRun(ctx context.Context, id string, checkRunID int64) error {
+	_, err := s.do(ctx, "SET", Key(id, FieldCheckRunID), checkRunID)
+	return err
+}
+
+func (s *RedigoStore) Progress(ctx context.Context, id string, done, total int) error {
+	_, err := s.do(ctx, "MSET",
+		Key(id, FieldStepsDone), done,
+		Key(id, FieldStepsTotal), total,
+	)
+	return err
+}
+
+func (s *RedigoStore) Expire(ctx context.Context, id string, ttl time.Duration) error {
+	return s.multi(ctx, func(conn redis.Conn) {
+		for _, key := range append(keys(id), LogKey(id)) {
+			_ = conn.Send("PEXPIRE", key, ttl.Milliseconds())
+		}
+	})
+}
+
+// multi runs the commands queued by send in a transaction.
+func (s *RedigoStore) multi(ctx context.Context, send func(conn redis.Conn)) error {
+	conn, err := s.pool.GetContext(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	_ = conn.Send("MULTI")
+	send(conn)
+	_, err = redis.DoContext(conn, ctx, "EXEC")
+	return err
+}
+
+// setStatus records a status change along with the given key/value pairs,
+// keeps the set of the running jobs up to date and publishes the change.
+func (s *RedigoStore) setStatus(ctx context.Context, id string, status Status, pairs ...interface{}) error {
+	err := s.multi(ctx, func(conn redis.Conn) {
+		_ = conn.Send("MSET", append(pairs, statusPairs(id, status)...)...)
+		if status == StatusRunning {
+			_ = conn.Send("SADD", KeyRunning, id)
+		} else {
+			_ = conn.Send("SREM", KeyRunning, id)
+		}
+	})
+	if err != nil {
+		return err
+	}
+	return s.publish(ctx, id, status)
+}
+
+func (s *RedigoStore) publish(ctx context.Context, id string, status Status) error {
+	_, err := s.do(ctx, "PUBLISH", ChannelEvents, encodeEvent(Event{JobID: id, Status: status}))
+	return err
+}
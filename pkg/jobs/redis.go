@@ -0,0 +1,233 @@
+package jobs
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+var _ JobStore = (*RedisStore)(nil)
+
+var transitionScript = redis.NewScript(transitionSource)
+
+// RedisStore is a JobStore backed by a go-redis client.
+type RedisStore struct {
+	client *redis.Client
+}
+
+// NewRedisStore returns a JobStore using the given go-redis client.
+func NewRedisStore(client *redis.Client) *RedisStore {
+	return &RedisStore{client: client}
+}
+
+func (s *RedisStore) Create(ctx context.Context, job *Job) (string, error) {
+	jobNumber, err := s.client.Incr(ctx, KeyJobs).Result()
+	if err != nil {
+		return "", err
+	}
+	job.prepare(strconv.FormatInt(jobNumber, 10))
+	_, err = s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.MSet(ctx, job.encode()...)
+		pipe.SAdd(ctx, job.prKey(), job.ID)
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return job.ID, s.publish(ctx, job.ID, job.Status)
+}
+
+func (s *RedisStore) Get(ctx context.Context, id string) (*Job, error) {
+	raw, err := s.client.MGet(ctx, keys(id)...).Result()
+	if err != nil {
+		return nil, err
+	}
+	values := make([]string, len(raw))
+	for i, value := range raw {
+		if str, ok := value.(string); ok {
+			values[i] = str
+		}
+	}
+	return decode(id, values)
+}
+
+func (s *RedisStore) UpdateStatus(ctx context.Context, id string, status Status) error {
+	return s.setStatus(ctx, id, status)
+}
+
+func (s *RedisStore) Transition(ctx context.Context, id string, to Status, from ...Status) (bool, error) {
+	ks, args := transitionArgs(id, to, from)
+	changed, err := transitionScript.Run(ctx, s.client, ks, args...).Bool()
+	if err != nil || !changed {
+		return false, err
+	}
+	return true, s.publish(ctx, id, to)
+}
+
+func (s *RedisStore) Complete(ctx context.Context, id string, result Result) error {
+	return s.setStatus(ctx, id, StatusSuccess,
+		Key(id, FieldDuration), formatDuration(result.Duration),
+		Key(id, FieldS3URL), result.S3URL,
+		Key(id, FieldCmd), result.Cmd,
+		Key(id, FieldModelName), result.ModelName,
+	)
+}
+
+func (s *RedisStore) Fail(ctx context.Context, id string, jobErr error) error {
+	return s.setStatus(ctx, id, StatusError, Key(id, FieldErrors), jobErr.Error())
+}
+
+func (s *RedisStore) Cancel(ctx context.Context, id, reason string) error {
+	var status *redis.StringCmd
+	_, err := s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Set(ctx, Key(id, FieldCancelReason), reason, 0)
+		status = pipe.Get(ctx, Key(id, FieldStatus))
+		return nil
+	})
+	if err != nil && err != redis.Nil {
+		return err
+	}
+	return s.client.Publish(ctx, ChannelEvents, encodeEvent(Event{JobID: id, Status: Status(status.Val()), CancelReason: reason})).Err()
+}
+
+func (s *RedisStore) List(ctx context.Context) ([]*Job, error) {
+	var ids []string
+	var cursor uint64
+	for {
+		ks, next, err := s.client.Scan(ctx, cursor, Key("*", FieldJobType), 0).Result()
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, idsFromKeys(ks)...)
+		cursor = next
+		if cursor == 0 {
+			break
+		}
+	}
+	return listJobs(ctx, s, ids)
+}
+
+func (s *RedisStore) Running(ctx context.Context) ([]*Job, error) {
+	return s.listIndex(ctx, KeyRunning)
+}
+
+func (s *RedisStore) ListPR(ctx context.Context, repoOwner, repoName string, prNum int) ([]*Job, error) {
+	return s.listIndex(ctx, PRKey(repoOwner, repoName, prNum))
+}
+
+// listIndex returns the jobs of an index set, and drops the expired ones from
+// it.
+func (s *RedisStore) listIndex(ctx context.Context, key string) ([]*Job, error) {
+	ids, err := s.client.SMembers(ctx, key).Result()
+	if err != nil {
+		return nil, err
+	}
+	list, err := listJobs(ctx, s, ids)
+	if err != nil {
+		return nil, err
+	}
+	if missing := missingIDs(ids, list); len(missing) > 0 {
+		if err := s.client.SRem(ctx, key, missing...).Err(); err != nil {
+			return nil, err
+		}
+	}
+	return list, nil
+}
+
+func (s *RedisStore) SetCheckRun(ctx context.Context, id string, checkRunID int64) error {
+	return s.client.Set(ctx, Key(id, FieldCheckRunID), strconv.FormatInt(checkRunID, 10), 0).Err()
+}
+
+func (s *RedisStore) Progress(ctx context.Context, id string, done, total int) error {
+	return s.client.MSet(ctx,
+		Key(id, FieldStepsDone), strconv.Itoa(done),
+		Key(id, FieldStepsTotal), strconv.Itoa(total),
+	).Err()
+}
+
+func (s *RedisStore) Expire(ctx context.Context, id string, ttl time.Duration) error {
+	_, err := s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, key := range append(keys(id), LogKey(id)) {
+			pipe.Expire(ctx, key, ttl)
+		}
+		return nil
+	})
+	return err
+}
+
+// Tail returns the last n lines of the log of a job, oldest first.
+func (s *RedisStore) Tail(ctx context.Context, id string, n int) ([]string, error) {
+	entries, err := s.client.XRevRangeN(ctx, LogKey(id), "+", "-", int64(n)).Result()
+	if err != nil {
+		return nil, err
+	}
+	lines := make([]string, 0, len(entries))
+	for i := len(entries) - 1; i >= 0; i-- {
+		if line, ok := entries[i].Values[logFieldLine].(string); ok {
+			lines = append(lines, line)
+		}
+	}
+	return lines, nil
+}
+
+// Subscribe streams the job events published on ChannelEvents until the
+// context is cancelled.
+func (s *RedisStore) Subscribe(ctx context.Context) (<-chan Event, error) {
+	sub := s.client.Subscribe(ctx, ChannelEvents)
+	// Wait for the subscription to be confirmed so no event is missed
+	if _, err := sub.Receive(ctx); err != nil {
+		sub.Close()
+		return nil, err
+	}
+
+	events := make(chan Event)
+	go func() {
+		defer close(events)
+		defer sub.Close()
+		messages := sub.Channel()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case msg, ok := <-messages:
+				if !ok {
+					return
+				}
+				event, err := DecodeEvent(msg.Payload)
+				if err != nil {
+					continue
+				}
+				select {
+				case events <- event:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+	return events, nil
+}
+
+// setStatus records a status change along with the given key/value pairs,
+// keeps the set of the running jobs up to date and publishes the change.
+func (s *RedisStore) setStatus(ctx context.Context, id string, status Status, pairs ...interface{}) error {
+	_, err := s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.MSet(ctx, append(pairs, statusPairs(id, status)...)...)
+		if status == StatusRunning {
+			pipe.SAdd(ctx, KeyRunning, id)
+		} else {
+			pipe.SRem(ctx, KeyRunning, id)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return s.publish(ctx, id, status)
+}
+
+func (s *RedisStore) publish(ctx context.Context, id string, status Status) error {
+	return s.client.Publish(ctx, ChannelEvents, encodeEvent(Event{JobID: id, Status: status})).Err()
+}
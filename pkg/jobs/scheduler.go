@@ -0,0 +1,177 @@
+package jobs
+
+import (
+	"context"
+	"strconv"
+	"strings"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// Jobs requested on pull requests are not handed to the workers right away.
+// The bot first schedules them in a sorted set per job type and priority
+// class, and releases them to the queue backend as long as fewer jobs of
+// their type wait in the queue than the workers have slots for them. This keeps the order of the
+// waiting jobs under the bot's control:
+//
+//   - higher priority classes are released first,
+//   - within a class, jobs are served round-robin across pull requests and
+//     authors: the n-th waiting job of a pull request or an author is only
+//     released after the jobs of the n-1 previous rounds of everybody else.
+//
+// Across job types, a worker running several types takes the jobs of the
+// first type in Types first.
+
+const keySchedule = "schedule"
+
+// ScheduleKey returns the sorted set holding the scheduled jobs of a type and
+// priority class.
+func ScheduleKey(jobType string, priority Priority) string {
+	return keySchedule + ":" + jobType + ":" + string(priority)
+}
+
+// roundsKey returns the hash holding the last round of every pull request and
+// author of a schedule, and the round being released.
+func roundsKey(jobType string, priority Priority) string {
+	return ScheduleKey(jobType, priority) + ":rounds"
+}
+
+const fieldCurrentRound = "current"
+
+// scheduleScript adds a job to a schedule in the round following the last
+// one of its pull request and author, and returns its rank. Members are
+// prefixed with a zero-padded sequence number so jobs of the same round keep
+// their arrival order.
+var scheduleScript = redis.NewScript(`
+local round = tonumber(redis.call('HGET', KEYS[2], 'current') or '0')
+for i = 2, 3 do
+	local last = redis.call('HGET', KEYS[2], ARGV[i])
+	if last and tonumber(last) + 1 > round then
+		round = tonumber(last) + 1
+	end
+end
+redis.call('HSET', KEYS[2], ARGV[2], round, ARGV[3], round)
+local member = string.format('%012d:%s', redis.call('INCR', KEYS[3]), ARGV[1])
+redis.call('ZADD', KEYS[1], round, member)
+return redis.call('ZRANK', KEYS[1], member)
+`)
+
+// Scheduler orders the jobs waiting for a worker before handing them to a
+// Dispatcher.
+type Scheduler struct {
+	client     *redis.Client
+	store      *RedisStore
+	dispatcher Dispatcher
+}
+
+// NewScheduler returns a scheduler releasing jobs to the given dispatcher.
+func NewScheduler(client *redis.Client, dispatcher Dispatcher) *Scheduler {
+	return &Scheduler{client: client, store: NewRedisStore(client), dispatcher: dispatcher}
+}
+
+// Schedule adds a job to the schedule of its type and returns its position
+// among the jobs of that type waiting for a worker, starting at 1.
+func (s *Scheduler) Schedule(ctx context.Context, job *Job) (int, error) {
+	priority := job.Priority
+	if priority == "" {
+		priority = PriorityNormal
+	}
+	rank, err := scheduleScript.Run(ctx, s.client,
+		[]string{ScheduleKey(job.JobType, priority), roundsKey(job.JobType, priority), keySchedule + ":seq"},
+		job.ID,
+		"pr:"+job.RepoOwner+"/"+job.RepoName+"#"+strconv.Itoa(job.PRNumber),
+		"author:"+job.Author,
+	).Int()
+	if err != nil {
+		return 0, err
+	}
+
+	ahead, err := s.dispatcher.Waiting(ctx, job.JobType)
+	if err != nil {
+		return 0, err
+	}
+	for _, p := range Priorities {
+		if p == priority {
+			break
+		}
+		n, err := s.client.ZCard(ctx, ScheduleKey(job.JobType, p)).Result()
+		if err != nil {
+			return 0, err
+		}
+		ahead += int(n)
+	}
+	return ahead + rank + 1, nil
+}
+
+// Release hands the next scheduled jobs of every type to the dispatcher until
+// as many jobs wait in the queue of the type as the live workers have slots
+// for it, or a single one when no worker registered any, and returns the IDs
+// of the released jobs. Jobs cancelled while scheduled are dropped.
+func (s *Scheduler) Release(ctx context.Context) ([]string, error) {
+	var released []string
+	for _, jobType := range Types {
+		waiting, err := s.dispatcher.Waiting(ctx, jobType)
+		if err != nil {
+			return released, err
+		}
+		slots, err := Consumers(ctx, s.client, jobType)
+		if err != nil {
+			return released, err
+		}
+		for ; waiting < max(slots, 1); waiting++ {
+			id, err := s.releaseNext(ctx, jobType)
+			if err != nil {
+				return released, err
+			}
+			if id == "" {
+				break
+			}
+			released = append(released, id)
+		}
+	}
+	return released, nil
+}
+
+// releaseNext enqueues the first active job of the schedules of a type, from
+// the highest priority class down. The job leaves its schedule only once
+// enqueued, so a failure may release it twice but never loses it.
+func (s *Scheduler) releaseNext(ctx context.Context, jobType string) (string, error) {
+	for _, priority := range Priorities {
+		key := ScheduleKey(jobType, priority)
+		for {
+			next, err := s.client.ZRangeWithScores(ctx, key, 0, 0).Result()
+			if err != nil {
+				return "", err
+			}
+			if len(next) == 0 {
+				break
+			}
+			member, _ := next[0].Member.(string)
+			id := member[strings.Index(member, ":")+1:]
+
+			job, err := s.store.Get(ctx, id)
+			if err != nil && err != ErrNotFound {
+				return "", err
+			}
+			active := err == nil && job.Status == StatusPending && !job.Cancelled()
+			if active {
+				if err := s.dispatcher.Enqueue(ctx, id, jobType); err != nil {
+					return "", err
+				}
+			}
+
+			_, err = s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+				pipe.ZRem(ctx, key, member)
+				pipe.HSet(ctx, roundsKey(jobType, priority), fieldCurrentRound, next[0].Score)
+				return nil
+			})
+			if err != nil {
+				return "", err
+			}
+			if active {
+				return id, nil
+			}
+		}
+	}
+	return "", nil
+}
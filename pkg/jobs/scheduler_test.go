@@ -0,0 +1,168 @@
+package jobs
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	goredis "github.com/go-redis/redis/v8"
+	"github.com/gomodule/redigo/redis"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestSchedulerOrdersJobs verifies jobs are released by priority class, then
+// round-robin across pull requests and authors, one at a time per type when
+// no worker registered its slots.
+func TestSchedulerOrdersJobs(t *testing.T) {
+	for _, backend := range []string{BackendLists, BackendStreams} {
+		t.Run(backend, func(t *testing.T) {
+			ctx := context.Background()
+			mr := miniredis.RunT(t)
+			client := goredis.NewClient(&goredis.Options{Addr: mr.Addr()})
+			t.Cleanup(func() { client.Close() })
+			pool := &redis.Pool{
+				Dial: func() (redis.Conn, error) {
+					return redis.Dial("tcp", mr.Addr())
+				},
+			}
+			t.Cleanup(func() { pool.Close() })
+
+			dispatcher, err := NewDispatcher(ctx, backend, client, DispatcherOptions{
+				MaxAttempts:       2,
+				VisibilityTimeout: time.Minute,
+			})
+			require.NoError(t, err)
+			queue, err := NewConsumer(ctx, backend, pool, "worker-a", Types, 1)
+			require.NoError(t, err)
+			scheduler := NewScheduler(client, dispatcher)
+			store := NewRedisStore(client)
+
+			schedule := func(pr int, author string, priority Priority) (string, int) {
+				job := &Job{PRNumber: pr, Author: author, JobType: TypePrecheck, Priority: priority}
+				_, err := store.Create(ctx, job)
+				require.NoError(t, err)
+				position, err := scheduler.Schedule(ctx, job)
+				require.NoError(t, err)
+				return job.ID, position
+			}
+
+			a1, pos := schedule(1, "alice", PriorityNormal)
+			assert.Equal(t, 1, pos)
+			a2, pos := schedule(1, "alice", PriorityNormal)
+			assert.Equal(t, 2, pos)
+			a3, _ := schedule(1, "alice", PriorityNormal)
+			// Another author's PR goes ahead of the second job of PR 1
+			b1, pos := schedule(2, "bob", PriorityNormal)
+			assert.Equal(t, 2, pos)
+			// The same author on another PR waits behind their earlier jobs
+			c1, pos := schedule(3, "alice", PriorityNormal)
+			assert.Equal(t, 5, pos)
+			h1, pos := schedule(4, "carol", PriorityHigh)
+			assert.Equal(t, 1, pos)
+			cancelled, _ := schedule(5, "dave", PriorityNormal)
+			require.NoError(t, store.Cancel(ctx, cancelled, "test"))
+
+			var order []string
+			for {
+				released, err := scheduler.Release(ctx)
+				require.NoError(t, err)
+				if len(released) == 0 {
+					break
+				}
+				require.Len(t, released, 1)
+
+				// Nothing else is released until the queue is drained
+				again, err := scheduler.Release(ctx)
+				require.NoError(t, err)
+				assert.Empty(t, again)
+
+				got, err := queue.Dequeue(ctx, time.Second)
+				require.NoError(t, err)
+				require.NotNil(t, got)
+				assert.Equal(t, released[0], got.JobID)
+				require.NoError(t, got.Ack(ctx))
+				order = append(order, got.JobID)
+			}
+			assert.Equal(t, []string{h1, a1, b1, a2, a3, c1}, order)
+		})
+	}
+}
+
+// TestSchedulerFillsWorkerSlots verifies as many jobs of a type are released
+// as the live workers have slots for, and no more.
+func TestSchedulerFillsWorkerSlots(t *testing.T) {
+	for _, backend := range []string{BackendLists, BackendStreams} {
+		t.Run(backend, func(t *testing.T) {
+			ctx := context.Background()
+			mr := miniredis.RunT(t)
+			client := goredis.NewClient(&goredis.Options{Addr: mr.Addr()})
+			t.Cleanup(func() { client.Close() })
+			pool := &redis.Pool{
+				Dial: func() (redis.Conn, error) {
+					return redis.Dial("tcp", mr.Addr())
+				},
+			}
+			t.Cleanup(func() { pool.Close() })
+
+			dispatcher, err := NewDispatcher(ctx, backend, client, DispatcherOptions{
+				MaxAttempts:       2,
+				VisibilityTimeout: time.Minute,
+			})
+			require.NoError(t, err)
+			scheduler := NewScheduler(client, dispatcher)
+			store := NewRedisStore(client)
+			for pr := 1; pr <= 5; pr++ {
+				job := &Job{PRNumber: pr, Author: "alice", JobType: TypePrecheck}
+				_, err := store.Create(ctx, job)
+				require.NoError(t, err)
+				_, err = scheduler.Schedule(ctx, job)
+				require.NoError(t, err)
+			}
+
+			workerA, err := NewConsumer(ctx, backend, pool, "worker-a", Types, 2)
+			require.NoError(t, err)
+			require.NoError(t, workerA.Heartbeat(ctx, time.Minute))
+			workerB, err := NewConsumer(ctx, backend, pool, "worker-b", []string{TypeSDG}, 4)
+			require.NoError(t, err)
+			require.NoError(t, workerB.Heartbeat(ctx, time.Minute))
+			slots, err := Consumers(ctx, client, TypePrecheck)
+			require.NoError(t, err)
+			assert.Equal(t, 2, slots)
+
+			released, err := scheduler.Release(ctx)
+			require.NoError(t, err)
+			assert.Len(t, released, 2)
+			released, err = scheduler.Release(ctx)
+			require.NoError(t, err)
+			assert.Empty(t, released)
+
+			// A picked up job makes room for the next one
+			got, err := workerA.Dequeue(ctx, time.Second)
+			require.NoError(t, err)
+			require.NotNil(t, got)
+			released, err = scheduler.Release(ctx)
+			require.NoError(t, err)
+			assert.Len(t, released, 1)
+
+			// The slots of a dead worker are forgotten
+			workerC, err := NewConsumer(ctx, backend, pool, "worker-c", Types, 1)
+			require.NoError(t, err)
+			require.NoError(t, workerC.Heartbeat(ctx, time.Millisecond))
+			time.Sleep(10 * time.Millisecond)
+			slots, err = Consumers(ctx, client, TypePrecheck)
+			require.NoError(t, err)
+			assert.Equal(t, 2, slots)
+		})
+	}
+}
+
+func TestParsePriority(t *testing.T) {
+	priority, err := ParsePriority("high")
+	require.NoError(t, err)
+	assert.Equal(t, PriorityHigh, priority)
+
+	_, err = ParsePriority("urgent")
+	assert.Error(t, err)
+}
@@ -0,0 +1,223 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	goredis "github.com/go-redis/redis/v8"
+	"github.com/gomodule/redigo/redis"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// storeFactories build each JobStore implementation against its own in-memory Redis.
+var storeFactories = map[string]func(t *testing.T, mr *miniredis.Miniredis) JobStore{
+	"go-redis": func(t *testing.T, mr *miniredis.Miniredis) JobStore {
+		client := goredis.NewClient(&goredis.Options{Addr: mr.Addr()})
+		t.Cleanup(func() { client.Close() })
+		return NewRedisStore(client)
+	},
+	"redigo": func(t *testing.T, mr *miniredis.Miniredis) JobStore {
+		pool := &redis.Pool{
+			Dial: func() (redis.Conn, error) {
+				return redis.Dial("tcp", mr.Addr())
+			},
+		}
+		t.Cleanup(func() { pool.Close() })
+		return NewRedigoStore(pool)
+	},
+}
+
+// TestJobStoreLifecycle runs the same job lifecycle against both store implementations.
+func TestJobStoreLifecycle(t *testing.T) {
+	for name, newStore := range storeFactories {
+		t.Run(name, func(t *testing.T) {
+			mr := miniredis.RunT(t)
+			store := newStore(t, mr)
+			ctx := context.Background()
+			requestTime := time.Unix(1717000000, 0)
+
+			id, err := store.Create(ctx, &Job{
+				PRNumber:       42,
+				PRSHA:          "abc123",
+				Author:         "octocat",
+				InstallationID: 1234,
+				RepoOwner:      "instructlab",
+				RepoName:       "taxonomy",
+				JobType:        TypePrecheck,
+				RequestTime:    requestTime,
+				GitRemote:      "https://github.com/instructlab/taxonomy",
+				BaseBranch:     "main",
+			})
+			require.NoError(t, err)
+			assert.Equal(t, "1", id)
+
+			job, err := store.Get(ctx, id)
+			require.NoError(t, err)
+			assert.Equal(t, 42, job.PRNumber)
+			assert.Equal(t, int64(1234), job.InstallationID)
+			assert.Equal(t, TypePrecheck, job.JobType)
+			assert.Equal(t, StatusPending, job.Status)
+			assert.Equal(t, requestTime, job.RequestTime)
+			assert.Equal(t, "https://github.com/instructlab/taxonomy", job.GitRemote)
+			assert.Equal(t, "main", job.BaseBranch)
+
+			changed, err := store.Transition(ctx, id, StatusRunning, StatusPending)
+			require.NoError(t, err)
+			assert.True(t, changed)
+			job, err = store.Get(ctx, id)
+			require.NoError(t, err)
+			assert.Equal(t, StatusRunning, job.Status)
+			assert.False(t, job.StartTime.IsZero())
+			assert.True(t, job.FinishTime.IsZero())
+			assert.Equal(t, -1, job.PercentComplete())
+			running, err := store.Running(ctx)
+			require.NoError(t, err)
+			require.Len(t, running, 1)
+			assert.Equal(t, id, running[0].ID)
+
+			require.NoError(t, store.Progress(ctx, id, 1, 4))
+			job, err = store.Get(ctx, id)
+			require.NoError(t, err)
+			assert.Equal(t, 1, job.StepsDone)
+			assert.Equal(t, 4, job.StepsTotal)
+			assert.Equal(t, 25, job.PercentComplete())
+
+			require.NoError(t, store.SetCheckRun(ctx, id, 987654321))
+			job, err = store.Get(ctx, id)
+			require.NoError(t, err)
+			assert.Equal(t, int64(987654321), job.CheckRunID)
+
+			require.NoError(t, store.Complete(ctx, id, Result{
+				Duration:  1500 * time.Millisecond,
+				S3URL:     "https://example.com/index.html",
+				ModelName: "granite-7b-lab",
+				Cmd:       "ilab model chat",
+			}))
+			job, err = store.Get(ctx, id)
+			require.NoError(t, err)
+			assert.Equal(t, StatusSuccess, job.Status)
+			assert.Equal(t, 2*time.Second, job.Duration)
+			assert.Equal(t, "https://example.com/index.html", job.S3URL)
+			assert.Equal(t, "granite-7b-lab", job.ModelName)
+			assert.False(t, job.FinishTime.IsZero())
+			assert.False(t, job.NoChanges())
+			running, err = store.Running(ctx)
+			require.NoError(t, err)
+			assert.Empty(t, running)
+
+			id2, err := store.Create(ctx, &Job{PRNumber: 43, JobType: TypeSDG})
+			require.NoError(t, err)
+			require.NoError(t, store.Fail(ctx, id2, errors.New("boom")))
+			job, err = store.Get(ctx, id2)
+			require.NoError(t, err)
+			assert.Equal(t, StatusError, job.Status)
+			assert.False(t, job.NoChanges())
+			assert.Equal(t, "boom", job.Errors)
+			assert.False(t, job.Active())
+
+			id3, err := store.Create(ctx, &Job{PRNumber: 43, JobType: TypePrecheck})
+			require.NoError(t, err)
+			require.NoError(t, store.Cancel(ctx, id3, "superseded"))
+			job, err = store.Get(ctx, id3)
+			require.NoError(t, err)
+			assert.True(t, job.Active())
+			assert.True(t, job.Cancelled())
+			assert.Equal(t, "superseded", job.CancelReason)
+
+			// Only the first of two racing transitions of a pending job wins
+			changed, err = store.Transition(ctx, id3, StatusSuperseded, StatusPending)
+			require.NoError(t, err)
+			assert.True(t, changed)
+			changed, err = store.Transition(ctx, id3, StatusRunning, StatusPending, StatusRunning)
+			require.NoError(t, err)
+			assert.False(t, changed)
+			job, err = store.Get(ctx, id3)
+			require.NoError(t, err)
+			assert.Equal(t, StatusSuperseded, job.Status)
+			assert.False(t, job.Active())
+			assert.False(t, job.FinishTime.IsZero())
+			changed, err = store.Transition(ctx, "404", StatusRunning, StatusPending)
+			require.NoError(t, err)
+			assert.False(t, changed)
+
+			list, err := store.List(ctx)
+			require.NoError(t, err)
+			require.Len(t, list, 3)
+			assert.Equal(t, id, list[0].ID)
+			assert.Equal(t, id2, list[1].ID)
+			assert.Equal(t, id3, list[2].ID)
+
+			prJobs, err := store.ListPR(ctx, "instructlab", "taxonomy", 42)
+			require.NoError(t, err)
+			require.Len(t, prJobs, 1)
+			assert.Equal(t, id, prJobs[0].ID)
+			prJobs, err = store.ListPR(ctx, "", "", 43)
+			require.NoError(t, err)
+			require.Len(t, prJobs, 2)
+			assert.Equal(t, id2, prJobs[0].ID)
+			assert.Equal(t, id3, prJobs[1].ID)
+
+			_, err = store.Get(ctx, "404")
+			assert.ErrorIs(t, err, ErrNotFound)
+
+			// An expired job is removed from the store
+			require.NoError(t, store.Expire(ctx, id, time.Hour))
+			_, err = store.Get(ctx, id)
+			require.NoError(t, err)
+			mr.FastForward(time.Hour)
+			_, err = store.Get(ctx, id)
+			assert.ErrorIs(t, err, ErrNotFound)
+
+			// and dropped from the indexes once they are read
+			prJobs, err = store.ListPR(ctx, "instructlab", "taxonomy", 42)
+			require.NoError(t, err)
+			assert.Empty(t, prJobs)
+			assert.False(t, mr.Exists(PRKey("instructlab", "taxonomy", 42)))
+		})
+	}
+}
+
+// TestJobEvents verifies status transitions written by the worker's store reach bot subscribers.
+func TestJobEvents(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	mr := miniredis.RunT(t)
+	client := goredis.NewClient(&goredis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { client.Close() })
+	pool := &redis.Pool{
+		Dial: func() (redis.Conn, error) {
+			return redis.Dial("tcp", mr.Addr())
+		},
+	}
+	t.Cleanup(func() { pool.Close() })
+
+	events, err := NewRedisStore(client).Subscribe(ctx)
+	require.NoError(t, err)
+
+	worker := NewRedigoStore(pool)
+	id, err := worker.Create(ctx, &Job{PRNumber: 7, JobType: TypeGenerateLocal})
+	require.NoError(t, err)
+	require.NoError(t, worker.UpdateStatus(ctx, id, StatusRunning))
+	require.NoError(t, NewRedisStore(client).Cancel(ctx, id, "cancelled by @alice"))
+	require.NoError(t, worker.Cancel(ctx, id, "cancelled by @bob"))
+	require.NoError(t, worker.UpdateStatus(ctx, id, StatusCancelled))
+
+	for _, want := range []Event{
+		{JobID: id, Status: StatusPending},
+		{JobID: id, Status: StatusRunning},
+		{JobID: id, Status: StatusRunning, CancelReason: "cancelled by @alice"},
+		{JobID: id, Status: StatusRunning, CancelReason: "cancelled by @bob"},
+		{JobID: id, Status: StatusCancelled},
+	} {
+		select {
+		case event := <-events:
+			assert.Equal(t, want, event)
+		case <-time.After(time.Second):
+			t.Fatalf("timed out waiting for %+v", want)
+		}
+	}
+}
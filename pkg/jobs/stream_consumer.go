@@ -0,0 +1,334 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+var _ Consumer = (*StreamConsumer)(nil)
+
+// StreamConsumer is the worker side of the streams backend. The worker ID is
+// used as its consumer name in GroupWorkers.
+type StreamConsumer struct {
+	pool     *redis.Pool
+	workerID string
+	jobTypes []string
+	slots    int
+	// streams are polled in order, so jobs of the first types are taken
+	// first.
+	streams []string
+	// next is the stream to block on when all of them are empty, rotated so
+	// every job type is waited on in turn.
+	next atomic.Uint32
+
+	mu sync.Mutex
+	// inflight holds the stream entries delivered to this worker and not
+	// acknowledged yet, by entry ID.
+	inflight map[string]streamEntry
+}
+
+// NewStreamConsumer creates the consumer groups if needed and returns the
+// queue consumer of the given worker, consuming jobs of the given types with
+// up to slots jobs at the same time.
+func NewStreamConsumer(ctx context.Context, pool *redis.Pool, workerID string, jobTypes []string, slots int) (*StreamConsumer, error) {
+	conn, err := pool.GetContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	groups := map[string]string{StreamResults: GroupBot}
+	streams := make([]string, 0, len(jobTypes))
+	for _, jobType := range jobTypes {
+		groups[GenerateStream(jobType)] = GroupWorkers
+		streams = append(streams, GenerateStream(jobType))
+	}
+	for stream, group := range groups {
+		_, err := redis.DoContext(conn, ctx, "XGROUP", "CREATE", stream, group, "0", "MKSTREAM")
+		if err != nil && !isBusyGroup(err) {
+			return nil, fmt.Errorf("could not create consumer group %s on %s: %w", group, stream, err)
+		}
+	}
+	return &StreamConsumer{
+		pool:     pool,
+		workerID: workerID,
+		jobTypes: jobTypes,
+		slots:    slots,
+		streams:  streams,
+		inflight: make(map[string]streamEntry),
+	}, nil
+}
+
+// Dequeue reads the next entry of the generate streams for this worker. The
+// entry stays pending in GroupWorkers until the delivery is acknowledged. An
+// entry already waiting on any stream is read right away, otherwise Dequeue
+// blocks on a single stream, as a blocking read of several streams could
+// deliver an entry of each.
+func (q *StreamConsumer) Dequeue(ctx context.Context, timeout time.Duration) (*Delivery, error) {
+	if len(q.streams) == 0 {
+		return nil, errors.New("no job types to consume")
+	}
+	conn, err := q.pool.GetContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	var entries []streamEntry
+	for _, stream := range q.streams {
+		entries, err = q.read(ctx, conn, stream, -1)
+		if err != nil || len(entries) > 0 {
+			break
+		}
+	}
+	if err == nil && len(entries) == 0 {
+		entries, err = q.read(ctx, conn, q.streams[int(q.next.Add(1))%len(q.streams)], timeout)
+	}
+	if err != nil || len(entries) == 0 {
+		return nil, err
+	}
+
+	entry := entries[0]
+	q.mu.Lock()
+	q.inflight[entry.id] = entry
+	q.mu.Unlock()
+
+	delivery := &Delivery{
+		JobID: entry.jobID,
+		ack: func(ctx context.Context) error {
+			return q.ack(ctx, entry)
+		},
+		requeue: func(ctx context.Context) error {
+			return q.requeue(ctx, entry)
+		},
+	}
+	return delivery, claim(ctx, conn, entry.jobID, q.workerID)
+}
+
+// read reads a single new entry of stream, blocking up to block if it is
+// not negative.
+func (q *StreamConsumer) read(ctx context.Context, conn redis.Conn, stream string, block time.Duration) ([]streamEntry, error) {
+	args := redis.Args{"GROUP", GroupWorkers, q.workerID, "COUNT", 1}
+	if block >= 0 {
+		args = args.Add("BLOCK", block.Milliseconds())
+	}
+	reply, err := redis.Values(redis.DoContext(conn, ctx, "XREADGROUP", args.Add("STREAMS", stream, ">")...))
+	if errors.Is(err, redis.ErrNil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return readGroupEntries(reply)
+}
+
+func (q *StreamConsumer) ack(ctx context.Context, entry streamEntry) error {
+	conn, err := q.pool.GetContext(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if _, err := redis.DoContext(conn, ctx, "XACK", entry.stream, GroupWorkers, entry.id); err != nil {
+		return err
+	}
+	q.mu.Lock()
+	delete(q.inflight, entry.id)
+	q.mu.Unlock()
+	return nil
+}
+
+// requeue appends a job again and acknowledges the entry it was read from.
+// The entry of a job that finished meanwhile is only acknowledged.
+func (q *StreamConsumer) requeue(ctx context.Context, entry streamEntry) error {
+	requeued, err := NewRedigoStore(q.pool).Transition(ctx, entry.jobID, StatusPending, StatusPending, StatusRunning)
+	if err != nil {
+		return err
+	}
+
+	conn, err := q.pool.GetContext(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	_ = conn.Send("MULTI")
+	if requeued {
+		_ = conn.Send("XADD", entry.stream, "MAXLEN", "~", streamMaxLen, "*", streamFieldJob, entry.jobID)
+		_ = conn.Send("DECR", Key(entry.jobID, FieldAttempts))
+	}
+	_ = conn.Send("XACK", entry.stream, GroupWorkers, entry.id)
+	if _, err := redis.DoContext(conn, ctx, "EXEC"); err != nil {
+		return err
+	}
+	q.mu.Lock()
+	delete(q.inflight, entry.id)
+	q.mu.Unlock()
+	return nil
+}
+
+// PostResult appends a finished job to StreamResults.
+func (q *StreamConsumer) PostResult(ctx context.Context, id string) error {
+	conn, err := q.pool.GetContext(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	_, err = redis.DoContext(conn, ctx, "XADD", StreamResults, "MAXLEN", "~", streamMaxLen, "*", streamFieldJob, id)
+	return err
+}
+
+// Heartbeat marks the worker alive for the next ttl and claims its entries
+// again, which resets their idle time so the bot does not reclaim them.
+func (q *StreamConsumer) Heartbeat(ctx context.Context, ttl time.Duration) error {
+	conn, err := q.pool.GetContext(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if err := heartbeat(ctx, conn, q.workerID, q.jobTypes, q.slots, ttl); err != nil {
+		return err
+	}
+
+	q.mu.Lock()
+	claims := map[string]redis.Args{}
+	for _, entry := range q.inflight {
+		claims[entry.stream] = claims[entry.stream].Add(entry.id)
+	}
+	q.mu.Unlock()
+	for stream, ids := range claims {
+		args := redis.Args{stream, GroupWorkers, q.workerID, 0}.Add(ids...).Add("JUSTID")
+		if _, err := redis.DoContext(conn, ctx, "XCLAIM", args...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Pending returns the entries of the consumer's generate streams pending in
+// GroupWorkers.
+func (q *StreamConsumer) Pending(ctx context.Context) ([]Pending, error) {
+	conn, err := q.pool.GetContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	var pending []Pending
+	for _, stream := range q.streams {
+		streamPending, err := q.streamPending(ctx, conn, stream)
+		if err != nil {
+			return nil, err
+		}
+		pending = append(pending, streamPending...)
+	}
+	return pending, nil
+}
+
+// streamPending returns the entries of a stream pending in GroupWorkers.
+func (q *StreamConsumer) streamPending(ctx context.Context, conn redis.Conn, stream string) ([]Pending, error) {
+	var pending []Pending
+	start := "-"
+	for {
+		reply, err := redis.Values(redis.DoContext(conn, ctx, "XPENDING", stream, GroupWorkers, start, "+", claimBatchSize))
+		if errors.Is(err, redis.ErrNil) {
+			return pending, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		for _, item := range reply {
+			var entryID, consumer string
+			var idle, deliveries int64
+			fields, err := redis.Values(item, nil)
+			if err != nil {
+				return nil, err
+			}
+			if _, err := redis.Scan(fields, &entryID, &consumer, &idle, &deliveries); err != nil {
+				return nil, err
+			}
+
+			entries, err := redis.Values(redis.DoContext(conn, ctx, "XRANGE", stream, entryID, entryID))
+			if err != nil {
+				return nil, err
+			}
+			p := Pending{WorkerID: consumer, Idle: time.Duration(idle) * time.Millisecond}
+			if parsed, err := streamEntries(stream, entries); err == nil && len(parsed) > 0 {
+				p.JobID = parsed[0].jobID
+				p.Attempts, err = redis.Int(redis.DoContext(conn, ctx, "GET", Key(p.JobID, FieldAttempts)))
+				if err != nil && !errors.Is(err, redis.ErrNil) {
+					return nil, err
+				}
+			}
+			pending = append(pending, p)
+			start = "(" + entryID
+		}
+		if len(reply) < claimBatchSize {
+			return pending, nil
+		}
+	}
+}
+
+// streamEntry is a stream entry carrying a job ID.
+type streamEntry struct {
+	stream string
+	id     string
+	jobID  string
+}
+
+// readGroupEntries parses the entries of the single stream of an XREADGROUP
+// reply.
+func readGroupEntries(reply []interface{}) ([]streamEntry, error) {
+	if len(reply) == 0 {
+		return nil, nil
+	}
+	stream, err := redis.Values(reply[0], nil)
+	if err != nil {
+		return nil, err
+	}
+	if len(stream) != 2 {
+		return nil, fmt.Errorf("unexpected XREADGROUP reply of length %d", len(stream))
+	}
+	name, err := redis.String(stream[0], nil)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := redis.Values(stream[1], nil)
+	if err != nil {
+		return nil, err
+	}
+	return streamEntries(name, entries)
+}
+
+// streamEntries parses a list of [id, [field, value, ...]] entries of a
+// stream.
+func streamEntries(stream string, entries []interface{}) ([]streamEntry, error) {
+	parsed := make([]streamEntry, 0, len(entries))
+	for _, raw := range entries {
+		entry, err := redis.Values(raw, nil)
+		if err != nil {
+			return nil, err
+		}
+		if len(entry) != 2 {
+			return nil, fmt.Errorf("unexpected stream entry of length %d", len(entry))
+		}
+		id, err := redis.String(entry[0], nil)
+		if err != nil {
+			return nil, err
+		}
+		values, err := redis.StringMap(entry[1], nil)
+		if err != nil {
+			return nil, err
+		}
+		parsed = append(parsed, streamEntry{stream: stream, id: id, jobID: values[streamFieldJob]})
+	}
+	return parsed, nil
+}
@@ -0,0 +1,287 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// Redis streams and consumer groups of the streams backend. Jobs wait on the
+// stream of their type, named by GenerateStream.
+const (
+	StreamGenerate = "stream:generate"
+	StreamResults  = "stream:results"
+	GroupWorkers   = "workers"
+	GroupBot       = "bot"
+)
+
+const (
+	// streamFieldJob is the entry field holding the job ID.
+	streamFieldJob = "job"
+	// streamMaxLen caps the length of the streams, acknowledged entries are
+	// only kept as a short history.
+	streamMaxLen = 10000
+	// consumerBot and consumerReaper are the consumer names of the bot when
+	// reading results and reclaiming abandoned jobs.
+	consumerBot    = "bot"
+	consumerReaper = "reaper"
+	claimBatchSize = 100
+)
+
+var _ Dispatcher = (*StreamDispatcher)(nil)
+
+// StreamDispatcher is the bot side of the streams backend.
+type StreamDispatcher struct {
+	client *redis.Client
+	store  *RedisStore
+	opts   DispatcherOptions
+	// drained is set once the results left pending by a previous run of the
+	// bot have been delivered again.
+	drained bool
+}
+
+// NewStreamDispatcher creates the consumer groups if needed and returns the
+// bot side of the streams backend.
+func NewStreamDispatcher(ctx context.Context, client *redis.Client, opts DispatcherOptions) (*StreamDispatcher, error) {
+	groups := map[string]string{StreamResults: GroupBot}
+	for _, jobType := range Types {
+		groups[GenerateStream(jobType)] = GroupWorkers
+	}
+	for stream, group := range groups {
+		err := client.XGroupCreateMkStream(ctx, stream, group, "0").Err()
+		if err != nil && !isBusyGroup(err) {
+			return nil, fmt.Errorf("could not create consumer group %s on %s: %w", group, stream, err)
+		}
+	}
+	return &StreamDispatcher{client: client, store: NewRedisStore(client), opts: opts}, nil
+}
+
+// Enqueue appends a job to the GenerateStream of its type.
+func (d *StreamDispatcher) Enqueue(ctx context.Context, id, jobType string) error {
+	return d.client.XAdd(ctx, &redis.XAddArgs{
+		Stream: GenerateStream(jobType),
+		MaxLen: streamMaxLen,
+		Approx: true,
+		Values: map[string]interface{}{streamFieldJob: id},
+	}).Err()
+}
+
+// NextResult reads the next entry of StreamResults. Acknowledging the
+// delivery removes the entry from the pending list of GroupBot.
+func (d *StreamDispatcher) NextResult(ctx context.Context, timeout time.Duration) (*Delivery, error) {
+	if !d.drained {
+		// Start over with the results read but never acknowledged
+		delivery, err := d.readResult(ctx, "0", -1)
+		if err != nil || delivery != nil {
+			return delivery, err
+		}
+		d.drained = true
+	}
+	return d.readResult(ctx, ">", timeout)
+}
+
+// readResult reads a single entry of StreamResults from the given ID,
+// blocking up to block if it is not negative.
+func (d *StreamDispatcher) readResult(ctx context.Context, from string, block time.Duration) (*Delivery, error) {
+	streams, err := d.client.XReadGroup(ctx, &redis.XReadGroupArgs{
+		Group:    GroupBot,
+		Consumer: consumerBot,
+		Streams:  []string{StreamResults, from},
+		Count:    1,
+		Block:    block,
+	}).Result()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(streams) == 0 || len(streams[0].Messages) == 0 {
+		return nil, nil
+	}
+
+	msg := streams[0].Messages[0]
+	id, _ := msg.Values[streamFieldJob].(string)
+	return &Delivery{JobID: id, ack: func(ctx context.Context) error {
+		return d.client.XAck(ctx, StreamResults, GroupBot, msg.ID).Err()
+	}}, nil
+}
+
+// Waiting returns the number of entries of the GenerateStream of a type not
+// delivered to GroupWorkers yet, counting up to claimBatchSize. The XINFO
+// GROUPS reply is parsed by hand as its format changed with Redis 7.
+func (d *StreamDispatcher) Waiting(ctx context.Context, jobType string) (int, error) {
+	stream := GenerateStream(jobType)
+	groups, err := d.client.Do(ctx, "XINFO", "GROUPS", stream).Slice()
+	if err != nil {
+		return 0, err
+	}
+	lastDelivered := "0-0"
+	for _, raw := range groups {
+		info, _ := raw.([]interface{})
+		values := map[string]interface{}{}
+		for i := 0; i+1 < len(info); i += 2 {
+			if field, ok := info[i].(string); ok {
+				values[field] = info[i+1]
+			}
+		}
+		if values["name"] == GroupWorkers {
+			lastDelivered, _ = values["last-delivered-id"].(string)
+		}
+	}
+	msgs, err := d.client.XRangeN(ctx, stream, "("+lastDelivered, "+", claimBatchSize).Result()
+	return len(msgs), err
+}
+
+// Recover claims the entries of the generate streams idle for longer than
+// the visibility timeout and appends them again, or dead-letters them once
+// they used up their attempts.
+func (d *StreamDispatcher) Recover(ctx context.Context) ([]Reaped, error) {
+	var reaped []Reaped
+	for _, jobType := range Types {
+		streamReaped, err := d.recoverStream(ctx, GenerateStream(jobType))
+		reaped = append(reaped, streamReaped...)
+		if err != nil {
+			return reaped, err
+		}
+	}
+	return reaped, nil
+}
+
+// recoverStream recovers the idle entries of a single generate stream.
+func (d *StreamDispatcher) recoverStream(ctx context.Context, stream string) ([]Reaped, error) {
+	var reaped []Reaped
+	start := "0-0"
+	for {
+		msgs, next, err := d.autoClaim(ctx, stream, start)
+		if err != nil {
+			return reaped, err
+		}
+
+		for _, msg := range msgs {
+			id, _ := msg.Values[streamFieldJob].(string)
+			job, err := d.store.Get(ctx, id)
+			if err == ErrNotFound {
+				// Nothing left to run, drop the entry
+				if err := d.client.XAck(ctx, stream, GroupWorkers, msg.ID).Err(); err != nil {
+					return reaped, err
+				}
+				continue
+			}
+			if err != nil {
+				return reaped, err
+			}
+
+			item := Reaped{JobID: id, WorkerID: job.Worker, Attempts: job.Attempts}
+			switch {
+			case !job.Active():
+				item.Finished = true
+				err = d.client.XAck(ctx, stream, GroupWorkers, msg.ID).Err()
+			case job.Attempts >= d.opts.MaxAttempts:
+				item.DeadLettered = true
+				err = d.deadLetter(ctx, stream, msg.ID, item)
+			default:
+				var requeued bool
+				requeued, err = d.requeue(ctx, stream, msg.ID, id)
+				item.Finished = !requeued
+			}
+			if err != nil {
+				return reaped, err
+			}
+			reaped = append(reaped, item)
+		}
+
+		start = next
+		if start == "0-0" || start == "" {
+			return reaped, nil
+		}
+	}
+}
+
+// autoClaim claims a batch of idle entries of a generate stream and returns
+// them with the ID to continue from. The reply is parsed by hand as its
+// format changed with Redis 7.
+func (d *StreamDispatcher) autoClaim(ctx context.Context, stream, start string) ([]redis.XMessage, string, error) {
+	reply, err := d.client.Do(ctx, "XAUTOCLAIM", stream, GroupWorkers, consumerReaper,
+		d.opts.VisibilityTimeout.Milliseconds(), start, "COUNT", claimBatchSize).Slice()
+	if err != nil {
+		return nil, "", err
+	}
+	if len(reply) < 2 {
+		return nil, "", fmt.Errorf("unexpected XAUTOCLAIM reply of length %d", len(reply))
+	}
+	next, _ := reply[0].(string)
+	entries, _ := reply[1].([]interface{})
+
+	msgs := make([]redis.XMessage, 0, len(entries))
+	for _, raw := range entries {
+		entry, ok := raw.([]interface{})
+		if !ok || len(entry) != 2 {
+			continue
+		}
+		msg := redis.XMessage{Values: map[string]interface{}{}}
+		msg.ID, _ = entry[0].(string)
+		values, _ := entry[1].([]interface{})
+		for i := 0; i+1 < len(values); i += 2 {
+			if field, ok := values[i].(string); ok {
+				msg.Values[field] = values[i+1]
+			}
+		}
+		msgs = append(msgs, msg)
+	}
+	return msgs, next, nil
+}
+
+// requeue appends a job again and acknowledges its abandoned entry, and
+// reports whether it did. The entry of a job that finished meanwhile is only
+// acknowledged.
+func (d *StreamDispatcher) requeue(ctx context.Context, stream, entryID, id string) (bool, error) {
+	requeued, err := d.store.Transition(ctx, id, StatusPending, StatusPending, StatusRunning)
+	if err != nil {
+		return false, err
+	}
+	if !requeued {
+		return false, d.client.XAck(ctx, stream, GroupWorkers, entryID).Err()
+	}
+	_, err = d.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.XAdd(ctx, &redis.XAddArgs{
+			Stream: stream,
+			MaxLen: streamMaxLen,
+			Approx: true,
+			Values: map[string]interface{}{streamFieldJob: id},
+		})
+		pipe.XAck(ctx, stream, GroupWorkers, entryID)
+		return nil
+	})
+	return true, err
+}
+
+// deadLetter fails a job, parks it in QueueDeadLetter and reports it on
+// StreamResults.
+func (d *StreamDispatcher) deadLetter(ctx context.Context, stream, entryID string, item Reaped) error {
+	jobErr := fmt.Errorf("job was abandoned by worker %s and exceeded the maximum of %d attempts", item.WorkerID, d.opts.MaxAttempts)
+	if err := d.store.Fail(ctx, item.JobID, jobErr); err != nil {
+		return err
+	}
+	_, err := d.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.LPush(ctx, QueueDeadLetter, item.JobID)
+		pipe.XAdd(ctx, &redis.XAddArgs{
+			Stream: StreamResults,
+			MaxLen: streamMaxLen,
+			Approx: true,
+			Values: map[string]interface{}{streamFieldJob: item.JobID},
+		})
+		pipe.XAck(ctx, stream, GroupWorkers, entryID)
+		return nil
+	})
+	return err
+}
+
+// isBusyGroup reports whether a consumer group creation failed because the
+// group already exists.
+func isBusyGroup(err error) bool {
+	return strings.HasPrefix(err.Error(), "BUSYGROUP")
+}
@@ -0,0 +1,58 @@
+package jobs
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Types lists every job type, in the order a worker polls their queues: a
+// worker running several types takes precheck jobs ahead of generation jobs.
+var Types = []string{TypePrecheck, TypeSDG, TypeGenerateLocal}
+
+// KnownType reports whether jobType is one of Types.
+func KnownType(jobType string) bool {
+	for _, t := range Types {
+		if t == jobType {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseTypes validates a list of job types, such as the one given to a
+// worker's --job-types flag, and returns them in the order of Types. An empty
+// list stands for every type.
+func ParseTypes(names []string) ([]string, error) {
+	seen := map[string]bool{}
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		if !KnownType(name) {
+			return nil, fmt.Errorf("unknown job type %q, expected one of %s", name, strings.Join(Types, ", "))
+		}
+		seen[name] = true
+	}
+	if len(seen) == 0 {
+		return Types, nil
+	}
+	var parsed []string
+	for _, t := range Types {
+		if seen[t] {
+			parsed = append(parsed, t)
+		}
+	}
+	return parsed, nil
+}
+
+// GenerateQueue returns the list the lists backend queues jobs of a type on.
+func GenerateQueue(jobType string) string {
+	return QueueGenerate + ":" + jobType
+}
+
+// GenerateStream returns the stream the streams backend queues jobs of a type
+// on.
+func GenerateStream(jobType string) string {
+	return StreamGenerate + ":" + jobType
+}
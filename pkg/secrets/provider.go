@@ -0,0 +1,170 @@
+// Package secrets reads the secrets of the bot and the worker from a secret
+// provider, and redacts them from the logs, Redis keys and artifacts the bot
+// and the worker write.
+package secrets
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Kinds of secret providers.
+const (
+	ProviderFile  = "file"
+	ProviderEnv   = "env"
+	ProviderVault = "vault"
+)
+
+// VaultTokenEnv is the environment variable holding the token of the vault
+// provider.
+const VaultTokenEnv = "VAULT_TOKEN"
+
+// ErrNotFound is returned by a Provider that does not hold a secret.
+var ErrNotFound = errors.New("secret not found")
+
+// Provider reads secrets by name. The bot and the worker name their secrets
+// after the flags holding them, such as github-token.
+type Provider interface {
+	// Secret returns the value of the named secret, or ErrNotFound.
+	Secret(ctx context.Context, name string) (string, error)
+}
+
+// Open returns the provider described by spec, "<kind>:<location>":
+//
+//   - file:<dir> reads the secret <name> from the file <dir>/<name>, as
+//     mounted by Kubernetes or Docker secrets.
+//   - env:<prefix> reads the secret <name> from the environment variable
+//     <PREFIX>_<NAME>, dashes turned into underscores.
+//   - vault:<url> reads the secret <name> from the field <name> of the secret
+//     served at url by Vault or a compatible server, such as
+//     https://vault:8200/v1/secret/data/instructlab-bot. The token is read
+//     from VAULT_TOKEN.
+//
+// An empty spec returns a nil provider.
+func Open(spec string) (Provider, error) {
+	if spec == "" {
+		return nil, nil
+	}
+	kind, location, ok := strings.Cut(spec, ":")
+	if !ok || location == "" {
+		return nil, fmt.Errorf("invalid secret provider %q, expected <kind>:<location>", spec)
+	}
+	switch kind {
+	case ProviderFile:
+		return FileProvider{Dir: location}, nil
+	case ProviderEnv:
+		return EnvProvider{Prefix: location}, nil
+	case ProviderVault:
+		return &HTTPProvider{URL: location, Token: os.Getenv(VaultTokenEnv)}, nil
+	default:
+		return nil, fmt.Errorf("unknown secret provider %q, expected one of %s, %s or %s", kind, ProviderFile, ProviderEnv, ProviderVault)
+	}
+}
+
+// ReadFile reads a secret from a file, without the line break ending it.
+func ReadFile(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read secret: %w", err)
+	}
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
+
+// FileProvider reads each secret from a file of a directory.
+type FileProvider struct {
+	Dir string
+}
+
+// Secret reads the file of the named secret.
+func (p FileProvider) Secret(_ context.Context, name string) (string, error) {
+	path := filepath.Join(p.Dir, name)
+	if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+		return "", ErrNotFound
+	}
+	return ReadFile(path)
+}
+
+// EnvProvider reads each secret from an environment variable.
+type EnvProvider struct {
+	Prefix string
+}
+
+// Secret reads the variable of the named secret.
+func (p EnvProvider) Secret(_ context.Context, name string) (string, error) {
+	value, ok := os.LookupEnv(strings.ToUpper(p.Prefix + "_" + strings.ReplaceAll(name, "-", "_")))
+	if !ok {
+		return "", ErrNotFound
+	}
+	return value, nil
+}
+
+// HTTPProvider reads the secrets from the fields of a secret served over HTTP
+// by Vault or a compatible server. Both the KV version 2 responses, with the
+// fields under data.data, and the version 1 responses, with the fields under
+// data, are read.
+type HTTPProvider struct {
+	URL   string
+	Token string
+	// Client sends the requests, http.DefaultClient with a timeout if nil.
+	Client *http.Client
+}
+
+// Secret reads the field of the named secret.
+func (p *HTTPProvider) Secret(ctx context.Context, name string) (string, error) {
+	fields, err := p.fields(ctx)
+	if err != nil {
+		return "", err
+	}
+	value, ok := fields[name]
+	if !ok {
+		return "", ErrNotFound
+	}
+	s, ok := value.(string)
+	if !ok {
+		return "", fmt.Errorf("secret %s is not a string", name)
+	}
+	return s, nil
+}
+
+func (p *HTTPProvider) fields(ctx context.Context) (map[string]any, error) {
+	client := p.Client
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+	if p.Token != "" {
+		req.Header.Set("X-Vault-Token", p.Token)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read secrets: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to read secrets from %s: %s", p.URL, resp.Status)
+	}
+
+	var body struct {
+		Data map[string]any `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("failed to parse secrets from %s: %w", p.URL, err)
+	}
+	if fields, ok := body.Data["data"].(map[string]any); ok {
+		return fields, nil
+	}
+	return body.Data, nil
+}
@@ -0,0 +1,122 @@
+package secrets
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// Redacted replaces the secrets in redacted text.
+const Redacted = "REDACTED"
+
+// Redactor replaces the secrets added to it with Redacted. A nil Redactor
+// redacts nothing.
+type Redactor struct {
+	mu       sync.RWMutex
+	secrets  []string
+	replacer *strings.Replacer
+}
+
+// NewRedactor returns a Redactor of the given secrets.
+func NewRedactor(secrets ...string) *Redactor {
+	r := &Redactor{}
+	r.Add(secrets...)
+	return r
+}
+
+// Add adds secrets to redact. Empty secrets are ignored. Each line of a
+// secret spanning several lines, such as a private key, is also redacted on
+// its own, for the writers that cut their output in lines.
+func (r *Redactor) Add(secrets ...string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, secret := range secrets {
+		values := []string{secret}
+		if strings.Contains(secret, "\n") {
+			values = append(values, strings.Split(secret, "\n")...)
+		}
+		for _, value := range values {
+			value = strings.TrimSpace(value)
+			if value != "" && !slices.Contains(r.secrets, value) {
+				r.secrets = append(r.secrets, value)
+			}
+		}
+	}
+	// The longest secrets go first, so a secret holding another is replaced
+	// as a whole
+	sort.Slice(r.secrets, func(i, j int) bool { return len(r.secrets[i]) > len(r.secrets[j]) })
+	pairs := make([]string, 0, 2*len(r.secrets))
+	for _, secret := range r.secrets {
+		pairs = append(pairs, secret, Redacted)
+	}
+	r.replacer = strings.NewReplacer(pairs...)
+}
+
+// Redact returns s with the secrets replaced.
+func (r *Redactor) Redact(s string) string {
+	if r == nil {
+		return s
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if r.replacer == nil {
+		return s
+	}
+	return r.replacer.Replace(s)
+}
+
+// RedactBytes returns b with the secrets replaced.
+func (r *Redactor) RedactBytes(b []byte) []byte {
+	return []byte(r.Redact(string(b)))
+}
+
+// redactCore redacts the messages and the string fields of the entries it
+// logs.
+type redactCore struct {
+	zapcore.Core
+	redactor *Redactor
+}
+
+// RedactCore returns a core redacting the secrets of r from what it logs, to
+// wrap the core of a logger with zap.WrapCore.
+func RedactCore(core zapcore.Core, r *Redactor) zapcore.Core {
+	return &redactCore{Core: core, redactor: r}
+}
+
+func (c *redactCore) With(fields []zapcore.Field) zapcore.Core {
+	return &redactCore{Core: c.Core.With(c.redactFields(fields)), redactor: c.redactor}
+}
+
+func (c *redactCore) Check(entry zapcore.Entry, checked *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(entry.Level) {
+		return checked.AddCore(entry, c)
+	}
+	return checked
+}
+
+func (c *redactCore) Write(entry zapcore.Entry, fields []zapcore.Field) error {
+	entry.Message = c.redactor.Redact(entry.Message)
+	return c.Core.Write(entry, c.redactFields(fields))
+}
+
+func (c *redactCore) redactFields(fields []zapcore.Field) []zapcore.Field {
+	redacted := make([]zapcore.Field, len(fields))
+	for i, field := range fields {
+		switch field.Type {
+		case zapcore.StringType:
+			field.String = c.redactor.Redact(field.String)
+		case zapcore.ErrorType, zapcore.StringerType, zapcore.ReflectType:
+			// Log the text of the value, so it can be redacted
+			if field.Interface != nil {
+				field = zap.String(field.Key, c.redactor.Redact(fmt.Sprint(field.Interface)))
+			}
+		}
+		redacted[i] = field
+	}
+	return redacted
+}
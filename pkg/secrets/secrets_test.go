@@ -0,0 +1,124 @@
+package secrets
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestOpen(t *testing.T) {
+	provider, err := Open("")
+	require.NoError(t, err)
+	assert.Nil(t, provider)
+
+	for _, spec := range []string{"vault", "file:", "aws:secret"} {
+		_, err := Open(spec)
+		assert.Error(t, err, spec)
+	}
+}
+
+func TestFileProvider(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "github-token"), []byte("ghp_file\n"), 0o600))
+	provider, err := Open("file:" + dir)
+	require.NoError(t, err)
+
+	value, err := provider.Secret(context.Background(), "github-token")
+	require.NoError(t, err)
+	assert.Equal(t, "ghp_file", value)
+	_, err = provider.Secret(context.Background(), "precheck-api-key")
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestEnvProvider(t *testing.T) {
+	t.Setenv("SECRETS_PRECHECK_API_KEY", "key_env")
+	provider, err := Open("env:secrets")
+	require.NoError(t, err)
+
+	value, err := provider.Secret(context.Background(), "precheck-api-key")
+	require.NoError(t, err)
+	assert.Equal(t, "key_env", value)
+	_, err = provider.Secret(context.Background(), "github-token")
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestVaultProvider(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "s.token" {
+			http.Error(w, "permission denied", http.StatusForbidden)
+			return
+		}
+		switch r.URL.Path {
+		case "/v1/secret/data/bot":
+			_, _ = w.Write([]byte(`{"data": {"data": {"github-token": "ghp_vault"}, "metadata": {"version": 3}}}`))
+		case "/v1/kv/bot":
+			_, _ = w.Write([]byte(`{"data": {"github-token": "ghp_kv1"}}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+	t.Setenv(VaultTokenEnv, "s.token")
+
+	tests := []struct {
+		path string
+		want string
+		err  error
+	}{
+		{"/v1/secret/data/bot", "ghp_vault", nil},
+		{"/v1/kv/bot", "ghp_kv1", nil},
+		{"/v1/secret/data/missing", "", ErrNotFound},
+	}
+	for _, tt := range tests {
+		provider, err := Open("vault:" + server.URL + tt.path)
+		require.NoError(t, err)
+		value, err := provider.Secret(context.Background(), "github-token")
+		assert.ErrorIs(t, err, tt.err, tt.path)
+		assert.Equal(t, tt.want, value, tt.path)
+	}
+
+	t.Setenv(VaultTokenEnv, "s.wrong")
+	provider, err := Open("vault:" + server.URL + "/v1/secret/data/bot")
+	require.NoError(t, err)
+	_, err = provider.Secret(context.Background(), "github-token")
+	require.Error(t, err)
+	assert.False(t, errors.Is(err, ErrNotFound))
+}
+
+func TestRedactor(t *testing.T) {
+	var nilRedactor *Redactor
+	assert.Equal(t, "ghp_token", nilRedactor.Redact("ghp_token"))
+
+	r := NewRedactor("", "sk-1234", "sk-1234-5678")
+	r.Add("-----BEGIN KEY-----\nc2VjcmV0\n-----END KEY-----")
+	assert.Equal(t, "ilab chat --api-key REDACTED", r.Redact("ilab chat --api-key sk-1234-5678"))
+	assert.Equal(t, "--api-key REDACTED", r.Redact("--api-key sk-1234"))
+	// Each line of a multi-line secret is redacted on its own
+	assert.Equal(t, "line REDACTED", r.Redact("line c2VjcmV0"))
+	assert.Equal(t, []byte("REDACTED"), r.RedactBytes([]byte("sk-1234")))
+}
+
+func TestRedactCore(t *testing.T) {
+	core, logs := observer.New(zapcore.DebugLevel)
+	r := NewRedactor("ghp_secret")
+	logger := zap.New(core, zap.WrapCore(func(c zapcore.Core) zapcore.Core { return RedactCore(c, r) })).Sugar()
+
+	logger.With("token", "ghp_secret").Infof("Running git push with %s", "ghp_secret")
+	logger.Errorw("Push failed", "error", errors.New("bad credentials ghp_secret"))
+
+	entries := logs.AllUntimed()
+	require.Len(t, entries, 2)
+	assert.Equal(t, "Running git push with REDACTED", entries[0].Message)
+	assert.Equal(t, "REDACTED", entries[0].ContextMap()["token"])
+	assert.Equal(t, "bad credentials REDACTED", entries[1].ContextMap()["error"])
+}
@@ -0,0 +1,76 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/instructlab/instructlab-bot/pkg/jobs"
+	"github.com/spf13/cobra"
+)
+
+var RedactSecrets bool
+
+func init() {
+	configDumpCmd.Flags().BoolVarP(&RedactSecrets, "redact-secrets", "", true, "Replace the secrets set with REDACTED")
+	configCmd.AddCommand(configValidateCmd, configDumpCmd)
+	rootCmd.AddCommand(configCmd)
+}
+
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Check the settings of the worker, from its flags, environment and --config file",
+}
+
+var configValidateCmd = &cobra.Command{
+	Use:   "validate",
+	Short: "Check the settings and the files they name, without starting the worker",
+	Args:  cobra.NoArgs,
+	// A setting that does not check out is not a usage error
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if _, err := checkWorkerConfig(); err != nil {
+			return err
+		}
+		if _, err := readIlabConfig(IlabConfigFile); err != nil {
+			return err
+		}
+		fmt.Fprintln(cmd.OutOrStdout(), "\nConfiguration is valid")
+		return nil
+	},
+}
+
+var configDumpCmd = &cobra.Command{
+	Use:   "dump",
+	Short: "Print the effective settings as a configuration file, with the source of each value",
+	Args:  cobra.NoArgs,
+	// A setting that does not check out is not a usage error
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return settings.Dump(os.Stdout, RedactSecrets)
+	},
+}
+
+// checkWorkerConfig checks the settings of the worker and returns the job
+// types it accepts.
+func checkWorkerConfig() ([]string, error) {
+	if GithubToken == "" {
+		return nil, errors.New("no GitHub token set with --github-token, --github-token-file or --secret-provider")
+	}
+	if Concurrency < 1 {
+		return nil, fmt.Errorf("invalid concurrency %d, it must be at least 1", Concurrency)
+	}
+	if err := jobs.ValidateBackend(QueueBackend); err != nil {
+		return nil, err
+	}
+	jobTypes, err := jobs.ParseTypes(JobTypes)
+	if err != nil {
+		return nil, fmt.Errorf("invalid job types: %w", err)
+	}
+	for _, jobType := range jobTypes {
+		if _, ok := runners[jobType]; !ok {
+			return nil, fmt.Errorf("this worker has no runner for %s jobs", jobType)
+		}
+	}
+	return jobTypes, nil
+}
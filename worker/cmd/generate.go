@@ -3,9 +3,12 @@ package cmd
 import (
 	"bytes"
 	"context"
+	"crypto/rand"
 	"crypto/tls"
 	"crypto/x509"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
@@ -17,6 +20,7 @@ import (
 	"path"
 	"path/filepath"
 	"regexp"
+	"strconv"
 	"strings"
 	"sync"
 	"syscall"
@@ -26,6 +30,8 @@ import (
 	awsconfig "github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/gomodule/redigo/redis"
+	"github.com/instructlab/instructlab-bot/pkg/config"
+	"github.com/instructlab/instructlab-bot/pkg/jobs"
 	"github.com/spf13/cobra"
 	"go.uber.org/zap"
 	"gopkg.in/yaml.v2"
@@ -39,6 +45,7 @@ var (
 	SdgEndpointURL      string
 	NumInstructions     int
 	GitRemote           string
+	GithubWebURL        string
 	Origin              string
 	GithubUsername      string
 	GithubToken         string
@@ -50,33 +57,42 @@ var (
 	PrecheckAPIKey      string
 	TlsInsecure         bool
 	MaxSeed             int
+	WorkerID            string
+	VisibilityTimeout   time.Duration
+	Concurrency         int
+	JobTypes            []string
+	ShutdownTimeout     time.Duration
 	TaxonomyFolders     = []string{"compositional_skills", "knowledge"}
 )
 
 const (
 	gitMaxRetries            = 5
 	gitRetryDelay            = 2 * time.Second
+	jobPollTimeout           = 1 * time.Second
+	cancelPollInterval       = 5 * time.Second
 	localEndpoint            = "http://localhost:8000/v1"
-	jobSDG                   = "sdg-svc"
-	jobGenerateLocal         = "generate"
-	jobPreCheck              = "precheck"
 	sdgModel                 = "mistralai/mixtral-8x7b-instruct-v0-1"
 	jsonViewerFilenameSuffix = "-viewer.html"
 	ctxPrompt                = "Answer this based on the following context:"
 )
 
-const (
-	jobStatusSuccess = "success"
-	jobStatusError   = "error"
-	jobStatusRunning = "running"
-	jobStatusPending = "pending"
-)
+// errJobCancelled is the cause of a job context cancelled on request. The job
+// context bounds the commands of a job, so cancelling it kills them.
+var errJobCancelled = errors.New("job cancelled")
 
 // Worker encapsulates dependencies and methods to process jobs
 type Worker struct {
 	ctx                 context.Context
+	jobCtx              context.Context
 	ilabConfig          *IlabConfig
+	workspace           *workspace
 	pool                *redis.Pool
+	store               jobs.JobStore
+	jobLog              *jobs.LogWriter
+	args                jobs.Args
+	gitRemote           string
+	baseBranch          string
+	consumer            jobs.Consumer
 	svc                 *s3.Client
 	logger              *zap.SugaredLogger
 	job                 string
@@ -91,11 +107,14 @@ type Worker struct {
 	cmdRun              string
 }
 
-func NewJobProcessor(ctx context.Context, ilabConfig *IlabConfig, pool *redis.Pool, svc *s3.Client, logger *zap.SugaredLogger, job, precheckEndpoint, precheckAPIKey, sdgEndpoint, tlsClientCertPath, tlsClientKeyPath, tlsServerCaCertPath string, maxSeed int) *Worker {
+func NewJobProcessor(ctx context.Context, ilabConfig *IlabConfig, pool *redis.Pool, consumer jobs.Consumer, svc *s3.Client, logger *zap.SugaredLogger, job, precheckEndpoint, precheckAPIKey, sdgEndpoint, tlsClientCertPath, tlsClientKeyPath, tlsServerCaCertPath string, maxSeed int) *Worker {
 	return &Worker{
 		ctx:                 ctx,
+		jobCtx:              ctx,
 		ilabConfig:          ilabConfig,
 		pool:                pool,
+		store:               jobs.NewRedigoStore(pool),
+		consumer:            consumer,
 		svc:                 svc,
 		logger:              logger,
 		job:                 job,
@@ -165,11 +184,12 @@ func init() {
 	generateCmd.Flags().StringVarP(&WorkDir, "work-dir", "w", "", "Directory to work in")
 	generateCmd.Flags().StringVarP(&VenvDir, "venv-dir", "v", "", "The virtual environment directory")
 	generateCmd.Flags().StringVarP(&IlabConfigFile, "ilab-config-file", "", "config.yaml", "InstructLab config file absolute path - <path>/config.yaml")
-	generateCmd.Flags().StringVarP(&PreCheckEndpointURL, "precheck-endpoint-url", "e", "", "Endpoint hosting the model API. Default, it assumes the model is served locally.")
+	generateCmd.Flags().StringVarP(&PreCheckEndpointURL, "precheck-endpoint-url", "e", localEndpoint, "Endpoint hosting the model API. Default, it assumes the model is served locally. Also read from PECHECK_ENDPOINT")
 	generateCmd.Flags().StringVarP(&PrecheckAPIKey, "precheck-api-key", "", "", "The APIKey for the precheck-endpoint-url.")
 	generateCmd.Flags().StringVarP(&SdgEndpointURL, "sdg-endpoint-url", "", "http://localhost:8000/v1", "Endpoint hosting the model API. Default, it assumes the model is served locally.")
 	generateCmd.Flags().IntVarP(&NumInstructions, "num-instructions", "n", 10, "The number of instructions to generate")
-	generateCmd.Flags().StringVarP(&GitRemote, "git-remote", "", "https://github.com/instructlab/taxonomy", "The git remote for the taxonomy repo")
+	generateCmd.Flags().StringVarP(&GitRemote, "git-remote", "", "", "The git remote for the taxonomy repo, used by the jobs that do not carry one. If blank, instructlab/taxonomy on --github-web-url")
+	generateCmd.Flags().StringVarP(&GithubWebURL, "github-web-url", "", "https://github.com", "The web URL of the GitHub instance, such as https://github.example.com for GitHub Enterprise Server")
 	generateCmd.Flags().StringVarP(&Origin, "origin", "o", "origin", "The origin to fetch from")
 	generateCmd.Flags().StringVarP(&GithubUsername, "github-username", "u", "instructlab-bot", "The GitHub username to use for authentication")
 	generateCmd.Flags().StringVarP(&GithubToken, "github-token", "g", "", "The GitHub token to use for authentication")
@@ -180,22 +200,16 @@ func init() {
 	generateCmd.Flags().StringVarP(&TlsServerCaCertPath, "tls-server-ca-cert", "", "server-ca-crt.pem2", "Path to the TLS server CA certificate. Defaults to 'server-ca-crt.pem2'")
 	generateCmd.Flags().BoolVarP(&TlsInsecure, "tls-insecure", "", false, "Whether to skip TLS verification")
 	generateCmd.Flags().IntVarP(&MaxSeed, "max-seed", "m", 40, "Maximum number of seed Q&A pairs to process to SDG.")
-	if GithubToken == "" {
-		GithubToken = os.Getenv("ILWORKER_GITHUB_TOKEN")
-	}
-	if GithubUsername == "" {
-		GithubUsername = os.Getenv("ILWORKER_GITHUB_USERNAME")
-	}
-	if PreCheckEndpointURL == "" {
-		preCheckEndpointURLEnvValue := os.Getenv("PECHECK_ENDPOINT")
-		if preCheckEndpointURLEnvValue != "" {
-			PreCheckEndpointURL = preCheckEndpointURLEnvValue
-		} else {
-			PreCheckEndpointURL = localEndpoint
-		}
-	}
-	_ = generateCmd.MarkFlagRequired("github-token")
+	generateCmd.Flags().StringVarP(&WorkerID, "worker-id", "", defaultWorkerID(), "Unique ID of this worker, used to name its processing queue or stream consumer")
+	generateCmd.Flags().DurationVarP(&VisibilityTimeout, "visibility-timeout", "", 60*time.Second, "How long the worker's jobs stay claimed after its last heartbeat")
+	generateCmd.Flags().StringSliceVarP(&JobTypes, "job-types", "", nil, fmt.Sprintf("Comma-separated job types this worker accepts, out of %s. Defaults to all of them", strings.Join(jobs.Types, ", ")))
+	generateCmd.Flags().IntVarP(&Concurrency, "concurrency", "", 1, "Number of jobs processed at the same time")
+	generateCmd.Flags().DurationVarP(&ShutdownTimeout, "shutdown-timeout", "", 5*time.Minute, "How long to wait for in-flight jobs on shutdown before requeueing them")
+	config.MarkSecret(generateCmd.Flags(), "github-token", "precheck-api-key")
+	config.AddSecretFileFlags(generateCmd.Flags())
 	rootCmd.AddCommand(generateCmd)
+	// The config commands take the settings of generate, to check them
+	configCmd.PersistentFlags().AddFlagSet(generateCmd.Flags())
 }
 
 var generateCmd = &cobra.Command{
@@ -205,15 +219,31 @@ var generateCmd = &cobra.Command{
 		logger := initLogger(Debug)
 		sugar := logger.Sugar()
 
+		jobTypes, err := checkWorkerConfig()
+		if err != nil {
+			sugar.Fatalf("Invalid configuration: %v", err)
+		}
+		if ConfigFile != "" {
+			sugar.Infof("Loaded settings from %s", ConfigFile)
+		}
+		if GitRemote == "" {
+			GitRemote = strings.TrimSuffix(GithubWebURL, "/") + "/instructlab/taxonomy"
+		}
+		sugar.Infof("Using taxonomy remote %s", GitRemote)
+
+		// The signal context only stops the listener. Jobs run under a context
+		// of their own so they can finish during the shutdown grace period.
 		ctx, cancel := signal.NotifyContext(cmd.Context(), syscall.SIGTERM, syscall.SIGQUIT, syscall.SIGINT)
 		defer cancel()
+		jobsCtx, cancelJobs := context.WithCancel(context.WithoutCancel(cmd.Context()))
+		defer cancelJobs()
 
 		sugar.Info("Starting generate worker")
 
 		// Initialize Redis connection pool
 		pool := &redis.Pool{
-			MaxIdle: 3,
-			Dial: func() (redis.Conn, error) {
+			MaxIdle: 3 + Concurrency,
+			DialContext: func(ctx context.Context) (redis.Conn, error) {
 				return redis.DialContext(ctx, "tcp", RedisHost)
 			},
 		}
@@ -227,62 +257,133 @@ var generateCmd = &cobra.Command{
 		svc := s3.NewFromConfig(cfg)
 
 		// Read ilab config file
-		config, err := readIlabConfig(IlabConfigFile)
+		ilabConfig, err := readIlabConfig(IlabConfigFile)
 		if err != nil {
 			sugar.Fatalf("Could not read ilab config file: %v", err)
 		}
 
-		sugar.Info("ilab config read from config file: %+v", config)
+		sugar.Info("ilab config read from config file: %+v", ilabConfig)
 
-		sigChan := make(chan os.Signal, 1)
-		stopChan := make(chan struct{})
+		queue, err := jobs.NewConsumer(ctx, QueueBackend, pool, WorkerID, jobTypes, Concurrency)
+		if err != nil {
+			sugar.Fatalf("Could not set up the %s queue backend: %v", QueueBackend, err)
+		}
+		sugar = sugar.With("worker_id", WorkerID)
+		sugar.Infof("Accepting %s jobs", strings.Join(jobTypes, ", "))
 
-		signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
+		// Keep the worker heartbeat alive so the bot does not reap our jobs
+		if err := queue.Heartbeat(ctx, VisibilityTimeout); err != nil {
+			sugar.Fatalf("Could not register worker heartbeat in redis: %v", err)
+		}
 
-		var wg sync.WaitGroup
-		wg.Add(1)
-		go func(stopChan <-chan struct{}) {
-			defer wg.Done()
-			timer := time.NewTicker(1 * time.Second)
+		// The heartbeat outlives the listener until the last job is done
+		heartbeatCtx, stopHeartbeat := context.WithCancel(jobsCtx)
+		var heartbeatWg sync.WaitGroup
+		heartbeatWg.Add(1)
+		go func() {
+			defer heartbeatWg.Done()
+			ticker := time.NewTicker(VisibilityTimeout / 3)
+			defer ticker.Stop()
 			for {
 				select {
-				case <-stopChan:
-					sugar.Info("Shutting down job listener")
+				case <-heartbeatCtx.Done():
 					return
-				case <-timer.C:
-					conn := pool.Get()
-					job, err := redis.String(conn.Do("RPOP", "generate"))
-					conn.Close()
-					if err == redis.ErrNil {
-						continue
-					} else if err != nil {
-						sugar.Errorf("Could not pop from redis queue: %v", err)
-						continue
+				case <-ticker.C:
+					if err := queue.Heartbeat(heartbeatCtx, VisibilityTimeout); err != nil {
+						sugar.Errorf("Could not refresh worker heartbeat: %v", err)
 					}
-					NewJobProcessor(ctx, config, pool, svc, sugar, job,
-						PreCheckEndpointURL,
-						PrecheckAPIKey,
-						SdgEndpointURL,
-						TlsClientCertPath,
-						TlsClientKeyPath,
-						TlsServerCaCertPath,
-						MaxSeed).processJob()
 				}
 			}
-		}(stopChan)
+		}()
+
+		var jobsWg sync.WaitGroup
+		slots := make(chan struct{}, Concurrency)
+	listen:
+		for {
+			// Only take a job off the queue once there is room to run it
+			select {
+			case <-ctx.Done():
+				break listen
+			case slots <- struct{}{}:
+			}
+
+			delivery, err := queue.Dequeue(ctx, jobPollTimeout)
+			if err != nil || delivery == nil {
+				<-slots
+				if err != nil && ctx.Err() == nil {
+					sugar.Errorf("Could not pop from redis queue: %v", err)
+					sleepContext(ctx, jobPollTimeout)
+				}
+				continue
+			}
 
-		wg.Add(1)
-		go func(ch <-chan os.Signal) {
-			defer wg.Done()
-			<-ch
-			sugar.Info("Shutting down")
-			close(stopChan)
-		}(sigChan)
+			jobsWg.Add(1)
+			go func() {
+				defer jobsWg.Done()
+				defer func() { <-slots }()
+				err := NewJobProcessor(jobsCtx, ilabConfig, pool, queue, svc, sugar, delivery.JobID,
+					PreCheckEndpointURL,
+					PrecheckAPIKey,
+					SdgEndpointURL,
+					TlsClientCertPath,
+					TlsClientKeyPath,
+					TlsServerCaCertPath,
+					MaxSeed).processJob()
+
+				// Jobs interrupted by the shutdown, or that could not be
+				// started, go back to the queue for another attempt
+				settleCtx := context.WithoutCancel(jobsCtx)
+				if err != nil || jobsCtx.Err() != nil {
+					sugar.Infof("Requeueing job %s interrupted by shutdown or a redis error", delivery.JobID)
+					if err := delivery.Requeue(settleCtx); err != nil {
+						sugar.Errorf("Could not requeue job %s: %v", delivery.JobID, err)
+					}
+					return
+				}
+				if err := delivery.Ack(settleCtx); err != nil {
+					sugar.Errorf("Could not acknowledge job %s: %v", delivery.JobID, err)
+				}
+			}()
+		}
 
-		wg.Wait()
+		sugar.Infof("Shutting down job listener, waiting up to %s for in-flight jobs", ShutdownTimeout)
+		done := make(chan struct{})
+		go func() {
+			jobsWg.Wait()
+			close(done)
+		}()
+		select {
+		case <-done:
+		case <-time.After(ShutdownTimeout):
+			sugar.Warn("Shutdown timeout reached, stopping in-flight jobs")
+			cancelJobs()
+			<-done
+		}
+		stopHeartbeat()
+		heartbeatWg.Wait()
 	},
 }
 
+// sleepContext waits for the given duration or until the context is cancelled.
+func sleepContext(ctx context.Context, d time.Duration) {
+	select {
+	case <-ctx.Done():
+	case <-time.After(d):
+	}
+}
+
+// defaultWorkerID derives a worker ID that is unique across restarts, so the
+// jobs of a crashed worker are not hidden behind its successor's heartbeat.
+func defaultWorkerID() string {
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "worker"
+	}
+	suffix := make([]byte, 4)
+	_, _ = rand.Read(suffix)
+	return fmt.Sprintf("%s-%s", hostname, hex.EncodeToString(suffix))
+}
+
 // runPrecheck runs lab chat against git diffed yaml files
 func (w *Worker) runPrecheck(lab, outputDir, modelName string) error {
 	workDir := "."
@@ -386,7 +487,7 @@ func (w *Worker) runPrecheck(lab, outputDir, modelName string) error {
 		}
 	}()
 
-	cmd := exec.CommandContext(w.ctx, lab, "diff")
+	cmd := w.ilabCommand(lab, append([]string{"diff"}, w.taxonomyBaseArgs()...)...)
 	cmd.Dir = workDir
 	cmd.Env = os.Environ()
 	cmd.Stderr = os.Stderr
@@ -411,7 +512,10 @@ func (w *Worker) runPrecheck(lab, outputDir, modelName string) error {
 	w.logger.Debugf("Output: %s", outputStr)
 
 	yamlFileCount := 0
-	labDiffOutput := strings.Split(outputStr, "\n")
+	labDiffOutput, err := w.selectFiles(strings.Split(outputStr, "\n"))
+	if err != nil {
+		return err
+	}
 	isKnowledge := false
 
 	// Early check for YAML file presence before further processing
@@ -476,7 +580,9 @@ func (w *Worker) runKnowledgePrecheck(lab string, labDiffOutput []string, modelN
 			return err
 		}
 
+		w.jobLogf("Asking the questions of the %d seed examples of %s", len(seedExamples), file)
 		for seIndex, item := range seedExamples {
+			w.reportProgress(seIndex, len(seedExamples))
 			example, ok := item.(map[interface{}]interface{})
 			if !ok {
 				w.logger.Error("Invalid seed example format in knowledge YAML file")
@@ -497,7 +603,7 @@ func (w *Worker) runKnowledgePrecheck(lab string, labDiffOutput []string, modelN
 				continue
 			}
 
-			for _, qnaPair := range qnaPairs {
+			for qnaIndex, qnaPair := range qnaPairs {
 				qna, ok := qnaPair.(map[interface{}]interface{})
 				if !ok {
 					w.logger.Errorf("Invalid question and answer format in knowledge seed example %d", seIndex)
@@ -522,30 +628,22 @@ func (w *Worker) runKnowledgePrecheck(lab string, labDiffOutput []string, modelN
 				// Commenting out the context appending in case we need to revert back
 				// question = fmt.Sprintf("%s %s %s.", question, ctxPrompt, context)
 
-				commandStr := fmt.Sprintf("model chat --quick-question %s", question)
-				if TlsInsecure {
-					commandStr += " --tls-insecure"
-				}
-				if PreCheckEndpointURL != localEndpoint && modelName != "unknown" {
-					commandStr += fmt.Sprintf(" --endpoint-url %s --model %s", PreCheckEndpointURL, modelName)
-				}
-				if PrecheckAPIKey != "" {
-					commandStr += fmt.Sprintf(" --api-key %s", PrecheckAPIKey)
-				}
-				cmdArgs := strings.Fields(commandStr)
-				cmd := exec.Command(lab, cmdArgs...)
-				// Register the command for reporting/logging
-				w.cmdRun = cmd.String()
-				w.logger.Infof("Running the precheck command for knowledge contribution: %s", cmd.String())
+				cmd := w.precheckCommand(lab, question, modelName)
+				w.logger.Infof("Running the precheck command for knowledge contribution: %s", w.cmdRun)
 				cmd.Dir = workDir
 				cmd.Env = os.Environ()
 				var out bytes.Buffer
 				var errOut bytes.Buffer
 				cmd.Stdout = &out
-				cmd.Stderr = &errOut
+				cmd.Stderr = io.MultiWriter(&errOut, w.jobOutput())
+				w.jobLogf("Seed example %d/%d, question %d/%d", seIndex+1, len(seedExamples), qnaIndex+1, len(qnaPairs))
 				err = cmd.Run()
+				if w.jobCtx.Err() != nil {
+					return context.Cause(w.jobCtx)
+				}
 				if err != nil {
 					w.logger.Errorf("Precheck command failed for knowledge contribution with error: %v; stderr: %s", err, errOut.String())
+					w.jobLogf("Seed example %d/%d, question %d/%d failed: %v", seIndex+1, len(seedExamples), qnaIndex+1, len(qnaPairs), err)
 					continue
 				}
 
@@ -582,6 +680,7 @@ func (w *Worker) runKnowledgePrecheck(lab string, labDiffOutput []string, modelN
 			}
 
 		}
+		w.reportProgress(len(seedExamples), len(seedExamples))
 	}
 	return nil
 }
@@ -624,7 +723,9 @@ func (w *Worker) runSkillPrecheck(lab string, labDiffOutput []string, modelName
 			return err
 		}
 
-		for _, item := range seedExamples {
+		w.jobLogf("Asking the %d seed example questions of %s", len(seedExamples), file)
+		for seIndex, item := range seedExamples {
+			w.reportProgress(seIndex, len(seedExamples))
 			example, ok := item.(map[interface{}]interface{})
 			if !ok {
 				w.logger.Error("Invalid seed example format in the skill")
@@ -652,32 +753,23 @@ func (w *Worker) runSkillPrecheck(lab string, labDiffOutput []string, modelName
 				// Append the context to the question with a specific format
 				question = fmt.Sprintf("%s %s %s.", question, ctxPrompt, context)
 			}
-			commandStr := fmt.Sprintf("model chat --quick-question %s", question)
-			if TlsInsecure {
-				commandStr += " --tls-insecure"
-			}
-			if PreCheckEndpointURL != localEndpoint && modelName != "unknown" {
-				commandStr += fmt.Sprintf(" --endpoint-url %s --model %s", PreCheckEndpointURL, modelName)
-			}
-			if PrecheckAPIKey != "" {
-				commandStr += fmt.Sprintf(" --api-key %s", PrecheckAPIKey)
-			}
-
-			cmdArgs := strings.Fields(commandStr)
-			cmd := exec.Command(lab, cmdArgs...)
-			// Register the command for reporting/logging
-			w.cmdRun = cmd.String()
-			w.logger.Infof("Running the precheck command for skill contribution: %s", cmd.String())
+			cmd := w.precheckCommand(lab, question, modelName)
+			w.logger.Infof("Running the precheck command for skill contribution: %s", w.cmdRun)
 
 			cmd.Dir = workDir
 			cmd.Env = os.Environ()
 			var out bytes.Buffer
 			var errOut bytes.Buffer
 			cmd.Stdout = &out
-			cmd.Stderr = &errOut
+			cmd.Stderr = io.MultiWriter(&errOut, w.jobOutput())
+			w.jobLogf("Question %d/%d", seIndex+1, len(seedExamples))
 			err = cmd.Run()
+			if w.jobCtx.Err() != nil {
+				return context.Cause(w.jobCtx)
+			}
 			if err != nil {
 				w.logger.Errorf("Precheck command for skill failed with error: %v; stderr: %s", err, errOut.String())
+				w.jobLogf("Question %d/%d failed: %v", seIndex+1, len(seedExamples), err)
 				continue
 			}
 
@@ -718,52 +810,96 @@ func (w *Worker) runSkillPrecheck(lab string, labDiffOutput []string, modelName
 			// Sleep to ensure unique timestamps for filenames
 			time.Sleep(1 * time.Second)
 		}
+		w.reportProgress(len(seedExamples), len(seedExamples))
 	}
 	return nil
 }
 
-// processJob processes a given job, all jobs start here
-func (w *Worker) processJob() {
+// processJob processes a given job, all jobs start here. It returns an error
+// when the job could not be started because of Redis, so its delivery goes
+// back to the queue instead of being acknowledged.
+func (w *Worker) processJob() error {
 	sugar := w.logger.With("job", w.job)
 	sugar.Infof("Processing job %s", w.job)
 
-	// Get a new Redis connection from the pool for this operation
-	conn := w.pool.Get()
-	defer conn.Close()
-
-	// Set job status to 'pending'
-	if _, err := conn.Do("SET", fmt.Sprintf("jobs:%s:status", w.job), jobStatusRunning); err != nil {
-		sugar.Errorf("Could not set job status to pending in redis: %v", err)
-		return
+	job, err := w.store.Get(w.ctx, w.job)
+	if errors.Is(err, jobs.ErrNotFound) {
+		sugar.Warn("Skipping job no longer in redis")
+		return nil
+	}
+	if err != nil {
+		sugar.Errorf("Could not get job details from redis: %v", err)
+		return err
+	}
+	if job.Cancelled() {
+		sugar.Infof("Skipping job cancelled before it started: %s", job.CancelReason)
+		w.reportJobCancelled()
+		return nil
 	}
 
-	prNumber, err := redis.String(conn.Do("GET", fmt.Sprintf("jobs:%s:pr_number", w.job)))
+	// Set job status to 'running', unless the bot ended the job meanwhile. A
+	// requeued job may still be marked running by its previous attempt.
+	started, err := w.store.Transition(w.ctx, w.job, jobs.StatusRunning, jobs.StatusPending, jobs.StatusRunning)
 	if err != nil {
-		sugar.Errorf("Could not get pr_number from redis: %v", err)
-		return
+		sugar.Errorf("Could not set job status to running in redis: %v", err)
+		return err
+	}
+	if !started {
+		// The bot reported the job when it ended it
+		sugar.Info("Skipping job ended before it started")
+		return nil
 	}
 
-	jobType, err := redis.String(conn.Do("GET", fmt.Sprintf("jobs:%s:job_type", w.job)))
+	// The job log is streamed to the bot, which shows it on the check run
+	w.jobLog = jobs.NewLogWriter(w.ctx, w.pool, w.job).WithRedaction(secretRedactor.Redact)
+	defer w.jobLog.Flush()
+
+	// The options requested on the job command override the worker defaults
+	w.args, err = jobs.ParseArgs(job.Args)
 	if err != nil {
-		sugar.Errorf("Could not get job_type from redis: %v", err)
-		return
+		sugar.Errorf("Invalid options for job: %v", err)
+		w.reportJobError(err)
+		return nil
+	}
+	if job.Args != "" {
+		w.jobLogf("Job options: %s", job.Args)
+	}
+
+	// The job carries the repository of its PR, jobs queued before that
+	// use the worker's taxonomy remote
+	w.gitRemote = GitRemote
+	if job.GitRemote != "" {
+		w.gitRemote = job.GitRemote
+	}
+	w.baseBranch = defaultBaseBranch
+	if job.BaseBranch != "" {
+		w.baseBranch = job.BaseBranch
 	}
-	switch jobType {
-	case jobGenerateLocal:
-	case jobPreCheck:
-	case jobSDG:
-	default:
+
+	jobCtx, cancel := context.WithCancelCause(w.ctx)
+	defer cancel(nil)
+	w.jobCtx = jobCtx
+	go w.watchCancellation(jobCtx, cancel)
+	prNumber := strconv.Itoa(job.PRNumber)
+	jobType := job.JobType
+	runner, ok := runners[jobType]
+	if !ok {
 		sugar.Errorf("Unknown job type: %s", jobType)
-		return
+		w.reportJobError(fmt.Errorf("unknown job type %q", jobType))
+		return nil
 	}
 
 	// If in test mode, immediately post to the results queue
 	if TestMode {
 		//sleep to simulate processing time
-		time.Sleep(10 * time.Second)
+		sleepContext(w.jobCtx, 10*time.Second)
+		if w.jobCancelled() {
+			w.reportJobCancelled()
+			return nil
+		}
 		w.postJobResults("https://example.com", jobType)
 		sugar.Info("Job done (test mode)")
-		return
+		return nil
 	}
 
 	sugar = sugar.With("pr_number", prNumber)
@@ -771,33 +907,43 @@ func (w *Worker) processJob() {
 	workDir, err := os.Getwd()
 	if err != nil {
 		sugar.Errorf("Could not get working directory: %v", err)
-		return
+		w.reportJobError(err)
+		return nil
 	}
 	if WorkDir != "" {
 		workDir = WorkDir
 	}
-	taxonomyDir := w.ilabConfig.Generate.TaxonomyPath
 
-	sugar = sugar.With("work_dir", workDir, "origin", Origin)
-
-	// Clean up the taxonomy directory if it exists from a previous jobs
-	if _, err := os.Stat(taxonomyDir); !os.IsNotExist(err) {
-		sugar.Warnf("Taxonomy directory exists, deleting %s", taxonomyDir)
-		if err := deleteTaxonomyDir(taxonomyDir); err != nil {
-			sugar.Errorf("could not delete existing taxonomy directory: %v", err)
-		}
+	// Clone the taxonomy and keep the chat logs and output in a workspace of
+	// its own, so concurrent jobs do not step on each other
+	ws, err := newWorkspace(w.job, w.ilabConfig)
+	if err != nil {
+		sugar.Errorf("Could not set up job workspace: %v", err)
+		w.reportJobError(err)
+		return nil
 	}
+	defer func() {
+		if err := ws.cleanup(); err != nil {
+			sugar.Errorf("%v", err)
+		}
+	}()
+	w.workspace = ws
+	w.ilabConfig = ws.ilabConfig
+	taxonomyDir := w.ilabConfig.Generate.TaxonomyPath
+
+	sugar = sugar.With("work_dir", workDir, "workspace", ws.dir, "origin", Origin)
 
+	w.jobLogf("Fetching pull request #%s", prNumber)
 	headHash, err := w.gitOperations(sugar, taxonomyDir, prNumber)
 	if err != nil {
 		w.logger.Errorf("git operations error: %v", err)
 		wrappedErr := fmt.Errorf("git operations error: %w", err)
 		w.reportJobError(wrappedErr)
-		return
+		return nil
 	}
 
 	outDirName := fmt.Sprintf("%s-pr-%s-%s", jobType, prNumber, headHash)
-	outputDir := path.Join(workDir, outDirName)
+	outputDir := path.Join(ws.dir, outDirName)
 
 	sugar = sugar.With("out_dir", outputDir)
 	_ = os.MkdirAll(outputDir, 0755)
@@ -809,7 +955,7 @@ func (w *Worker) processJob() {
 
 	var modelName string
 	// sdg-svc does not have a models endpoint as yet
-	if jobType != jobSDG && PreCheckEndpointURL != localEndpoint {
+	if jobType != jobs.TypeSDG && PreCheckEndpointURL != localEndpoint {
 		var err error
 		modelName, err = w.fetchModelName(true)
 		if err != nil {
@@ -820,197 +966,65 @@ func (w *Worker) processJob() {
 	} else {
 		modelName = w.getModelNameFromConfig()
 	}
+	if w.args.Model != "" {
+		modelName = w.args.Model
+	}
 
-	var cmd *exec.Cmd
-	switch jobType {
-	case jobGenerateLocal:
-		// @instructlab-bot generate-local
-		// Runs generate on the local worker node
-		generateArgs := []string{"data", "generate", "--num-instructions", fmt.Sprintf("%d", NumInstructions), "--output-dir", outputDir}
-
-		cmd = exec.CommandContext(w.ctx, lab, generateArgs...)
-		if WorkDir != "" {
-			cmd.Dir = WorkDir
-		}
-
-		var stderr bytes.Buffer
-		// Capture both the ilab err buffer and the os.Stderr
-		cmd.Stderr = io.MultiWriter(&stderr, os.Stderr)
-		cmd.Env = os.Environ()
-		cmd.Stdout = os.Stdout
-
-		sugar.Debug(fmt.Sprintf("Running %s job", jobType))
-		// Run the command
-		sugar.Infof("Running the generate command: %s", cmd.String())
-		if err := cmd.Run(); err != nil {
-			detailedErr := fmt.Errorf("Error running command (%s %s): %v. \nDetails: %s", cmd.Path, strings.Join(generateArgs, " "), err, stderr.String())
-			sugar.Errorf(detailedErr.Error())
-			w.reportJobError(detailedErr)
-			return
-		}
-	case jobPreCheck:
-		// @instructlab-bot precheck
-		// Runs precheck on a backend node
-		err = w.runPrecheck(lab, outputDir, modelName)
-		if err != nil {
-			sugar.Errorf("Could not run precheck: %v", err)
-			w.reportJobError(err)
-			return
-		}
-	case jobSDG:
-		// @instructlab-bot generate
-		// Runs generate on the SDG backend
-		// ilab diff is run since the sdg generation is not part of upstream cli
-		cmdDiff := exec.Command("ilab", "taxonomy", "diff")
-		var stderr bytes.Buffer
-		cmdDiff.Stderr = &stderr
-
-		diffOutput, err := cmdDiff.Output()
-		if err != nil {
-			detailedErr := fmt.Errorf("Failed to execute 'ilab diff': %v. \nDetails: %s", err, stderr.String())
-			w.reportJobError(detailedErr)
-			sugar.Errorf(detailedErr.Error())
-			return
-		}
-
-		diffOutputLines := strings.Split(string(diffOutput), "\n")
-		// Filter taxonomy files ending in .yaml and prepare them relative to workDir
-		var taxonomyFiles []string
-		for _, file := range diffOutputLines {
-			if strings.HasSuffix(file, ".yaml") {
-				relativePath := filepath.Join(w.ilabConfig.Generate.TaxonomyPath, file)
-				taxonomyFiles = append(taxonomyFiles, relativePath)
-			}
-		}
-
-		// Uncomment to bypass ilab diff
-		//taxonomyFiles, err := discoverGitTaxonomyFiles(taxonomyDir, "main")
-		//if err != nil {
-		//	sugar.Errorf("Failed to discover taxonomy files: %v", err)
-		//	return
-		//}
-
-		if len(taxonomyFiles) == 0 {
+	run := JobRun{Logger: sugar, Lab: lab, OutputDir: outputDir, ModelName: modelName}
+	sugar.Debug(fmt.Sprintf("Running %s job", jobType))
+	w.jobLogf("Running the %s job on commit %s", jobType, headHash)
+	if err := runner.Run(w, run); err != nil {
+		if errors.Is(err, errNoChanges) {
 			sugar.Info("No taxonomy files were changed.")
-			return
-		}
-
-		// Process each YAML file and filter questions if over the max seed
-		filteredFiles := []string{}
-		for _, file := range taxonomyFiles {
-			f, err := os.Open(file)
-			if err != nil {
-				sugar.Errorf("Failed to open file: %v", err)
-				continue
-			}
-			defer f.Close()
-
-			decoder := yaml.NewDecoder(f)
-			var data map[string]interface{}
-			if err := decoder.Decode(&data); err != nil {
-				sugar.Errorf("Failed to decode YAML file: %v", err)
-				continue
-			}
-
-			if seedExamples, ok := data["seed_examples"].([]interface{}); ok && len(seedExamples) > w.maxSeed {
-				originalCount := len(seedExamples)
-				data["seed_examples"] = seedExamples[:w.maxSeed]
-				outputData, err := yaml.Marshal(data)
-				if err != nil {
-					sugar.Errorf("Failed to re-marshal filtered YAML data: %v", err)
-					continue
-				}
-
-				// Write the modified content back to a new file to pass to datagenSvc instead of the original diff
-				filteredQNA, err := os.CreateTemp("", "filtered-*.yaml")
-				if err != nil {
-					sugar.Errorf("Failed to create temporary file: %v", err)
-					continue
-				}
-				defer filteredQNA.Close()
-
-				if _, err = filteredQNA.Write(outputData); err != nil {
-					sugar.Errorf("Failed to write filtered data to the new QNA file: %v", err)
-					continue
-				}
-				sugar.Infof("Trimmed %s from %d to %d Q&A pairs", file, originalCount, w.maxSeed)
-
-				filteredFiles = append(filteredFiles, filteredQNA.Name())
-			} else {
-				// No filtering needed, use the original file
-				filteredFiles = append(filteredFiles, file)
-			}
+			w.jobLogf("No taxonomy files were changed")
+			// A job without results tells the bot there was nothing to do
+			w.postJobResults("", jobType)
+			return nil
 		}
+		sugar.Errorf("Could not run %s job: %v", jobType, err)
+		w.reportJobError(err)
+		return nil
+	}
 
-		// Generate data with potentially filtered files
-		outputFiles, err := w.datagenSvc(filteredFiles, outputDir, NumInstructions)
-		if err != nil {
-			sugar.Errorf("Failed to generate data: %v", err)
-			w.reportJobError(err)
-			return
-		}
-		sugar.Infof("Generated data written to: %v", outputFiles)
-
-	default:
-		sugar.Errorf("Unknown job type: %s", jobType)
-		return
+	if w.jobCancelled() {
+		w.reportJobCancelled()
+		return nil
 	}
 
 	// handle file operations and get the index file key
+	w.jobLogf("Uploading the results")
 	indexUpKey := w.handleOutputFiles(outputDir, prNumber, outDirName)
 	if indexUpKey == "" {
 		sugar.Errorf("Failed to handle output files correctly")
-		return
+		w.reportJobError(errors.New("failed to upload the results"))
+		return nil
 	}
 
 	indexPublicURL := fmt.Sprintf("https://%s.s3.%s.amazonaws.com/%s", S3Bucket, AWSRegion, indexUpKey)
 
 	// Notify the "results" queue that the job is done with the public URL
 	w.postJobResults(indexPublicURL, jobType)
-
-	// Clean up the taxonomy directory if it exists
-	if _, err := os.Stat(taxonomyDir); !os.IsNotExist(err) {
-		sugar.Warnf("Taxonomy directory exists, deleting %s", taxonomyDir)
-		if err := deleteTaxonomyDir(taxonomyDir); err != nil {
-			sugar.Errorf("could not delete existing taxonomy directory: %v", err)
-		}
-	}
 	sugar.Infof("Job done")
+	return nil
 }
 
 // postJobResults posts the results of a job to a Redis queue
 func (w *Worker) postJobResults(URL, jobType string) {
-	conn := w.pool.Get()
-	defer conn.Close()
-
 	// Calculate the job duration and round it up
-	jobDuration := time.Since(w.jobStart).Seconds()
-	roundedDuration := math.Ceil(jobDuration)
-	w.logger.Infof("Job took %.0fs to run", roundedDuration)
-
-	if _, err := conn.Do("SET", fmt.Sprintf("jobs:%s:duration", w.job), roundedDuration); err != nil {
-		w.logger.Errorf("Could not set job duration in redis: %v", err)
-	}
-
-	if _, err := conn.Do("SET", fmt.Sprintf("jobs:%s:status", w.job), jobStatusSuccess); err != nil {
-		w.logger.Errorf("Could not set job status in redis: %v", err)
-	}
-
-	if _, err := conn.Do("SET", fmt.Sprintf("jobs:%s:s3_url", w.job), URL); err != nil {
-		w.logger.Errorf("Could not set s3_url in redis: %v", err)
-	}
-
-	if _, err := conn.Do("SET", fmt.Sprintf("jobs:%s:cmd", w.job), w.cmdRun); err != nil {
-		w.logger.Errorf("Could not set cmd in redis: %v", err)
-	}
-
-	modelName := w.determineModelName(jobType)
-
-	if _, err := conn.Do("SET", fmt.Sprintf("jobs:%s:model_name", w.job), modelName); err != nil {
-		w.logger.Errorf("Could not set model name in redis: %v", err)
+	jobDuration := time.Since(w.jobStart)
+	w.logger.Infof("Job took %.0fs to run", math.Ceil(jobDuration.Seconds()))
+
+	err := w.store.Complete(w.ctx, w.job, jobs.Result{
+		Duration:  jobDuration,
+		S3URL:     URL,
+		ModelName: w.determineModelName(jobType),
+		Cmd:       secretRedactor.Redact(w.cmdRun),
+	})
+	if err != nil {
+		w.logger.Errorf("Could not set job results in redis: %v", err)
 	}
 
-	if _, err := conn.Do("LPUSH", "results", w.job); err != nil {
+	if err := w.consumer.PostResult(w.ctx, w.job); err != nil {
 		w.logger.Errorf("Could not push to redis queue: %v", err)
 	}
 }
@@ -1058,9 +1072,12 @@ func (w *Worker) fetchModelName(fullName bool) (string, error) {
 	}
 	endpoint += "models"
 
-	http.DefaultTransport.(*http.Transport).TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
-	http.DefaultTransport.(*http.Transport).TLSHandshakeTimeout = 10 * time.Second
-	http.DefaultTransport.(*http.Transport).ExpectContinueTimeout = 1 * time.Second
+	// Jobs run concurrently, so tune a copy of the default transport rather than the shared one
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+	transport.TLSHandshakeTimeout = 10 * time.Second
+	transport.ExpectContinueTimeout = 1 * time.Second
+	client := &http.Client{Transport: transport}
 
 	req, err := http.NewRequestWithContext(w.ctx, "GET", endpoint, nil)
 	if err != nil {
@@ -1071,7 +1088,7 @@ func (w *Worker) fetchModelName(fullName bool) (string, error) {
 		w.logger.Info("Set Authorization header with precheck API key")
 		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", w.precheckAPIKey))
 	}
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := client.Do(req)
 	if err != nil {
 		return "", fmt.Errorf("failed to fetch model details: %w", err)
 	}
@@ -1124,34 +1141,145 @@ func (w *Worker) fetchModelName(fullName bool) (string, error) {
 	return "", fmt.Errorf("model name not found in response")
 }
 
+// jobLogf appends a line to the job log streamed to the bot. The log is shown
+// on the pull request, so it must not hold secrets.
+func (w *Worker) jobLogf(format string, args ...interface{}) {
+	fmt.Fprintf(w.jobOutput(), format+"\n", args...)
+}
+
+// jobOutput returns the writer of the job log, to stream command output to.
+func (w *Worker) jobOutput() io.Writer {
+	if w.jobLog == nil {
+		return io.Discard
+	}
+	return w.jobLog
+}
+
+// reportProgress records how many of the steps of the job are done.
+func (w *Worker) reportProgress(done, total int) {
+	if err := w.store.Progress(w.ctx, w.job, done, total); err != nil {
+		w.logger.Warnf("Could not record the progress of job %s: %v", w.job, err)
+	}
+}
+
 // reportJobError push app errors into the redis job 'errors' key
 func (w *Worker) reportJobError(err error) {
-	conn := w.pool.Get()
-	defer conn.Close()
+	// Errors caused by killing the job's commands are reported as a cancellation
+	if w.jobCancelled() {
+		w.reportJobCancelled()
+		return
+	}
+	// Jobs interrupted by a shutdown are handed back to the queue instead
+	if w.ctx.Err() != nil {
+		w.logger.Warnf("Job %s interrupted by shutdown: %v", w.job, err)
+		return
+	}
 
-	if _, err := conn.Do("SET", fmt.Sprintf("jobs:%s:errors", w.job), err.Error()); err != nil {
+	if err := w.store.Fail(w.ctx, w.job, errors.New(secretRedactor.Redact(err.Error()))); err != nil {
 		w.logger.Errorf("Failed to set the error for job %s: %v", w.job, err)
 		return
 	}
 
-	if _, err := conn.Do("SET", fmt.Sprintf("jobs:%s:status", w.job), jobStatusError); err != nil {
-		w.logger.Errorf("Could not set job status in redis: %v", err)
+	if err := w.consumer.PostResult(w.ctx, w.job); err != nil {
+		w.logger.Errorf("Could not push error results to redis queue: %v", err)
+		return
 	}
+}
 
-	if _, err := conn.Do("LPUSH", "results", w.job); err != nil {
-		w.logger.Errorf("Could not push error results to redis queue: %v", err)
+// ilabCommand returns an ilab command bound to the job context, using the
+// config file of the job workspace once it is set up.
+func (w *Worker) ilabCommand(lab string, args ...string) *exec.Cmd {
+	if w.workspace != nil {
+		args = append([]string{"--config", w.workspace.configFile}, args...)
+	}
+	return exec.CommandContext(w.jobCtx, lab, args...)
+}
+
+// precheckCommand returns the ilab model chat command asking a question of
+// the precheck model, and registers it for reporting. The registered command
+// has the API key redacted, as it ends up in the job log and in Redis.
+func (w *Worker) precheckCommand(lab, question, modelName string) *exec.Cmd {
+	commandStr := fmt.Sprintf("model chat --quick-question %s", question)
+	if TlsInsecure {
+		commandStr += " --tls-insecure"
+	}
+	if PreCheckEndpointURL != localEndpoint && modelName != "unknown" {
+		commandStr += fmt.Sprintf(" --endpoint-url %s --model %s", PreCheckEndpointURL, modelName)
+	}
+	if w.precheckAPIKey != "" {
+		commandStr += fmt.Sprintf(" --api-key %s", w.precheckAPIKey)
+	}
+	cmd := w.ilabCommand(lab, strings.Fields(commandStr)...)
+	w.cmdRun = secretRedactor.Redact(cmd.String())
+	return cmd
+}
+
+// taxonomyBaseArgs returns the ilab diff options comparing the PR to its base
+// branch, which ilab diff assumes is main.
+func (w *Worker) taxonomyBaseArgs() []string {
+	if w.baseBranch == "" || w.baseBranch == defaultBaseBranch {
+		return nil
+	}
+	return []string{"--taxonomy-base", "origin/" + w.baseBranch}
+}
+
+// watchCancellation polls the cancellation flag of the job and cancels the
+// job context once it is set, which kills the running ilab commands.
+func (w *Worker) watchCancellation(ctx context.Context, cancel context.CancelCauseFunc) {
+	ticker := time.NewTicker(cancelPollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			job, err := w.store.Get(ctx, w.job)
+			if err != nil {
+				w.logger.Warnf("Could not check the cancellation flag of job %s: %v", w.job, err)
+				continue
+			}
+			if job.Cancelled() {
+				w.logger.Infof("Cancelling job %s: %s", w.job, job.CancelReason)
+				cancel(fmt.Errorf("%w: %s", errJobCancelled, job.CancelReason))
+				return
+			}
+		}
+	}
+}
+
+// jobCancelled reports whether the job context was cancelled on request.
+func (w *Worker) jobCancelled() bool {
+	return errors.Is(context.Cause(w.jobCtx), errJobCancelled)
+}
+
+// reportJobCancelled marks the job as cancelled and notifies the bot, unless
+// the job already ended.
+func (w *Worker) reportJobCancelled() {
+	cancelled, err := w.store.Transition(w.ctx, w.job, jobs.StatusCancelled, jobs.StatusPending, jobs.StatusRunning)
+	if err != nil {
+		w.logger.Errorf("Failed to set the cancelled status of job %s: %v", w.job, err)
 		return
 	}
+	if !cancelled {
+		return
+	}
+
+	if err := w.consumer.PostResult(w.ctx, w.job); err != nil {
+		w.logger.Errorf("Could not push cancelled results to redis queue: %v", err)
+	}
 }
 
 // determineModelName decides the model name based on jobType and configuration.
 func (w *Worker) determineModelName(jobType string) string {
-	if jobType == jobSDG {
+	if jobType == jobs.TypeSDG {
 		return "sdg service backend"
 	}
+	if w.args.Model != "" {
+		return w.args.Model
+	}
 
 	// precheck is the only case we use a remote OpenAI endpoint right now
-	if PreCheckEndpointURL != localEndpoint && jobType == jobPreCheck {
+	if PreCheckEndpointURL != localEndpoint && jobType == jobs.TypePrecheck {
 		modelName, err := w.fetchModelName(false)
 		if err != nil {
 			w.logger.Errorf("Failed to fetch model name: %v", err)
@@ -1172,7 +1300,9 @@ func (w *Worker) datagenSvc(taxonomyFiles []string, outputDir string, numSamples
 		return nil, err
 	}
 
-	for _, tf := range taxonomyFiles {
+	for i, tf := range taxonomyFiles {
+		w.reportProgress(i, len(taxonomyFiles))
+		w.jobLogf("Generating data for %s (%d/%d)", filepath.Base(tf), i+1, len(taxonomyFiles))
 		tfData, err := os.ReadFile(tf)
 		if err != nil {
 			return nil, fmt.Errorf("failed to read taxonomy file '%s': %w", tf, err)
@@ -1210,7 +1340,7 @@ func (w *Worker) datagenSvc(taxonomyFiles []string, outputDir string, numSamples
 			requestURL = strings.Replace(requestURL, "skill", "knowledge", -1)
 		}
 
-		request, err := http.NewRequestWithContext(w.ctx, "POST", requestURL, bytes.NewBuffer(jsonData))
+		request, err := http.NewRequestWithContext(w.jobCtx, "POST", requestURL, bytes.NewBuffer(jsonData))
 		if err != nil {
 			return nil, fmt.Errorf("failed to create request: %w", err)
 		}
@@ -1244,6 +1374,7 @@ func (w *Worker) datagenSvc(taxonomyFiles []string, outputDir string, numSamples
 
 		outputFiles = append(outputFiles, outputPath)
 	}
+	w.reportProgress(len(taxonomyFiles), len(taxonomyFiles))
 
 	return outputFiles, nil
 }
@@ -1421,12 +1552,17 @@ func (w *Worker) handleOutputFiles(outputDir, prNumber, outDirName string) strin
 				continue
 			}
 			defer file.Close()
+			body, err := redactedBody(file)
+			if err != nil {
+				sugar.Errorf("Could not read file: %v", err)
+				continue
+			}
 
 			upKey := fmt.Sprintf("%s/%s", jobSpecificOutDirName, filename)
 			_, err = w.svc.PutObject(w.ctx, &s3.PutObjectInput{
 				Bucket:      aws.String(S3Bucket),
 				Key:         aws.String(upKey),
-				Body:        file,
+				Body:        body,
 				ContentType: aws.String(contentType),
 			})
 			if err != nil {
@@ -1465,12 +1601,17 @@ func (w *Worker) handleOutputFiles(outputDir, prNumber, outDirName string) strin
 		return ""
 	}
 	defer indexFile.Close()
+	indexBody, err := redactedBody(indexFile)
+	if err != nil {
+		sugar.Errorf("Could not read index.html: %v", err)
+		return ""
+	}
 
 	indexUpKey := fmt.Sprintf("%s/index.html", jobSpecificOutDirName)
 	_, err = w.svc.PutObject(w.ctx, &s3.PutObjectInput{
 		Bucket:      aws.String(S3Bucket),
 		Key:         aws.String(indexUpKey),
-		Body:        indexFile,
+		Body:        indexBody,
 		ContentType: aws.String("text/html"),
 	})
 	if err != nil {
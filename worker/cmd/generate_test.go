@@ -8,8 +8,13 @@ import (
 	"os"
 	"regexp"
 	"testing"
+	"time"
 
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gomodule/redigo/redis"
+	"github.com/instructlab/instructlab-bot/pkg/jobs"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"go.uber.org/zap"
 )
 
@@ -151,6 +156,7 @@ func TestFetchModelName(t *testing.T) {
 		nil,
 		nil,
 		nil,
+		nil,
 		zap.NewExample().Sugar(),
 		"job-id",
 		mockServer.URL,
@@ -214,6 +220,7 @@ func TestFetchModelNameWithInvalidObject(t *testing.T) {
 		nil,
 		nil,
 		nil,
+		nil,
 		zap.NewExample().Sugar(),
 		"job-id",
 		mockServer.URL,
@@ -231,8 +238,96 @@ func TestFetchModelNameWithInvalidObject(t *testing.T) {
 	assert.Empty(t, modelName, "The model name should be empty for invalid object field")
 }
 
+func TestSelectFiles(t *testing.T) {
+	diff := []string{"compositional_skills/a/qna.yaml", "knowledge/b/qna.yaml", ""}
+	w := &Worker{}
+
+	selected, err := w.selectFiles(diff)
+	assert.NoError(t, err)
+	assert.Equal(t, diff, selected)
+
+	w.args.Files = []string{"knowledge/b/qna.yaml"}
+	selected, err = w.selectFiles(diff)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"knowledge/b/qna.yaml"}, selected)
+
+	w.args.Files = []string{"knowledge/c/qna.yaml"}
+	_, err = w.selectFiles(diff)
+	assert.Error(t, err, "selectFiles should fail for a file the PR does not change")
+}
+
 // Replace all whitespace sequences with a single space. Remove spaces between HTML tags
 func normalizeHTML(input string) string {
 	compacted := regexp.MustCompile(`\s+`).ReplaceAllString(input, " ")
 	return regexp.MustCompile(`>\s+<`).ReplaceAllString(compacted, "><")
 }
+
+func TestPrecheckCommand(t *testing.T) {
+	defer func(url string) { PreCheckEndpointURL = url }(PreCheckEndpointURL)
+	PreCheckEndpointURL = "https://precheck.example.com"
+	secretRedactor.Add("sk-precheck-key")
+	w := &Worker{jobCtx: context.Background(), precheckAPIKey: "sk-precheck-key"}
+
+	cmd := w.precheckCommand("ilab", "What is a taxonomy?", "granite-7b-lab")
+	assert.Equal(t, []string{"ilab", "model", "chat", "--quick-question", "What", "is", "a", "taxonomy?",
+		"--endpoint-url", "https://precheck.example.com", "--model", "granite-7b-lab",
+		"--api-key", "sk-precheck-key"}, cmd.Args)
+	assert.NotContains(t, w.cmdRun, "sk-precheck-key")
+	assert.Contains(t, w.cmdRun, "--api-key REDACTED")
+
+	w.precheckAPIKey = ""
+	cmd = w.precheckCommand("ilab", "What is a taxonomy?", "granite-7b-lab")
+	assert.NotContains(t, cmd.Args, "--api-key")
+}
+
+// newTestProcessor returns a worker processing the next job of an in-memory
+// Redis, and the store of the jobs.
+func newTestProcessor(t *testing.T, mr *miniredis.Miniredis) (*Worker, jobs.JobStore) {
+	ctx := context.Background()
+	pool := &redis.Pool{
+		Dial: func() (redis.Conn, error) {
+			return redis.Dial("tcp", mr.Addr())
+		},
+	}
+	t.Cleanup(func() { pool.Close() })
+	store := jobs.NewRedigoStore(pool)
+	id, err := store.Create(ctx, &jobs.Job{PRNumber: 7, JobType: jobs.TypePrecheck})
+	require.NoError(t, err)
+
+	consumer := jobs.NewListConsumer(pool, "worker-a", jobs.Types, 1)
+	conn := pool.Get()
+	defer conn.Close()
+	_, err = redis.DoContext(conn, ctx, "LPUSH", jobs.GenerateQueue(jobs.TypePrecheck), id)
+	require.NoError(t, err)
+	delivery, err := consumer.Dequeue(ctx, time.Second)
+	require.NoError(t, err)
+	require.NotNil(t, delivery)
+
+	w := NewJobProcessor(ctx, nil, pool, consumer, nil, zap.NewNop().Sugar(), id,
+		"", "", "", "", "", "", 20)
+	return w, store
+}
+
+func TestProcessJobCancelledBeforeStart(t *testing.T) {
+	mr := miniredis.RunT(t)
+	w, store := newTestProcessor(t, mr)
+	require.NoError(t, store.Cancel(context.Background(), w.job, "cancelled by @alice"))
+
+	// The job is closed out for the bot instead of being left pending
+	assert.NoError(t, w.processJob())
+	job, err := store.Get(context.Background(), w.job)
+	require.NoError(t, err)
+	assert.Equal(t, jobs.StatusCancelled, job.Status)
+	results, err := mr.List(jobs.QueueResults)
+	require.NoError(t, err)
+	assert.Equal(t, []string{w.job}, results)
+}
+
+func TestProcessJobRedisError(t *testing.T) {
+	mr := miniredis.RunT(t)
+	w, _ := newTestProcessor(t, mr)
+
+	// The delivery goes back to the queue when the job can not be read
+	mr.SetError("LOADING")
+	assert.Error(t, w.processJob())
+}
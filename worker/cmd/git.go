@@ -12,6 +12,10 @@ import (
 	"go.uber.org/zap"
 )
 
+// defaultBaseBranch is the branch PRs are compared to when their job does not
+// name one.
+const defaultBaseBranch = "main"
+
 // gitOperations handles the git operations for a job and returns the head hash for the PR
 func (w *Worker) gitOperations(logger *zap.SugaredLogger, taxonomyDir string, prNumber string) (string, error) {
 	logger.Debug("Opening taxonomy git repo")
@@ -21,9 +25,9 @@ func (w *Worker) gitOperations(logger *zap.SugaredLogger, taxonomyDir string, pr
 
 	// Check if the taxonomy directory exists, clone it if it does not
 	if _, err := os.Stat(taxonomyDir); os.IsNotExist(err) {
-		logger.Debugf("Taxonomy directory does not exist, cloning from %s", GitRemote)
+		logger.Debugf("Taxonomy directory does not exist, cloning from %s", w.gitRemote)
 		r, err = git.PlainClone(taxonomyDir, false, &git.CloneOptions{
-			URL: GitRemote,
+			URL: w.gitRemote,
 			Auth: &githttp.BasicAuth{
 				Username: GithubUsername,
 				Password: GithubToken,
@@ -45,8 +49,8 @@ func (w *Worker) gitOperations(logger *zap.SugaredLogger, taxonomyDir string, pr
 		return "", err
 	}
 
-	// Checkout the main branch
-	if err := checkoutBranch(r, "main", logger); err != nil {
+	// Checkout the base branch
+	if err := checkoutBranch(r, w.baseBranch, logger); err != nil {
 		return "", err
 	}
 
@@ -139,7 +143,7 @@ func fetchAndCheckoutPRBranch(r *git.Repository, prNumber, prBranchName string,
 		RemoteName: "origin",
 		RefSpecs:   []gitconfig.RefSpec{refspec},
 		Auth: &githttp.BasicAuth{
-			Username: "instructlab-bot",
+			Username: GithubUsername,
 			Password: GithubToken,
 		},
 	})
@@ -159,11 +163,3 @@ func fetchAndCheckoutPRBranch(r *git.Repository, prNumber, prBranchName string,
 
 	return nil
 }
-
-// deleteTaxonomyDir deletes the taxonomy directory and its contents
-func deleteTaxonomyDir(taxonomyDir string) error {
-	if err := os.RemoveAll(taxonomyDir); err != nil {
-		return fmt.Errorf("could not delete taxonomy directory: %v", err)
-	}
-	return nil
-}
@@ -0,0 +1,100 @@
+package cmd
+
+import (
+	"net/http"
+	"net/http/cgi"
+	"net/http/httptest"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+// runGit runs a git command in dir and returns its trimmed output.
+func runGit(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	cmd.Env = append(cmd.Environ(), "GIT_AUTHOR_NAME=test", "GIT_AUTHOR_EMAIL=test@example.com",
+		"GIT_COMMITTER_NAME=test", "GIT_COMMITTER_EMAIL=test@example.com")
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %s: %v\n%s", strings.Join(args, " "), err, out)
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// TestGitOperationsOnEnterpriseServer clones a PR from a fake GitHub
+// Enterprise Server serving git over HTTP, with the remote and the base
+// branch carried by the job.
+func TestGitOperationsOnEnterpriseServer(t *testing.T) {
+	gitPath, err := exec.LookPath("git")
+	if err != nil {
+		t.Skip("git is not installed")
+	}
+
+	// The repository has a staging base branch and a PR ref, the way GitHub
+	// exposes pull requests
+	root := t.TempDir()
+	src := filepath.Join(root, "src")
+	runGit(t, root, "init", "-q", "-b", "staging", src)
+	runGit(t, src, "commit", "-q", "--allow-empty", "-m", "base")
+	runGit(t, src, "checkout", "-q", "-b", "contribution")
+	runGit(t, src, "commit", "-q", "--allow-empty", "-m", "contribution")
+	prSha := runGit(t, src, "rev-parse", "HEAD")
+	repos := filepath.Join(root, "repos")
+	runGit(t, root, "clone", "-q", "--bare", src, filepath.Join(repos, "acme", "taxonomy.git"))
+	runGit(t, filepath.Join(repos, "acme", "taxonomy.git"), "update-ref", "refs/pull/7/head", prSha)
+
+	var mu sync.Mutex
+	var paths []string
+	backend := &cgi.Handler{
+		Path: gitPath,
+		Args: []string{"http-backend"},
+		Env:  []string{"GIT_PROJECT_ROOT=" + repos, "GIT_HTTP_EXPORT_ALL=1"},
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		paths = append(paths, r.URL.Path)
+		mu.Unlock()
+		backend.ServeHTTP(w, r)
+	}))
+	defer srv.Close()
+
+	w := &Worker{
+		gitRemote:  srv.URL + "/acme/taxonomy.git",
+		baseBranch: "staging",
+	}
+	head, err := w.gitOperations(zap.NewNop().Sugar(), filepath.Join(root, "taxonomy"), "7")
+	if err != nil {
+		t.Fatalf("gitOperations: %v", err)
+	}
+	if head != prSha {
+		t.Errorf("Head = %s, want the PR commit %s", head, prSha)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(paths) == 0 {
+		t.Fatal("No request reached the fake GitHub")
+	}
+	for _, path := range paths {
+		if !strings.HasPrefix(path, "/acme/taxonomy.git/") {
+			t.Errorf("Unexpected request %s", path)
+		}
+	}
+}
+
+func TestTaxonomyBaseArgs(t *testing.T) {
+	w := &Worker{baseBranch: defaultBaseBranch}
+	if args := w.taxonomyBaseArgs(); args != nil {
+		t.Errorf("taxonomyBaseArgs on main = %v, want none", args)
+	}
+	w.baseBranch = "staging"
+	if got := strings.Join(w.taxonomyBaseArgs(), " "); got != "--taxonomy-base origin/staging" {
+		t.Errorf("taxonomyBaseArgs on staging = %q", got)
+	}
+}
@@ -0,0 +1,52 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/instructlab/instructlab-bot/pkg/jobs"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	rootCmd.AddCommand(pendingCmd)
+}
+
+var pendingCmd = &cobra.Command{
+	Use:   "pending",
+	Short: "List the jobs claimed by each worker and not acknowledged yet.",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx := cmd.Context()
+		pool := &redis.Pool{
+			MaxIdle: 1,
+			Dial: func() (redis.Conn, error) {
+				return redis.DialContext(ctx, "tcp", RedisHost)
+			},
+		}
+		defer pool.Close()
+
+		// The consumer is only used to inspect the queue, its ID is never registered
+		queue, err := jobs.NewConsumer(ctx, QueueBackend, pool, "", jobs.Types, 0)
+		if err != nil {
+			return err
+		}
+		pending, err := queue.Pending(ctx)
+		if err != nil {
+			return fmt.Errorf("could not list pending jobs: %w", err)
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "WORKER\tJOB\tATTEMPTS\tIDLE")
+		for _, p := range pending {
+			idle := "-"
+			if p.Idle > 0 {
+				idle = p.Idle.Round(time.Second).String()
+			}
+			fmt.Fprintf(w, "%s\t%s\t%d\t%s\n", p.WorkerID, p.JobID, p.Attempts, idle)
+		}
+		return w.Flush()
+	},
+}
@@ -3,24 +3,32 @@ package cmd
 import (
 	"fmt"
 	"os"
-	"strings"
 
+	"github.com/instructlab/instructlab-bot/pkg/config"
+	"github.com/instructlab/instructlab-bot/pkg/jobs"
+	"github.com/instructlab/instructlab-bot/pkg/secrets"
 	"github.com/spf13/cobra"
 	"github.com/spf13/pflag"
-	"github.com/spf13/viper"
 	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
 )
 
 var (
-	RedisHost string
-	Debug     bool
-	TestMode  bool
+	RedisHost      string
+	QueueBackend   string
+	ConfigFile     string
+	SecretProvider string
+	Debug          bool
+	TestMode       bool
 )
 
 func init() {
 	rootCmd.PersistentFlags().StringVarP(&RedisHost, "redis", "r", "localhost:6379", "The Redis instance to connect to")
+	rootCmd.PersistentFlags().StringVarP(&QueueBackend, "queue-backend", "", jobs.BackendLists, "Transport used to exchange jobs with the bot: 'lists' or 'streams'. Must match the bot")
 	rootCmd.PersistentFlags().BoolVarP(&TestMode, "test", "t", false, "Enable test mode - do not run generate or post to S3")
 	rootCmd.PersistentFlags().BoolVarP(&Debug, "debug", "d", false, "Enable debug logging")
+	rootCmd.PersistentFlags().StringVarP(&SecretProvider, "secret-provider", "", "", "Where to read the secrets left empty: file:<dir> reads <dir>/<flag name>, env:<prefix> reads <PREFIX>_<FLAG_NAME>, vault:<url> reads the fields of a Vault KV secret with the token in VAULT_TOKEN")
+	rootCmd.PersistentFlags().StringVarP(&ConfigFile, config.FlagName, "", "", "YAML file of settings keyed by the flag names, such as worker.yaml. Flags win over ILWORKER_* environment variables, which win over the file")
 }
 
 var rootCmd = &cobra.Command{
@@ -52,25 +60,34 @@ func initLogger(debug bool) *zap.Logger {
 		OutputPaths:      []string{"stderr"},
 		ErrorOutputPaths: []string{"stderr"},
 	}
-	logger, _ := loggerConfig.Build()
+	logger, _ := loggerConfig.Build(zap.WrapCore(func(core zapcore.Core) zapcore.Core {
+		return secrets.RedactCore(core, secretRedactor)
+	}))
 	return logger
 }
 
-func initializeConfig(cmd *cobra.Command) error {
-	v := viper.New()
-	v.SetEnvPrefix("ILWORKER")
-	v.SetEnvKeyReplacer(strings.NewReplacer("-", "_"))
-	v.AutomaticEnv()
-	bindFlags(cmd, v)
-	return nil
+// configLoader reads the settings of the worker from the ILWORKER_*
+// environment variables, and the precheck endpoint from its older variable.
+var configLoader = config.Loader{
+	EnvPrefix:  "ILWORKER",
+	EnvAliases: map[string]string{"precheck-endpoint-url": "PECHECK_ENDPOINT"},
 }
 
-func bindFlags(cmd *cobra.Command, v *viper.Viper) {
-	cmd.Flags().VisitAll(func(f *pflag.Flag) {
-		configName := f.Name
-		if !f.Changed && v.IsSet(configName) {
-			val := v.Get(configName)
-			_ = cmd.Flags().Set(f.Name, fmt.Sprintf("%v", val))
-		}
-	})
+// settings are the loaded settings of the worker, along with their sources.
+var settings *config.Settings
+
+// initializeConfig applies the environment and the configuration file to the
+// settings of the worker not given on the command line. The settings of the
+// generate command are loaded whatever the command run, so the file is
+// checked as a whole.
+func initializeConfig(cmd *cobra.Command) error {
+	flags := pflag.NewFlagSet("worker", pflag.ContinueOnError)
+	flags.AddFlagSet(cmd.Root().PersistentFlags())
+	flags.AddFlagSet(generateCmd.Flags())
+	var err error
+	settings, err = configLoader.Load(flags, ConfigFile)
+	if err != nil {
+		return err
+	}
+	return resolveSecrets(cmd.Context())
 }
@@ -0,0 +1,226 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/instructlab/instructlab-bot/pkg/jobs"
+	"go.uber.org/zap"
+	"gopkg.in/yaml.v2"
+)
+
+// errNoChanges is returned by a runner with nothing to do on the PR. The job
+// then completes without a results URL.
+var errNoChanges = errors.New("no taxonomy files were changed")
+
+// JobRun holds what a Runner needs on top of the worker to run a job.
+type JobRun struct {
+	Logger    *zap.SugaredLogger
+	Lab       string
+	OutputDir string
+	// ModelName is the model served by the endpoint the job runs against.
+	ModelName string
+}
+
+// Runner runs the jobs of one type.
+type Runner interface {
+	// Run runs a job, writing its results in run.OutputDir.
+	Run(w *Worker, run JobRun) error
+}
+
+// RunnerFunc adapts a function to the Runner interface.
+type RunnerFunc func(w *Worker, run JobRun) error
+
+// Run calls f.
+func (f RunnerFunc) Run(w *Worker, run JobRun) error {
+	return f(w, run)
+}
+
+// runners maps each job type the worker can run to its runner.
+var runners = map[string]Runner{}
+
+func init() {
+	RegisterRunner(jobs.TypeGenerateLocal, RunnerFunc((*Worker).runGenerateLocal))
+	RegisterRunner(jobs.TypePrecheck, RunnerFunc((*Worker).runPrecheckJob))
+	RegisterRunner(jobs.TypeSDG, RunnerFunc((*Worker).runSDG))
+}
+
+// RegisterRunner sets the runner of a job type. It panics if the job type
+// already has one.
+func RegisterRunner(jobType string, runner Runner) {
+	if _, ok := runners[jobType]; ok {
+		panic(fmt.Sprintf("job type %q already has a runner", jobType))
+	}
+	runners[jobType] = runner
+}
+
+// runGenerateLocal runs generate on the local worker node.
+// @instructlab-bot generate-local
+func (w *Worker) runGenerateLocal(run JobRun) error {
+	generateArgs := []string{"data", "generate", "--num-instructions", fmt.Sprintf("%d", w.numInstructions()), "--output-dir", run.OutputDir}
+	if w.args.Model != "" {
+		generateArgs = append(generateArgs, "--model", w.args.Model)
+	}
+
+	cmd := w.ilabCommand(run.Lab, generateArgs...)
+	if WorkDir != "" {
+		cmd.Dir = WorkDir
+	}
+
+	var stderr bytes.Buffer
+	// Capture both the ilab err buffer and the os.Stderr, and stream it to the job log
+	cmd.Stderr = io.MultiWriter(&stderr, os.Stderr, w.jobOutput())
+	cmd.Env = os.Environ()
+	cmd.Stdout = os.Stdout
+
+	// Run the command
+	run.Logger.Infof("Running the generate command: %s", cmd.String())
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("Error running command (%s %s): %v. \nDetails: %s", cmd.Path, strings.Join(generateArgs, " "), err, stderr.String())
+	}
+	return nil
+}
+
+// runPrecheckJob runs precheck on a backend node.
+// @instructlab-bot precheck
+func (w *Worker) runPrecheckJob(run JobRun) error {
+	return w.runPrecheck(run.Lab, run.OutputDir, run.ModelName)
+}
+
+// runSDG runs generate on the SDG backend. ilab diff is run since the sdg
+// generation is not part of upstream cli.
+// @instructlab-bot generate
+func (w *Worker) runSDG(run JobRun) error {
+	sugar := run.Logger
+	cmdDiff := w.ilabCommand("ilab", append([]string{"taxonomy", "diff"}, w.taxonomyBaseArgs()...)...)
+	var stderr bytes.Buffer
+	cmdDiff.Stderr = &stderr
+
+	diffOutput, err := cmdDiff.Output()
+	if err != nil {
+		return fmt.Errorf("Failed to execute 'ilab diff': %v. \nDetails: %s", err, stderr.String())
+	}
+
+	diffOutputLines, err := w.selectFiles(strings.Split(string(diffOutput), "\n"))
+	if err != nil {
+		return err
+	}
+	// Filter taxonomy files ending in .yaml and prepare them relative to workDir
+	var taxonomyFiles []string
+	for _, file := range diffOutputLines {
+		if strings.HasSuffix(file, ".yaml") {
+			relativePath := filepath.Join(w.ilabConfig.Generate.TaxonomyPath, file)
+			taxonomyFiles = append(taxonomyFiles, relativePath)
+		}
+	}
+
+	// Uncomment to bypass ilab diff
+	//taxonomyFiles, err := discoverGitTaxonomyFiles(taxonomyDir, "main")
+	//if err != nil {
+	//	sugar.Errorf("Failed to discover taxonomy files: %v", err)
+	//	return
+	//}
+
+	if len(taxonomyFiles) == 0 {
+		return errNoChanges
+	}
+
+	maxSeed := w.maxSeed
+	if w.args.MaxSeed > 0 {
+		maxSeed = w.args.MaxSeed
+	}
+
+	// Process each YAML file and filter questions if over the max seed
+	filteredFiles := []string{}
+	for _, file := range taxonomyFiles {
+		f, err := os.Open(file)
+		if err != nil {
+			sugar.Errorf("Failed to open file: %v", err)
+			continue
+		}
+		defer f.Close()
+
+		decoder := yaml.NewDecoder(f)
+		var data map[string]interface{}
+		if err := decoder.Decode(&data); err != nil {
+			sugar.Errorf("Failed to decode YAML file: %v", err)
+			continue
+		}
+
+		if seedExamples, ok := data["seed_examples"].([]interface{}); ok && len(seedExamples) > maxSeed {
+			originalCount := len(seedExamples)
+			data["seed_examples"] = seedExamples[:maxSeed]
+			outputData, err := yaml.Marshal(data)
+			if err != nil {
+				sugar.Errorf("Failed to re-marshal filtered YAML data: %v", err)
+				continue
+			}
+
+			// Write the modified content back to a new file to pass to datagenSvc instead of the original diff
+			filteredQNA, err := os.CreateTemp(w.workspace.dir, "filtered-*.yaml")
+			if err != nil {
+				sugar.Errorf("Failed to create temporary file: %v", err)
+				continue
+			}
+			defer filteredQNA.Close()
+
+			if _, err = filteredQNA.Write(outputData); err != nil {
+				sugar.Errorf("Failed to write filtered data to the new QNA file: %v", err)
+				continue
+			}
+			sugar.Infof("Trimmed %s from %d to %d Q&A pairs", file, originalCount, maxSeed)
+
+			filteredFiles = append(filteredFiles, filteredQNA.Name())
+		} else {
+			// No filtering needed, use the original file
+			filteredFiles = append(filteredFiles, file)
+		}
+	}
+
+	// Generate data with potentially filtered files
+	outputFiles, err := w.datagenSvc(filteredFiles, run.OutputDir, w.numInstructions())
+	if err != nil {
+		return err
+	}
+	sugar.Infof("Generated data written to: %v", outputFiles)
+	return nil
+}
+
+// numInstructions returns the number of instructions to generate for the job.
+func (w *Worker) numInstructions() int {
+	if w.args.NumInstructions > 0 {
+		return w.args.NumInstructions
+	}
+	return NumInstructions
+}
+
+// selectFiles keeps the files of a taxonomy diff requested with the --files
+// option of the job, or all of them when the option is not set. Requesting a
+// file the PR does not change is an error.
+func (w *Worker) selectFiles(diff []string) ([]string, error) {
+	if len(w.args.Files) == 0 {
+		return diff, nil
+	}
+	changed := make([]string, 0, len(diff))
+	for _, file := range diff {
+		changed = append(changed, strings.TrimSpace(file))
+	}
+	var selected, missing []string
+	for _, file := range w.args.Files {
+		if slices.Contains(changed, file) {
+			selected = append(selected, file)
+		} else {
+			missing = append(missing, file)
+		}
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("files not changed by the PR: %s", strings.Join(missing, ", "))
+	}
+	return selected, nil
+}
@@ -0,0 +1,36 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"io"
+
+	"github.com/instructlab/instructlab-bot/pkg/secrets"
+)
+
+// secretRedactor redacts the secrets of the worker from its logs, from the
+// job details and logs it writes to Redis, and from the files it uploads.
+var secretRedactor = secrets.NewRedactor()
+
+// resolveSecrets reads the secrets from their files or from --secret-provider,
+// and registers them for redaction.
+func resolveSecrets(ctx context.Context) error {
+	provider, err := secrets.Open(SecretProvider)
+	if err != nil {
+		return err
+	}
+	if err := configLoader.ResolveSecrets(ctx, settings, provider); err != nil {
+		return err
+	}
+	secretRedactor.Add(settings.Secrets()...)
+	return nil
+}
+
+// redactedBody reads a file to upload, with the secrets redacted.
+func redactedBody(r io.Reader) (io.ReadSeeker, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	return bytes.NewReader(secretRedactor.RedactBytes(data)), nil
+}
@@ -0,0 +1,22 @@
+package cmd
+
+import (
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestRedactedBody(t *testing.T) {
+	secretRedactor.Add("sk-precheck-key")
+	body, err := redactedBody(strings.NewReader("Question:\nilab model chat --api-key sk-precheck-key\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := io.ReadAll(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "sk-precheck-key") {
+		t.Errorf("Uploaded file holds the secret: %s", data)
+	}
+}
@@ -285,11 +285,16 @@ func generateFormattedJSON(ctx context.Context, outputDir, filename string, svc
 		return ""
 	}
 	defer file.Close()
+	body, err := redactedBody(file)
+	if err != nil {
+		logger.Errorf("Could not read generated HTML file: %v", err)
+		return ""
+	}
 
 	_, err = svc.PutObject(ctx, &s3.PutObjectInput{
 		Bucket:      aws.String(S3Bucket),
 		Key:         aws.String(s3Key),
-		Body:        file,
+		Body:        body,
 		ContentType: aws.String("text/html"),
 	})
 	if err != nil {
@@ -363,11 +368,16 @@ func generateFormattedYAML(ctx context.Context, outputDir, filename string, svc
 		return ""
 	}
 	defer file.Close()
+	body, err := redactedBody(file)
+	if err != nil {
+		logger.Errorf("Could not read generated HTML file: %v", err)
+		return ""
+	}
 
 	_, err = svc.PutObject(ctx, &s3.PutObjectInput{
 		Bucket:      aws.String(S3Bucket),
 		Key:         aws.String(s3Key),
-		Body:        file,
+		Body:        body,
 		ContentType: aws.String("text/html"),
 	})
 	if err != nil {
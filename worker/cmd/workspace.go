@@ -0,0 +1,98 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/yaml.v2"
+)
+
+// workspace is the private directory of a job. Jobs running concurrently on
+// the same host each clone the taxonomy and write their chat logs and output
+// in their own workspace, and run ilab with a config file pointing there.
+type workspace struct {
+	dir         string
+	taxonomyDir string
+	chatlogDir  string
+	configFile  string
+	ilabConfig  *IlabConfig
+}
+
+// newWorkspace creates a temporary workspace for a job, along with a copy of
+// the ilab config file whose taxonomy, chat log and output paths point into it.
+func newWorkspace(jobID string, ilabConfig *IlabConfig) (*workspace, error) {
+	dir, err := os.MkdirTemp("", fmt.Sprintf("ilab-job-%s-", jobID))
+	if err != nil {
+		return nil, fmt.Errorf("could not create job workspace: %w", err)
+	}
+	ws := &workspace{
+		dir:         dir,
+		taxonomyDir: filepath.Join(dir, "taxonomy"),
+		chatlogDir:  filepath.Join(dir, "chatlogs"),
+		configFile:  filepath.Join(dir, "config.yaml"),
+	}
+	if err := os.MkdirAll(ws.chatlogDir, 0755); err != nil {
+		ws.cleanup()
+		return nil, fmt.Errorf("could not create chat log directory: %w", err)
+	}
+
+	cfg := *ilabConfig
+	cfg.Chat.LogsDir = ws.chatlogDir
+	cfg.Generate.TaxonomyPath = ws.taxonomyDir
+	cfg.Generate.OutputDir = filepath.Join(dir, "generated")
+	ws.ilabConfig = &cfg
+
+	if err := ws.writeIlabConfig(); err != nil {
+		ws.cleanup()
+		return nil, err
+	}
+	return ws, nil
+}
+
+// writeIlabConfig copies the ilab config file into the workspace with its
+// paths rewritten. The file is edited as a generic document so settings the
+// worker does not know about are preserved.
+func (ws *workspace) writeIlabConfig() error {
+	cfgData, err := os.ReadFile(IlabConfigFile)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %v", err)
+	}
+	var doc map[interface{}]interface{}
+	if err := yaml.Unmarshal(cfgData, &doc); err != nil {
+		return fmt.Errorf("failed to unmarshal config file: %v", err)
+	}
+	if doc == nil {
+		doc = map[interface{}]interface{}{}
+	}
+
+	setConfigValue(doc, "chat", "logs_dir", ws.ilabConfig.Chat.LogsDir)
+	setConfigValue(doc, "generate", "taxonomy_path", ws.ilabConfig.Generate.TaxonomyPath)
+	setConfigValue(doc, "generate", "output_dir", ws.ilabConfig.Generate.OutputDir)
+
+	out, err := yaml.Marshal(doc)
+	if err != nil {
+		return fmt.Errorf("failed to marshal job config file: %v", err)
+	}
+	if err := os.WriteFile(ws.configFile, out, 0644); err != nil {
+		return fmt.Errorf("failed to write job config file: %v", err)
+	}
+	return nil
+}
+
+// cleanup removes the workspace and everything in it.
+func (ws *workspace) cleanup() error {
+	if err := os.RemoveAll(ws.dir); err != nil {
+		return fmt.Errorf("could not delete job workspace: %v", err)
+	}
+	return nil
+}
+
+func setConfigValue(doc map[interface{}]interface{}, section, key string, value interface{}) {
+	sectionMap, ok := doc[section].(map[interface{}]interface{})
+	if !ok {
+		sectionMap = map[interface{}]interface{}{}
+		doc[section] = sectionMap
+	}
+	sectionMap[key] = value
+}